@@ -0,0 +1,208 @@
+// Package apikey issues and verifies the API keys a media account gives its own
+// systems, e.g. a newsroom CMS creating bookings. A key acts as the media account
+// but only on the routes its scopes allow. Only the sha256 of a key is stored.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+// Scope is what a key may be used for
+type Scope string
+
+const (
+	ScopeBookingsRead  Scope = "bookings:read"
+	ScopeBookingsWrite Scope = "bookings:write"
+	ScopeCreditsRead   Scope = "credits:read"
+)
+
+// Scopes that can be given to a key
+var Scopes = []Scope{ScopeBookingsRead, ScopeBookingsWrite, ScopeCreditsRead}
+
+const (
+	// Prefix starts every key so leaked keys are easy to recognise
+	Prefix = "byrd_"
+	// prefixLen is how much of the key is stored in the clear to tell keys apart
+	prefixLen = len(Prefix) + 8
+	// MaxKeys a media account may have active at once
+	MaxKeys = 20
+)
+
+var (
+	ErrInvalidKey   = errors.New("api key is invalid or revoked")
+	ErrInvalidScope = errors.New("api key scopes must be one or more of bookings:read, bookings:write and credits:read")
+	ErrTooManyKeys  = errors.New("too many active api keys, revoke one first")
+	ErrNotFound     = errors.New("api key not found")
+)
+
+// Key is an issued key without its secret
+type Key struct {
+	ID         uuid.UUID  `json:"id"`
+	MediaID    string     `json:"mediaId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedBy  string     `json:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+}
+
+// Allows reports whether the key holds scope
+func (k Key) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active is true until the key is revoked
+func (k Key) Active() bool {
+	return k.RevokedAt == nil
+}
+
+func fromRow(row postgres.ApiKey) Key {
+	k := Key{
+		ID:        row.ID,
+		MediaID:   row.MediaID,
+		Name:      row.Name,
+		Prefix:    row.Prefix,
+		Scopes:    make([]Scope, 0, len(row.Scopes)),
+		CreatedBy: row.CreatedBy,
+		CreatedAt: row.CreatedAt,
+	}
+	for _, s := range row.Scopes {
+		k.Scopes = append(k.Scopes, Scope(s))
+	}
+	if row.LastUsedAt.Valid {
+		k.LastUsedAt = &row.LastUsedAt.Time
+	}
+	if row.RevokedAt.Valid {
+		k.RevokedAt = &row.RevokedAt.Time
+	}
+	return k
+}
+
+// ParseScopes validates and dedupes the requested scopes
+func ParseScopes(scopes []string) ([]Scope, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	var parsed []Scope
+	seen := make(map[Scope]bool)
+	for _, s := range scopes {
+		scope := Scope(strings.TrimSpace(s))
+		valid := false
+		for _, known := range Scopes {
+			valid = valid || scope == known
+		}
+		if !valid {
+			return nil, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			parsed = append(parsed, scope)
+		}
+	}
+	return parsed, nil
+}
+
+func hash(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// Create issues a key for mediaID. The key is only returned here, it can not be shown again.
+func Create(ctx context.Context, q *postgres.Queries, mediaID, createdBy, name string, scopes []Scope) (string, Key, error) {
+	existing, err := q.ListApiKeys(ctx, mediaID)
+	if err != nil {
+		return "", Key{}, err
+	}
+	active := 0
+	for _, k := range existing {
+		if !k.RevokedAt.Valid {
+			active++
+		}
+	}
+	if active >= MaxKeys {
+		return "", Key{}, ErrTooManyKeys
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", Key{}, err
+	}
+	key := Prefix + hex.EncodeToString(b)
+	params := postgres.CreateApiKeyParams{
+		MediaID:   mediaID,
+		Name:      strings.TrimSpace(name),
+		Prefix:    key[:prefixLen],
+		KeyHash:   hash(key),
+		CreatedBy: createdBy,
+	}
+	for _, s := range scopes {
+		params.Scopes = append(params.Scopes, string(s))
+	}
+	row, err := q.CreateApiKey(ctx, params)
+	if err != nil {
+		return "", Key{}, err
+	}
+	return key, fromRow(row), nil
+}
+
+// Verify returns the active key and records that it was used
+func Verify(ctx context.Context, q *postgres.Queries, key string) (Key, error) {
+	if !strings.HasPrefix(key, Prefix) {
+		return Key{}, ErrInvalidKey
+	}
+	row, err := q.GetApiKeyByHash(ctx, hash(key))
+	if err == sql.ErrNoRows {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	// last used is only written once a minute, see TouchApiKey
+	if err := q.TouchApiKey(ctx, row.ID); err != nil {
+		return Key{}, err
+	}
+	return fromRow(row), nil
+}
+
+// List the active and revoked keys of mediaID, newest first
+func List(ctx context.Context, q *postgres.Queries, mediaID string) ([]Key, error) {
+	rows, err := q.ListApiKeys(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, fromRow(row))
+	}
+	return keys, nil
+}
+
+// Revoke stops key id of mediaID from working
+func Revoke(ctx context.Context, q *postgres.Queries, id uuid.UUID, mediaID string) error {
+	n, err := q.RevokeApiKey(ctx, postgres.RevokeApiKeyParams{ID: id, MediaID: mediaID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
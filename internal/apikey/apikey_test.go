@@ -0,0 +1,44 @@
+package apikey
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want int
+		err  error
+	}{
+		{[]string{"bookings:read"}, 1, nil},
+		{[]string{"bookings:read", " bookings:write", "bookings:read"}, 2, nil},
+		{[]string{"bookings:read", "admin"}, 0, ErrInvalidScope},
+		{nil, 0, ErrInvalidScope},
+	}
+	for _, test := range tests {
+		scopes, err := ParseScopes(test.in)
+		if err != test.err || len(scopes) != test.want {
+			t.Errorf("%v: expected %d scopes and %v, got %v and %v", test.in, test.want, test.err, scopes, err)
+		}
+	}
+}
+
+func TestFromRow(t *testing.T) {
+	used := time.Now()
+	k := fromRow(postgres.ApiKey{
+		MediaID:    "media",
+		Scopes:     []string{"bookings:read"},
+		KeyHash:    hash(Prefix + "secret"),
+		LastUsedAt: sql.NullTime{Time: used, Valid: true},
+	})
+	if !k.Allows(ScopeBookingsRead) || k.Allows(ScopeBookingsWrite) {
+		t.Errorf("unexpected scopes %v", k.Scopes)
+	}
+	if k.LastUsedAt == nil || !k.LastUsedAt.Equal(used) || !k.Active() {
+		t.Errorf("unexpected key %+v", k)
+	}
+}
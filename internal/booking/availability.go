@@ -0,0 +1,53 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+	utils "github.com/byrdapp/byrd-pro-api/public/time"
+)
+
+var (
+	ErrBookingConflict = errors.New("photographer is already booked in an overlapping period")
+	ErrUnavailable     = errors.New("photographer is not available for the whole period")
+)
+
+// CheckAvailabilityTx fails with ErrBookingConflict when the photographer has another
+// busy booking overlapping [start, end), and with ErrUnavailable when part of the period is
+// outside their weekly slots or blocked by an exception, see workingTime. The photographer is locked
+// for the rest of the transaction, so two bookings can not be accepted for the same period concurrently.
+func CheckAvailabilityTx(ctx context.Context, q *postgres.Queries, photographerID string, bookingID uuid.UUID, start, end time.Time) error {
+	if err := q.LockPhotographer(ctx, photographerID); err != nil {
+		return err
+	}
+	conflicts, err := q.ListPhotographerConflicts(ctx, postgres.ListPhotographerConflictsParams{
+		PhotographerID: photographerID,
+		BookingID:      bookingID,
+		DateStart:      start,
+		DateEnd:        end,
+	})
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return ErrBookingConflict
+	}
+	period := utils.NewTime(start, end)
+	working, err := workingTime(ctx, q, photographerID, period)
+	if err != nil {
+		return err
+	}
+	if !covered(period, working) {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// covered reports whether no part of period is outside the working intervals
+func covered(period *utils.TimeBuilder, working []*utils.TimeBuilder) bool {
+	return len(utils.Subtract([]*utils.TimeBuilder{period}, working)) == 0
+}
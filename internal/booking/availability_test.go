@@ -0,0 +1,237 @@
+package booking
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/byrdapp/timestamp/timeparser"
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+	"github.com/byrdapp/byrd-pro-api/public/ics"
+	utils "github.com/byrdapp/byrd-pro-api/public/time"
+)
+
+// photographerBooking creates a booking of [start, end) assigned to the photographer in status
+func photographerBooking(t *testing.T, db *sql.DB, photographerID string, start, end time.Time, status postgres.BookingStatus) uuid.UUID {
+	ctx := context.Background()
+	q := postgres.New(db)
+	id, err := q.CreateBooking(ctx, postgres.CreateBookingParams{
+		MediaID:   uuid.New().String(),
+		Task:      "portrait",
+		Price:     100,
+		Credits:   1,
+		DateStart: timeparser.Timestamp(start),
+		DateEnd:   timeparser.Timestamp(end),
+		Lat:       55.67,
+		Lng:       12.56,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AcceptBooking(ctx, postgres.AcceptBookingParams{ID: id, PhotographerID: photographerID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.UpdateBookingStatus(ctx, postgres.UpdateBookingStatusParams{ID: id, Status: status}); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func checkAvailability(db *sql.DB, photographerID string, bookingID uuid.UUID, start, end time.Time) error {
+	return postgres.ExecTx(context.Background(), db, func(q *postgres.Queries) error {
+		return CheckAvailabilityTx(context.Background(), q, photographerID, bookingID, start, end)
+	})
+}
+
+func TestCheckAvailabilityBoundaries(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	photographerID := uuid.New().String()
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	end := start.Add(time.Hour)
+	busy := photographerBooking(t, db, photographerID, start, end, postgres.BookingStatusAccepted)
+
+	tests := []struct {
+		name       string
+		bookingID  uuid.UUID
+		start, end time.Time
+		err        error
+	}{
+		{"touching before", uuid.New(), start.Add(-time.Hour), start, nil},
+		{"touching after", uuid.New(), end, end.Add(time.Hour), nil},
+		{"overlapping start", uuid.New(), start.Add(-30 * time.Minute), start.Add(time.Second), ErrBookingConflict},
+		{"overlapping end", uuid.New(), end.Add(-time.Second), end.Add(time.Hour), ErrBookingConflict},
+		{"inside", uuid.New(), start.Add(10 * time.Minute), start.Add(20 * time.Minute), ErrBookingConflict},
+		{"enclosing", uuid.New(), start.Add(-time.Hour), end.Add(time.Hour), ErrBookingConflict},
+		{"same period", uuid.New(), start, end, ErrBookingConflict},
+		// updating a booking does not conflict with itself
+		{"itself", busy, start, end, nil},
+	}
+	for _, tt := range tests {
+		if err := checkAvailability(db, photographerID, tt.bookingID, tt.start, tt.end); err != tt.err {
+			t.Errorf("%s: expected %v got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestCheckAvailabilityStatuses(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	start := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		status postgres.BookingStatus
+		busy   bool
+	}{
+		{postgres.BookingStatusRequested, false},
+		{postgres.BookingStatusOffered, false},
+		{postgres.BookingStatusCancelled, false},
+		{postgres.BookingStatusExpired, false},
+		{postgres.BookingStatusAccepted, true},
+		{postgres.BookingStatusInProgress, true},
+		{postgres.BookingStatusDelivered, true},
+		{postgres.BookingStatusCompleted, true},
+		{postgres.BookingStatusDisputed, true},
+	}
+	for _, tt := range tests {
+		photographerID := uuid.New().String()
+		photographerBooking(t, db, photographerID, start, end, tt.status)
+
+		var want error
+		if tt.busy {
+			want = ErrBookingConflict
+		}
+		if err := checkAvailability(db, photographerID, uuid.New(), start, end); err != want {
+			t.Errorf("%s: expected %v got %v", tt.status, want, err)
+		}
+		intervals, err := postgres.New(db).ListPhotographerBusyIntervals(context.Background(), postgres.ListPhotographerBusyIntervalsParams{
+			PhotographerID: photographerID,
+			RangeStart:     start.Add(-time.Hour),
+			RangeEnd:       end.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(intervals) == 1; got != tt.busy {
+			t.Errorf("%s: expected busy %v got %d intervals", tt.status, tt.busy, len(intervals))
+		}
+	}
+}
+
+func TestBusyIntervalsBoundaries(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	photographerID := uuid.New().String()
+	start := time.Now().Add(96 * time.Hour).Truncate(time.Second)
+	end := start.Add(time.Hour)
+	photographerBooking(t, db, photographerID, start, end, postgres.BookingStatusAccepted)
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		count      int
+	}{
+		{"range ends at the start", start.Add(-time.Hour), start, 0},
+		{"range starts at the end", end, end.Add(time.Hour), 0},
+		{"range covers the last second", end.Add(-time.Second), end.Add(time.Hour), 1},
+		{"range inside", start.Add(time.Minute), end.Add(-time.Minute), 1},
+	}
+	for _, tt := range tests {
+		intervals, err := postgres.New(db).ListPhotographerBusyIntervals(context.Background(), postgres.ListPhotographerBusyIntervalsParams{
+			PhotographerID: photographerID,
+			RangeStart:     tt.start,
+			RangeEnd:       tt.end,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(intervals) != tt.count {
+			t.Errorf("%s: expected %d intervals got %d", tt.name, tt.count, len(intervals))
+		}
+	}
+}
+
+func TestCheckAvailabilityCalendar(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	photographerID := uuid.New().String()
+	// a monday two weeks ahead, the photographer works 9-17 UTC on mondays
+	day := time.Now().UTC().AddDate(0, 0, 14).Truncate(24 * time.Hour)
+	for day.Weekday() != time.Monday {
+		day = day.AddDate(0, 0, 1)
+	}
+	at := func(hour int) time.Time { return day.Add(time.Duration(hour) * time.Hour) }
+
+	// without weekly slots only exceptions block time
+	if err := checkAvailability(db, photographerID, uuid.New(), at(20), at(21)); err != nil {
+		t.Errorf("expected no calendar to be available got %v", err)
+	}
+	if _, err := ReplaceSlots(ctx, db, photographerID, []postgres.CreateAvailabilitySlotParams{
+		{Weekday: int16(time.Monday), StartMinute: 9 * 60, EndMinute: 17 * 60, TimeZone: "UTC"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportEvents(ctx, db, photographerID, []ics.Event{{UID: "busy", Start: at(12), End: at(13)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		err        error
+	}{
+		{"inside the slot", at(9), at(12), nil},
+		{"touching the import", at(13), at(17), nil},
+		{"before the slot", at(8), at(10), ErrUnavailable},
+		{"after the slot", at(16), at(18), ErrUnavailable},
+		{"imported busy time", at(11), at(13), ErrUnavailable},
+	}
+	for _, tt := range tests {
+		if err := checkAvailability(db, photographerID, uuid.New(), tt.start, tt.end); err != tt.err {
+			t.Errorf("%s: expected %v got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestCalendarTime(t *testing.T) {
+	// 2020-04-13 is a monday, the photographer works 9-17 UTC on mondays
+	day := time.Date(2020, time.April, 13, 0, 0, 0, 0, time.UTC)
+	at := func(hour int) time.Time { return day.Add(time.Duration(hour) * time.Hour) }
+	bounds := utils.NewTime(day, day.AddDate(0, 0, 1))
+	slots := []postgres.AvailabilitySlot{{Weekday: int16(time.Monday), StartMinute: 9 * 60, EndMinute: 17 * 60, TimeZone: "UTC"}}
+	exceptions := []postgres.AvailabilityException{
+		{Kind: postgres.AvailabilityKindUnavailable, DateStart: at(12), DateEnd: at(13)},
+		{Kind: postgres.AvailabilityKindAvailable, DateStart: at(18), DateEnd: at(20)},
+	}
+
+	tests := []struct {
+		name       string
+		slots      []postgres.AvailabilitySlot
+		start, end time.Time
+		available  bool
+	}{
+		{"inside the slot", slots, at(9), at(12), true},
+		{"touching the exception", slots, at(13), at(17), true},
+		{"available exception", slots, at(18), at(20), true},
+		{"before the slot", slots, at(8), at(10), false},
+		{"after the slot", slots, at(16), at(18), false},
+		{"unavailable exception", slots, at(11), at(13), false},
+		// without weekly slots only exceptions block time
+		{"without slots", nil, at(20), at(22), true},
+		{"without slots blocked", nil, at(12), at(14), false},
+	}
+	for _, tt := range tests {
+		working, err := calendarTime(tt.slots, exceptions, bounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := covered(utils.NewTime(tt.start, tt.end), working); got != tt.available {
+			t.Errorf("%s: expected available %v got %v", tt.name, tt.available, got)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package booking
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/credits"
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+var (
+	ErrInvalidTransition = errors.New("booking status transition is not allowed")
+	ErrUnknownStatus     = errors.New("unknown booking status")
+)
+
+// transitions lists every status a booking is allowed to move to from a given status.
+// completed, cancelled and expired are terminal and have no outgoing transitions.
+var transitions = map[postgres.BookingStatus][]postgres.BookingStatus{
+	postgres.BookingStatusRequested: {
+		postgres.BookingStatusOffered,
+		postgres.BookingStatusCancelled,
+		postgres.BookingStatusExpired,
+	},
+	postgres.BookingStatusOffered: {
+		postgres.BookingStatusRequested, // all offers were withdrawn or expired
+		postgres.BookingStatusAccepted,
+		postgres.BookingStatusCancelled,
+		postgres.BookingStatusExpired,
+	},
+	postgres.BookingStatusAccepted: {
+		postgres.BookingStatusInProgress,
+		postgres.BookingStatusCancelled,
+		postgres.BookingStatusDisputed,
+	},
+	postgres.BookingStatusInProgress: {
+		postgres.BookingStatusDelivered,
+		postgres.BookingStatusCancelled,
+		postgres.BookingStatusDisputed,
+	},
+	postgres.BookingStatusDelivered: {
+		postgres.BookingStatusCompleted,
+		postgres.BookingStatusDisputed,
+	},
+	postgres.BookingStatusDisputed: {
+		postgres.BookingStatusCompleted,
+		postgres.BookingStatusCancelled,
+	},
+	postgres.BookingStatusCompleted: {},
+	postgres.BookingStatusCancelled: {},
+	postgres.BookingStatusExpired:   {},
+}
+
+// ParseStatus validates a client supplied status
+func ParseStatus(s string) (postgres.BookingStatus, error) {
+	status := postgres.BookingStatus(s)
+	if _, ok := transitions[status]; !ok {
+		return "", ErrUnknownStatus
+	}
+	return status, nil
+}
+
+// CanTransition reports whether a booking in status from may be moved to status to
+func CanTransition(from, to postgres.BookingStatus) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal is true when no further transitions are possible
+func IsTerminal(status postgres.BookingStatus) bool {
+	next, ok := transitions[status]
+	return ok && len(next) == 0
+}
+
+// Transition moves the booking to status to in its own transaction. See TransitionTx.
+// A booking is only accepted through AcceptOffer, which sets its photographer after checking their
+// availability, so accepted fails with ErrInvalidTransition here.
+func Transition(ctx context.Context, db *sql.DB, bookingID uuid.UUID, to postgres.BookingStatus, actorID, reason string) (postgres.BookingTransition, error) {
+	var t postgres.BookingTransition
+	if to == postgres.BookingStatusAccepted {
+		return t, ErrInvalidTransition
+	}
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		var err error
+		t, err = TransitionTx(ctx, q, bookingID, to, actorID, reason)
+		return err
+	})
+	return t, err
+}
+
+// Create stores a new booking and reserves its credits from the media in one transaction
+func Create(ctx context.Context, db *sql.DB, arg postgres.CreateBookingParams, actorID string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		var err error
+		if id, err = q.CreateBooking(ctx, arg); err != nil {
+			return err
+		}
+		_, err = credits.ReserveTx(ctx, q, arg.MediaID, id, int64(arg.Credits), actorID)
+		return err
+	})
+	return id, err
+}
+
+// Cancel moves the booking to cancelled, which releases its reserved credits.
+// Bookings are cancelled rather than deleted: their transitions and credit transactions are kept.
+func Cancel(ctx context.Context, db *sql.DB, bookingID uuid.UUID, actorID, reason string) (postgres.BookingTransition, error) {
+	return Transition(ctx, db, bookingID, postgres.BookingStatusCancelled, actorID, reason)
+}
+
+// settleCredits captures the reserved credits of a completed booking and releases them
+// when it is cancelled or expires. Bookings without a reservation are left untouched.
+func settleCredits(ctx context.Context, q *postgres.Queries, b postgres.Booking, to postgres.BookingStatus, actorID string) error {
+	var err error
+	switch to {
+	case postgres.BookingStatusCompleted:
+		_, err = credits.CaptureTx(ctx, q, b.MediaID, b.ID, actorID)
+	case postgres.BookingStatusCancelled, postgres.BookingStatusExpired:
+		_, err = credits.ReleaseTx(ctx, q, b.MediaID, b.ID, actorID)
+	}
+	if err == credits.ErrNoReservation {
+		return nil
+	}
+	return err
+}
+
+// TransitionTx locks the booking row, validates the move from its current status
+// and records it in the transition history. Reserved credits are settled when the
+// booking reaches completed, cancelled or expired. q must be bound to a transaction.
+func TransitionTx(ctx context.Context, q *postgres.Queries, bookingID uuid.UUID, to postgres.BookingStatus, actorID, reason string) (postgres.BookingTransition, error) {
+	b, err := q.GetBookingForUpdate(ctx, bookingID)
+	if err != nil {
+		return postgres.BookingTransition{}, err
+	}
+	if !CanTransition(b.Status, to) {
+		return postgres.BookingTransition{}, ErrInvalidTransition
+	}
+	if err := q.UpdateBookingStatus(ctx, postgres.UpdateBookingStatusParams{
+		ID:     bookingID,
+		Status: to,
+	}); err != nil {
+		return postgres.BookingTransition{}, err
+	}
+	if err := settleCredits(ctx, q, b, to, actorID); err != nil {
+		return postgres.BookingTransition{}, err
+	}
+	return q.CreateBookingTransition(ctx, postgres.CreateBookingTransitionParams{
+		BookingID:  bookingID,
+		FromStatus: b.Status,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Reason:     reason,
+	})
+}
@@ -0,0 +1,113 @@
+package booking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/byrdapp/timestamp/timeparser"
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+	"github.com/byrdapp/byrd-pro-api/public/geo"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to postgres.BookingStatus
+		allowed  bool
+	}{
+		{postgres.BookingStatusRequested, postgres.BookingStatusOffered, true},
+		{postgres.BookingStatusOffered, postgres.BookingStatusAccepted, true},
+		{postgres.BookingStatusAccepted, postgres.BookingStatusInProgress, true},
+		{postgres.BookingStatusInProgress, postgres.BookingStatusDelivered, true},
+		{postgres.BookingStatusDelivered, postgres.BookingStatusCompleted, true},
+		{postgres.BookingStatusDelivered, postgres.BookingStatusDisputed, true},
+		{postgres.BookingStatusRequested, postgres.BookingStatusCompleted, false},
+		{postgres.BookingStatusRequested, postgres.BookingStatusAccepted, false},
+		{postgres.BookingStatusCompleted, postgres.BookingStatusCancelled, false},
+		{postgres.BookingStatusCancelled, postgres.BookingStatusRequested, false},
+		{postgres.BookingStatusAccepted, postgres.BookingStatusAccepted, false},
+	}
+	for _, test := range tests {
+		if got := CanTransition(test.from, test.to); got != test.allowed {
+			t.Errorf("%s -> %s: expected %v got %v", test.from, test.to, test.allowed, got)
+		}
+	}
+}
+
+func TestTransitionToAccepted(t *testing.T) {
+	// only AcceptOffer accepts a booking, the database is not reached
+	if _, err := Transition(context.Background(), nil, uuid.New(), postgres.BookingStatusAccepted, "admin", ""); err != ErrInvalidTransition {
+		t.Errorf("expected %v got %v", ErrInvalidTransition, err)
+	}
+}
+
+func TestTerminalStatus(t *testing.T) {
+	for status := range transitions {
+		terminal := status == postgres.BookingStatusCompleted ||
+			status == postgres.BookingStatusCancelled ||
+			status == postgres.BookingStatusExpired
+		if IsTerminal(status) != terminal {
+			t.Errorf("%s: expected terminal=%v", status, terminal)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	if _, err := ParseStatus("in_progress"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseStatus("done"); err != ErrUnknownStatus {
+		t.Errorf("expected %v got %v", ErrUnknownStatus, err)
+	}
+}
+
+func TestOfferWithoutPhotographers(t *testing.T) {
+	if _, err := Offer(context.Background(), nil, uuid.New(), nil, 0, "media"); err != ErrNoPhotographers {
+		t.Errorf("expected %v got %v", ErrNoPhotographers, err)
+	}
+}
+
+func TestPatchApply(t *testing.T) {
+	start := time.Date(2020, time.April, 15, 10, 0, 0, 0, time.UTC)
+	b := postgres.Booking{
+		ID:        uuid.New(),
+		Task:      "portrait",
+		DateStart: timeparser.Timestamp(start),
+		DateEnd:   timeparser.Timestamp(start.Add(time.Hour)),
+		Lat:       55.67,
+		Lng:       12.56,
+		Version:   3,
+	}
+	task := "  studio portrait "
+	lat := 91.0
+	end := timeparser.Timestamp(start.Add(-time.Hour))
+	later := timeparser.Timestamp(start.Add(2 * time.Hour))
+	accepted := b
+	accepted.PhotographerID = "photographer"
+
+	tests := []struct {
+		name    string
+		booking postgres.Booking
+		patch   Patch
+		err     error
+	}{
+		{"empty", b, Patch{Version: 3}, ErrEmptyPatch},
+		{"task", b, Patch{Version: 3, Task: &task}, nil},
+		{"end before start", b, Patch{Version: 3, DateEnd: &end}, ErrInvalidInterval},
+		{"bad location", b, Patch{Version: 3, Lat: &lat}, geo.ErrInvalidPoint},
+		{"task after accept", accepted, Patch{Version: 3, Task: &task}, nil},
+		{"dates after accept", accepted, Patch{Version: 3, DateEnd: &later}, ErrDatesLocked},
+	}
+	for _, test := range tests {
+		arg, err := test.patch.apply(test.booking)
+		if err != test.err {
+			t.Errorf("%s: expected %v got %v", test.name, test.err, err)
+			continue
+		}
+		if err == nil && (arg.Task != "studio portrait" || arg.Lng != b.Lng || !arg.DateStart.Equal(start) || arg.Version != 3) {
+			t.Errorf("%s: unexpected update %+v", test.name, arg)
+		}
+	}
+}
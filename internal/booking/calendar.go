@@ -0,0 +1,207 @@
+package booking
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+	"github.com/byrdapp/byrd-pro-api/public/ics"
+	utils "github.com/byrdapp/byrd-pro-api/public/time"
+)
+
+// sourceICS marks availability exceptions created by an ICS import
+const sourceICS = "ics"
+
+var (
+	ErrInvalidSlot      = errors.New("availability slot needs a weekday 0-6, minutes within the day and a known time zone")
+	ErrInvalidInterval  = errors.New("interval must have a start before its end")
+	ErrInvalidFeedToken = errors.New("calendar feed token is invalid")
+)
+
+// ValidateSlot checks the weekday, minutes and time zone of a weekly availability slot
+func ValidateSlot(s postgres.CreateAvailabilitySlotParams) error {
+	if s.Weekday < 0 || s.Weekday > 6 ||
+		s.StartMinute < 0 || s.StartMinute > 1439 ||
+		s.EndMinute < 0 || s.EndMinute > 1440 {
+		return ErrInvalidSlot
+	}
+	if _, err := time.LoadLocation(s.TimeZone); err != nil {
+		return ErrInvalidSlot
+	}
+	return nil
+}
+
+// ReplaceSlots replaces every weekly slot of the photographer
+func ReplaceSlots(ctx context.Context, db *sql.DB, photographerID string, slots []postgres.CreateAvailabilitySlotParams) ([]postgres.AvailabilitySlot, error) {
+	for _, s := range slots {
+		if err := ValidateSlot(s); err != nil {
+			return nil, err
+		}
+	}
+	var created []postgres.AvailabilitySlot
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		if err := q.DeleteAvailabilitySlots(ctx, photographerID); err != nil {
+			return err
+		}
+		for _, s := range slots {
+			s.PhotographerID = photographerID
+			slot, err := q.CreateAvailabilitySlot(ctx, s)
+			if err != nil {
+				return err
+			}
+			created = append(created, slot)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// FreeIntervals returns the time within bounds the photographer is available: their working time minus busy bookings.
+func FreeIntervals(ctx context.Context, q *postgres.Queries, photographerID string, bounds *utils.TimeBuilder) ([]*utils.TimeBuilder, error) {
+	if !bounds.IsValid() {
+		return nil, ErrInvalidInterval
+	}
+	working, err := workingTime(ctx, q, photographerID, bounds)
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := q.ListPhotographerBusyIntervals(ctx, postgres.ListPhotographerBusyIntervalsParams{
+		PhotographerID: photographerID,
+		RangeStart:     bounds.DateStart,
+		RangeEnd:       bounds.DateEnd,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var busy []*utils.TimeBuilder
+	for _, b := range bookings {
+		busy = append(busy, utils.NewTime(time.Time(b.DateStart), time.Time(b.DateEnd)))
+	}
+	return utils.Subtract(working, busy), nil
+}
+
+// workingTime returns the time within bounds the photographer's calendar has them working: the weekly slots
+// plus 'available' exceptions, minus 'unavailable' exceptions, which include imported ICS busy time.
+// A photographer without weekly slots works at any time an exception does not block.
+func workingTime(ctx context.Context, q *postgres.Queries, photographerID string, bounds *utils.TimeBuilder) ([]*utils.TimeBuilder, error) {
+	slots, err := q.ListAvailabilitySlots(ctx, photographerID)
+	if err != nil {
+		return nil, err
+	}
+	exceptions, err := q.ListAvailabilityExceptions(ctx, postgres.ListAvailabilityExceptionsParams{
+		PhotographerID: photographerID,
+		RangeStart:     bounds.DateStart,
+		RangeEnd:       bounds.DateEnd,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return calendarTime(slots, exceptions, bounds)
+}
+
+// calendarTime returns the time within bounds covered by the weekly slots and the exceptions, see workingTime.
+func calendarTime(slots []postgres.AvailabilitySlot, exceptions []postgres.AvailabilityException, bounds *utils.TimeBuilder) ([]*utils.TimeBuilder, error) {
+	var free, blocked []*utils.TimeBuilder
+	if len(slots) == 0 {
+		free = append(free, bounds)
+	}
+	for _, s := range slots {
+		loc, err := time.LoadLocation(s.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		free = append(free, utils.Weekly(time.Weekday(s.Weekday), int(s.StartMinute), int(s.EndMinute), loc, bounds)...)
+	}
+	for _, e := range exceptions {
+		i := utils.NewTime(e.DateStart, e.DateEnd).Clamp(bounds)
+		if e.Kind == postgres.AvailabilityKindAvailable {
+			free = append(free, i)
+		} else {
+			blocked = append(blocked, i)
+		}
+	}
+	return utils.Subtract(free, blocked), nil
+}
+
+// ImportEvents replaces the photographer's previously imported busy time with the given events.
+// Manually created exceptions are kept.
+func ImportEvents(ctx context.Context, db *sql.DB, photographerID string, events []ics.Event) ([]postgres.AvailabilityException, error) {
+	var created []postgres.AvailabilityException
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		if err := q.DeleteImportedAvailabilityExceptions(ctx, photographerID); err != nil {
+			return err
+		}
+		for _, e := range events {
+			ex, err := q.CreateAvailabilityException(ctx, postgres.CreateAvailabilityExceptionParams{
+				PhotographerID: photographerID,
+				Kind:           postgres.AvailabilityKindUnavailable,
+				DateStart:      e.Start,
+				DateEnd:        e.End,
+				Source:         sourceICS,
+				ExternalUid:    e.UID,
+				Summary:        e.Summary,
+			})
+			if err != nil {
+				return err
+			}
+			created = append(created, ex)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// Events converts bookings to calendar events
+func Events(bookings []postgres.Booking) []ics.Event {
+	events := make([]ics.Event, 0, len(bookings))
+	for _, b := range bookings {
+		events = append(events, ics.Event{
+			UID:         b.ID.String() + "@byrd.news",
+			Summary:     "Byrd booking (" + string(b.Status) + ")",
+			Description: b.Task,
+			Start:       time.Time(b.DateStart),
+			End:         time.Time(b.DateEnd),
+		})
+	}
+	return events
+}
+
+// NewFeedToken generates a secret token for the photographer's ICS feed, replacing any previous token.
+// Only its sha256 is stored, so the token can not be shown again.
+func NewFeedToken(ctx context.Context, q *postgres.Queries, photographerID string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	hash := sha256.Sum256([]byte(token))
+	if err := q.UpsertCalendarFeed(ctx, postgres.UpsertCalendarFeedParams{
+		PhotographerID: photographerID,
+		TokenHash:      hash[:],
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// VerifyFeedToken fails with ErrInvalidFeedToken unless token is the photographer's current feed token
+func VerifyFeedToken(ctx context.Context, q *postgres.Queries, photographerID, token string) error {
+	stored, err := q.GetCalendarFeedTokenHash(ctx, photographerID)
+	if err == sql.ErrNoRows {
+		return ErrInvalidFeedToken
+	}
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(token))
+	if token == "" || subtle.ConstantTimeCompare(stored, hash[:]) != 1 {
+		return ErrInvalidFeedToken
+	}
+	return nil
+}
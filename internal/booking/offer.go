@@ -0,0 +1,190 @@
+package booking
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+// DefaultOfferTTL is used when an offer is created without an expiry
+const DefaultOfferTTL = 24 * time.Hour
+
+// SystemActor is the actor of the transitions the API makes on its own, e.g. when offers expire
+const SystemActor = "system"
+
+var (
+	ErrNoPhotographers  = errors.New("a booking must be offered to at least one professional")
+	ErrOfferExpired     = errors.New("booking offer has expired")
+	ErrOfferUnavailable = errors.New("booking offer is no longer available")
+)
+
+// expireOffersTx marks the lapsed pending offers of an offered booking expired. A booking left without
+// a pending offer goes back to requested so it can be offered again, or expires when it should have
+// started already. The status of the booking afterwards is returned. q must be bound to a transaction
+// holding the lock of the booking.
+func expireOffersTx(ctx context.Context, q *postgres.Queries, b postgres.Booking) (postgres.BookingStatus, error) {
+	if b.Status != postgres.BookingStatusOffered {
+		return b.Status, nil
+	}
+	if _, err := q.ExpireBookingOffers(ctx, b.ID); err != nil {
+		return b.Status, err
+	}
+	pending, err := q.CountPendingBookingOffers(ctx, b.ID)
+	if err != nil || pending > 0 {
+		return b.Status, err
+	}
+	to := postgres.BookingStatusRequested
+	if !time.Now().Before(time.Time(b.DateStart)) {
+		to = postgres.BookingStatusExpired
+	}
+	if _, err := TransitionTx(ctx, q, b.ID, to, SystemActor, "offers expired"); err != nil {
+		return b.Status, err
+	}
+	return to, nil
+}
+
+// ListOffers returns the offers of a booking, expiring the lapsed ones first
+func ListOffers(ctx context.Context, db *sql.DB, bookingID uuid.UUID) ([]postgres.BookingOffer, error) {
+	var offers []postgres.BookingOffer
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		b, err := q.GetBookingForUpdate(ctx, bookingID)
+		if err != nil {
+			return err
+		}
+		if _, err := expireOffersTx(ctx, q, b); err != nil {
+			return err
+		}
+		offers, err = q.ListBookingOffers(ctx, bookingID)
+		return err
+	})
+	return offers, err
+}
+
+// Offer offers a requested booking to the given professionals.
+// A booking that is already offered can be offered to additional professionals, and offering
+// a professional again re-arms their expired or withdrawn offer.
+func Offer(ctx context.Context, db *sql.DB, bookingID uuid.UUID, photographerIDs []string, ttl time.Duration, actorID string) ([]postgres.BookingOffer, error) {
+	if len(photographerIDs) == 0 {
+		return nil, ErrNoPhotographers
+	}
+	if ttl <= 0 {
+		ttl = DefaultOfferTTL
+	}
+	expiresAt := time.Now().Add(ttl).UTC()
+
+	var offers []postgres.BookingOffer
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		b, err := q.GetBookingForUpdate(ctx, bookingID)
+		if err != nil {
+			return err
+		}
+		if b.Status, err = expireOffersTx(ctx, q, b); err != nil {
+			return err
+		}
+		switch b.Status {
+		case postgres.BookingStatusRequested:
+			if _, err := TransitionTx(ctx, q, bookingID, postgres.BookingStatusOffered, actorID, ""); err != nil {
+				return err
+			}
+		case postgres.BookingStatusOffered:
+		default:
+			return ErrInvalidTransition
+		}
+
+		for _, photographerID := range photographerIDs {
+			o, err := q.CreateBookingOffer(ctx, postgres.CreateBookingOfferParams{
+				BookingID:      bookingID,
+				PhotographerID: photographerID,
+				ExpiresAt:      expiresAt,
+			})
+			if err == sql.ErrNoRows {
+				// the professional accepted the booking already
+				return ErrOfferUnavailable
+			}
+			if err != nil {
+				return err
+			}
+			offers = append(offers, o)
+		}
+		return nil
+	})
+	return offers, err
+}
+
+// AcceptOffer lets a professional accept the booking offered to them.
+// The booking row is locked for the duration of the transaction, so concurrent acceptances
+// are serialized: the first one wins and every other pending offer is withdrawn.
+// Lapsed offers are expired on the way, an expired offer fails with ErrOfferExpired.
+// The booking is repriced by price at the level of the accepting professional.
+// It fails with ErrBookingConflict when the professional is busy during the booking, and with ErrUnavailable
+// when their calendar does not have them working for all of it.
+func AcceptOffer(ctx context.Context, db *sql.DB, bookingID uuid.UUID, photographerID string, price Pricer) (postgres.BookingTransition, error) {
+	var t postgres.BookingTransition
+	expired := false
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		b, err := q.GetBookingForUpdate(ctx, bookingID)
+		if err != nil {
+			return err
+		}
+		if _, err := expireOffersTx(ctx, q, b); err != nil {
+			return err
+		}
+		o, err := q.GetBookingOfferForUpdate(ctx, postgres.GetBookingOfferForUpdateParams{
+			BookingID:      bookingID,
+			PhotographerID: photographerID,
+		})
+		if err != nil {
+			return err
+		}
+		switch o.Status {
+		case postgres.OfferStatusPending:
+		case postgres.OfferStatusExpired:
+			// commit the expiry before failing
+			expired = true
+			return nil
+		default:
+			return ErrOfferUnavailable
+		}
+		if err := CheckAvailabilityTx(ctx, q, photographerID, bookingID, time.Time(b.DateStart), time.Time(b.DateEnd)); err != nil {
+			return err
+		}
+
+		b.PhotographerID = photographerID
+		quote, err := price(ctx, b, time.Time(b.DateStart), time.Time(b.DateEnd))
+		if err != nil {
+			return err
+		}
+
+		t, err = TransitionTx(ctx, q, bookingID, postgres.BookingStatusAccepted, photographerID, "")
+		if err != nil {
+			return err
+		}
+		if err := q.AcceptBooking(ctx, postgres.AcceptBookingParams{
+			ID:             bookingID,
+			PhotographerID: photographerID,
+			Price:          quote.Total,
+			PricingVersion: quote.Version,
+		}); err != nil {
+			return err
+		}
+		if err := q.UpdateBookingOfferStatus(ctx, postgres.UpdateBookingOfferStatusParams{
+			ID:     o.ID,
+			Status: postgres.OfferStatusAccepted,
+		}); err != nil {
+			return err
+		}
+		return q.WithdrawOtherBookingOffers(ctx, postgres.WithdrawOtherBookingOffersParams{
+			BookingID: bookingID,
+			ID:        o.ID,
+		})
+	})
+	if err == nil && expired {
+		err = ErrOfferExpired
+	}
+	return t, err
+}
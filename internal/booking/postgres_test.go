@@ -0,0 +1,126 @@
+package booking
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/byrdapp/timestamp/timeparser"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+
+	"github.com/byrdapp/byrd-pro-api/internal/credits"
+	"github.com/byrdapp/byrd-pro-api/internal/pricing"
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+// testDB opens the database of POSTGRES_TEST_URL, which must have internal/storage/sql/schema.sql loaded,
+// e.g. POSTGRES_TEST_URL=postgres://localhost:5432/byrd_test?sslmode=disable
+func testDB(t *testing.T) *sql.DB {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// testBooking grants credits to a new media and creates a booking of 4 credits
+func testBooking(t *testing.T, db *sql.DB) (mediaID string, bookingID uuid.UUID) {
+	ctx := context.Background()
+	mediaID = uuid.New().String()
+	if err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		_, err := credits.GrantTx(ctx, q, mediaID, 10, "admin", "test")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	bookingID, err := Create(ctx, db, postgres.CreateBookingParams{
+		MediaID:   mediaID,
+		Task:      "portrait",
+		Price:     400,
+		Credits:   4,
+		DateStart: timeparser.Timestamp(start),
+		DateEnd:   timeparser.Timestamp(start.Add(time.Hour)),
+		Lat:       55.67,
+		Lng:       12.56,
+	}, mediaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mediaID, bookingID
+}
+
+// photographerPrice prices bookings at 500 once they have a photographer
+func photographerPrice(ctx context.Context, b postgres.Booking, start, end time.Time) (*pricing.Quote, error) {
+	if b.PhotographerID == "" {
+		return &pricing.Quote{Version: "test", Total: 400}, nil
+	}
+	return &pricing.Quote{Version: "test", Total: 500}, nil
+}
+
+func TestCancelAfterCreate(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	mediaID, bookingID := testBooking(t, db)
+
+	if b, err := credits.GetBalance(ctx, db, mediaID); err != nil || b != (credits.Balance{Available: 6, Reserved: 4}) {
+		t.Fatalf("expected 6 available and 4 reserved got %+v %v", b, err)
+	}
+	tr, err := Cancel(ctx, db, bookingID, mediaID, "deleted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.FromStatus != postgres.BookingStatusRequested || tr.ToStatus != postgres.BookingStatusCancelled {
+		t.Errorf("expected requested -> cancelled got %s -> %s", tr.FromStatus, tr.ToStatus)
+	}
+	if b, err := credits.GetBalance(ctx, db, mediaID); err != nil || b != (credits.Balance{Available: 10}) {
+		t.Errorf("expected the reservation released got %+v %v", b, err)
+	}
+	// a cancelled booking can not be deleted again
+	if _, err := Cancel(ctx, db, bookingID, mediaID, "deleted"); err != ErrInvalidTransition {
+		t.Errorf("expected %v got %v", ErrInvalidTransition, err)
+	}
+}
+
+func TestOfferExpiry(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	mediaID, bookingID := testBooking(t, db)
+	photographerID := uuid.New().String()
+
+	if _, err := Offer(ctx, db, bookingID, []string{photographerID}, time.Nanosecond, mediaID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := AcceptOffer(ctx, db, bookingID, photographerID, photographerPrice); err != ErrOfferExpired {
+		t.Errorf("expected %v got %v", ErrOfferExpired, err)
+	}
+	// without a live offer the booking can be offered again
+	b, err := postgres.New(db).GetBooking(ctx, bookingID)
+	if err != nil || b.Status != postgres.BookingStatusRequested {
+		t.Fatalf("expected the booking requested again got %s %v", b.Status, err)
+	}
+	offers, err := Offer(ctx, db, bookingID, []string{photographerID}, 0, mediaID)
+	if err != nil || len(offers) != 1 || offers[0].Status != postgres.OfferStatusPending {
+		t.Fatalf("expected the offer re-armed got %+v %v", offers, err)
+	}
+	if _, err := AcceptOffer(ctx, db, bookingID, photographerID, photographerPrice); err != nil {
+		t.Fatal(err)
+	}
+	// the booking is repriced at the level of the accepting professional
+	if b, err := postgres.New(db).GetBooking(ctx, bookingID); err != nil || b.Price != 500 || b.PhotographerID != photographerID {
+		t.Errorf("expected the photographer's price got %d %q %v", b.Price, b.PhotographerID, err)
+	}
+}
@@ -0,0 +1,143 @@
+package booking
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/byrdapp/timestamp/timeparser"
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/policy"
+	"github.com/byrdapp/byrd-pro-api/internal/pricing"
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+	"github.com/byrdapp/byrd-pro-api/public/geo"
+)
+
+var (
+	ErrVersionConflict = errors.New("booking was changed since it was read, reload it and try again")
+	ErrNotEditable     = errors.New("booking can no longer be edited")
+	ErrEmptyPatch      = errors.New("no booking fields to update")
+	ErrEmptyTask       = errors.New("booking task can not be empty")
+	ErrDatesLocked     = errors.New("booking dates can not change once a photographer accepted it")
+)
+
+// Pricer quotes the booking for the dates, at the level of its photographer once one is set.
+// Its credits, and so their reservation, do not change.
+type Pricer func(ctx context.Context, b postgres.Booking, start, end time.Time) (*pricing.Quote, error)
+
+// Patch is a partial update of a booking. Fields left nil are not changed.
+// Version must be the version the client last read.
+type Patch struct {
+	Version   int32                 `json:"version"`
+	Task      *string               `json:"task,omitempty"`
+	DateStart *timeparser.Timestamp `json:"date_start,omitempty"`
+	DateEnd   *timeparser.Timestamp `json:"date_end,omitempty"`
+	Lat       *float64              `json:"lat,omitempty"`
+	Lng       *float64              `json:"lng,omitempty"`
+}
+
+// IsEditable is true while the booking has not started
+func IsEditable(status postgres.BookingStatus) bool {
+	switch status {
+	case postgres.BookingStatusRequested, postgres.BookingStatusOffered, postgres.BookingStatusAccepted:
+		return true
+	}
+	return false
+}
+
+// apply validates the patch and merges it with the current booking
+func (p Patch) apply(b postgres.Booking) (postgres.UpdateBookingParams, error) {
+	if p.Task == nil && p.DateStart == nil && p.DateEnd == nil && p.Lat == nil && p.Lng == nil {
+		return postgres.UpdateBookingParams{}, ErrEmptyPatch
+	}
+	arg := postgres.UpdateBookingParams{
+		ID:        b.ID,
+		Version:   p.Version,
+		Task:      b.Task,
+		DateStart: time.Time(b.DateStart),
+		DateEnd:   time.Time(b.DateEnd),
+		Lat:       b.Lat,
+		Lng:       b.Lng,
+		// repriced by Update when the dates change
+		Price:          b.Price,
+		PricingVersion: b.PricingVersion,
+	}
+	if p.Task != nil {
+		arg.Task = strings.TrimSpace(*p.Task)
+		if arg.Task == "" {
+			return arg, ErrEmptyTask
+		}
+	}
+	if p.DateStart != nil {
+		arg.DateStart = time.Time(*p.DateStart)
+	}
+	if p.DateEnd != nil {
+		arg.DateEnd = time.Time(*p.DateEnd)
+	}
+	if !arg.DateEnd.After(arg.DateStart) {
+		return arg, ErrInvalidInterval
+	}
+	// the photographer agreed to the dates they accepted
+	if b.PhotographerID != "" && datesChanged(b, arg) {
+		return arg, ErrDatesLocked
+	}
+	if p.Lat != nil {
+		arg.Lat = *p.Lat
+	}
+	if p.Lng != nil {
+		arg.Lng = *p.Lng
+	}
+	if err := (geo.Point{Lat: arg.Lat, Lng: arg.Lng}).Validate(); err != nil {
+		return arg, err
+	}
+	return arg, nil
+}
+
+// Update applies the patch to the booking. Only the media owning the booking, or an admin, may edit it
+// (see policy.CanManageBooking) and only until it is in progress. It fails with ErrVersionConflict when the booking was changed after
+// the client read patch.Version, and with ErrDatesLocked when new dates are set after a photographer accepted it.
+// New dates are priced by price in the same transaction.
+func Update(ctx context.Context, db *sql.DB, bookingID uuid.UUID, patch Patch, actor policy.Identity, price Pricer) (postgres.Booking, error) {
+	var updated postgres.Booking
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		b, err := q.GetBookingForUpdate(ctx, bookingID)
+		if err != nil {
+			return err
+		}
+		if err := policy.CanManageBooking(actor, b); err != nil {
+			return err
+		}
+		if b.Version != patch.Version {
+			return ErrVersionConflict
+		}
+		if !IsEditable(b.Status) {
+			return ErrNotEditable
+		}
+		arg, err := patch.apply(b)
+		if err != nil {
+			return err
+		}
+		if datesChanged(b, arg) {
+			quote, err := price(ctx, b, arg.DateStart, arg.DateEnd)
+			if err != nil {
+				return err
+			}
+			arg.Price = quote.Total
+			arg.PricingVersion = quote.Version
+		}
+
+		updated, err = q.UpdateBooking(ctx, arg)
+		if err == sql.ErrNoRows {
+			return ErrVersionConflict
+		}
+		return err
+	})
+	return updated, err
+}
+
+func datesChanged(b postgres.Booking, arg postgres.UpdateBookingParams) bool {
+	return !arg.DateStart.Equal(time.Time(b.DateStart)) || !arg.DateEnd.Equal(time.Time(b.DateEnd))
+}
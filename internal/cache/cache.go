@@ -0,0 +1,197 @@
+// Package cache is a key value cache with expiring keys, modelled on the redis
+// commands SET, GET, EXISTS, INCR, MSET, APPEND and DEL. Memory keeps the keys of
+// one instance; the Redis cache in internal/storage/redis shares them between instances.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrMiss is returned by Get when the key does not exist or has expired
+	ErrMiss = errors.New("cache: key not found")
+	// ErrNotInteger is returned by Incr when the value of the key is not an integer
+	ErrNotInteger = errors.New("cache: value is not an integer")
+)
+
+// Cache stores values by key. A ttl of zero keeps the key until it is deleted.
+type Cache interface {
+	// Get returns the value of key or ErrMiss
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key, replacing the value and ttl of an existing key
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Exists reports whether key is stored
+	Exists(ctx context.Context, key string) (bool, error)
+	// Incr adds delta to the integer value of key, starting from 0, and keeps its ttl
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
+	// MSet stores all values with the same ttl
+	MSet(ctx context.Context, values map[string][]byte, ttl time.Duration) error
+	// Append adds value to the end of key, keeps its ttl and returns the new length
+	Append(ctx context.Context, key string, value []byte) (int64, error)
+	// Del deletes keys and returns how many existed
+	Del(ctx context.Context, keys ...string) (int64, error)
+}
+
+// GetJSON decodes the value of key into v
+func GetJSON(ctx context.Context, c Cache, key string, v interface{}) error {
+	b, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// SetJSON stores v encoded as json
+func SetJSON(ctx context.Context, c Cache, key string, v interface{}, ttl time.Duration) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, b, ttl)
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// Memory is a Cache in the memory of this instance
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	now     func() time.Time
+	writes  int
+}
+
+var _ Cache = (*Memory)(nil)
+
+// every sweepEvery writes the expired keys are dropped, reads skip them before that
+const sweepEvery = 1000
+
+// NewMemory creates an empty in-memory cache
+func NewMemory() *Memory {
+	return &Memory{
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
+
+// get returns the live entry of key, the caller holds mu
+func (m *Memory) get(key string) (entry, bool) {
+	e, ok := m.entries[key]
+	if ok && e.expired(m.now()) {
+		delete(m.entries, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+// set stores the entry, the caller holds mu
+func (m *Memory) set(key string, e entry) {
+	m.entries[key] = e
+	if m.writes++; m.writes >= sweepEvery {
+		m.writes = 0
+		now := m.now()
+		for k, e := range m.entries {
+			if e.expired(now) {
+				delete(m.entries, k)
+			}
+		}
+	}
+}
+
+func (m *Memory) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return m.now().Add(ttl)
+}
+
+// Get returns a copy of the value of key
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(key)
+	if !ok {
+		return nil, ErrMiss
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+// Set stores a copy of value
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(key, entry{value: append([]byte(nil), value...), expires: m.expiry(ttl)})
+	return nil
+}
+
+// Exists reports whether key is stored and not expired
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.get(key)
+	return ok, nil
+}
+
+// Incr adds delta to the integer value of key
+func (m *Memory) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(key)
+	var n int64
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(string(e.value), 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	n += delta
+	e.value = []byte(strconv.FormatInt(n, 10))
+	m.set(key, e)
+	return n, nil
+}
+
+// MSet stores all values with the same ttl
+func (m *Memory) MSet(ctx context.Context, values map[string][]byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expires := m.expiry(ttl)
+	for key, value := range values {
+		m.set(key, entry{value: append([]byte(nil), value...), expires: expires})
+	}
+	return nil
+}
+
+// Append adds value to the end of key
+func (m *Memory) Append(ctx context.Context, key string, value []byte) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, _ := m.get(key)
+	e.value = append(append([]byte(nil), e.value...), value...)
+	m.set(key, e)
+	return int64(len(e.value)), nil
+}
+
+// Del deletes keys
+func (m *Memory) Del(ctx context.Context, keys ...string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if _, ok := m.get(key); ok {
+			delete(m.entries, key)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := m.Get(ctx, "missing"); err != ErrMiss {
+		t.Errorf("expected %v got %v", ErrMiss, err)
+	}
+	_ = m.Set(ctx, "profile", []byte("simon"), time.Minute)
+	_ = m.Set(ctx, "forever", []byte("1"), 0)
+	if b, err := m.Get(ctx, "profile"); err != nil || string(b) != "simon" {
+		t.Errorf("expected simon got %s %v", b, err)
+	}
+
+	if n, _ := m.Incr(ctx, "forever", 41); n != 42 {
+		t.Errorf("expected 42 got %d", n)
+	}
+	if n, _ := m.Incr(ctx, "counter", -1); n != -1 {
+		t.Errorf("expected a missing key to start at 0, got %d", n)
+	}
+	if _, err := m.Incr(ctx, "profile", 1); err != ErrNotInteger {
+		t.Errorf("expected %v got %v", ErrNotInteger, err)
+	}
+	if n, _ := m.Append(ctx, "profile", []byte(" says hi")); n != 13 {
+		t.Errorf("expected length 13 got %d", n)
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := m.Exists(ctx, "profile"); ok {
+		t.Error("key was kept after its ttl, appending must not extend it")
+	}
+	if ok, _ := m.Exists(ctx, "forever"); !ok {
+		t.Error("key without ttl expired")
+	}
+
+	_ = m.MSet(ctx, map[string][]byte{"a": []byte("1"), "b": []byte("2")}, time.Second)
+	if n, _ := m.Del(ctx, "a", "b", "c"); n != 2 {
+		t.Errorf("expected 2 deleted keys got %d", n)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	type profile struct {
+		Name string `json:"name"`
+	}
+	if err := SetJSON(ctx, m, "p", profile{"simon"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var p profile
+	if err := GetJSON(ctx, m, "p", &p); err != nil || p.Name != "simon" {
+		t.Errorf("expected simon got %+v %v", p, err)
+	}
+	if err := GetJSON(ctx, m, "missing", &p); err != ErrMiss {
+		t.Errorf("expected %v got %v", ErrMiss, err)
+	}
+}
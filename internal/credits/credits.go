@@ -0,0 +1,205 @@
+package credits
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+// SystemOwner owns the grants and revenue accounts on the other side of media postings
+const SystemOwner = "system"
+
+var (
+	ErrInvalidAmount       = errors.New("credit amount must be positive")
+	ErrInsufficientCredits = errors.New("not enough credits available for the booking")
+	ErrNoReservation       = errors.New("no credits are reserved for the booking")
+	ErrAlreadySettled      = errors.New("reserved credits for the booking are already captured or released")
+	ErrUnbalanced          = errors.New("credit entries must sum to zero")
+)
+
+// Balance of a media's credit accounts
+type Balance struct {
+	Available int64 `json:"available"`
+	Reserved  int64 `json:"reserved"`
+}
+
+type posting struct {
+	account postgres.CreditAccount
+	amount  int64
+}
+
+// balanced reports whether the postings of one transaction sum to zero
+func balanced(postings []posting) bool {
+	var sum int64
+	for _, p := range postings {
+		if p.amount == 0 {
+			return false
+		}
+		sum += p.amount
+	}
+	return len(postings) > 1 && sum == 0
+}
+
+// account returns the owner's account of the given kind, creating it when missing.
+// The account row stays locked until the surrounding transaction ends.
+func account(ctx context.Context, q *postgres.Queries, ownerID string, kind postgres.CreditAccountKind) (postgres.CreditAccount, error) {
+	return q.UpsertCreditAccount(ctx, postgres.UpsertCreditAccountParams{
+		OwnerID: ownerID,
+		Kind:    kind,
+	})
+}
+
+func post(ctx context.Context, q *postgres.Queries, arg postgres.CreateCreditTransactionParams, postings ...posting) (postgres.CreditTransaction, error) {
+	if !balanced(postings) {
+		return postgres.CreditTransaction{}, ErrUnbalanced
+	}
+	t, err := q.CreateCreditTransaction(ctx, arg)
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	for _, p := range postings {
+		if err := q.CreateCreditEntry(ctx, postgres.CreateCreditEntryParams{
+			TransactionID: t.ID,
+			AccountID:     p.account.ID,
+			Amount:        p.amount,
+		}); err != nil {
+			return postgres.CreditTransaction{}, err
+		}
+	}
+	return t, nil
+}
+
+// GrantTx issues credits to a media, e.g. when a subscription renews or credits are bought
+func GrantTx(ctx context.Context, q *postgres.Queries, mediaID string, amount int64, actorID, memo string) (postgres.CreditTransaction, error) {
+	if amount <= 0 {
+		return postgres.CreditTransaction{}, ErrInvalidAmount
+	}
+	available, err := account(ctx, q, mediaID, postgres.CreditAccountKindAvailable)
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	grants, err := account(ctx, q, SystemOwner, postgres.CreditAccountKindGrants)
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	return post(ctx, q, postgres.CreateCreditTransactionParams{
+		Kind:    postgres.CreditTransactionKindGrant,
+		Amount:  amount,
+		ActorID: actorID,
+		Memo:    memo,
+	}, posting{grants, -amount}, posting{available, amount})
+}
+
+// ReserveTx holds credits for a new booking. It fails with ErrInsufficientCredits
+// when the media's available balance does not cover the amount.
+func ReserveTx(ctx context.Context, q *postgres.Queries, mediaID string, bookingID uuid.UUID, amount int64, actorID string) (postgres.CreditTransaction, error) {
+	if amount <= 0 {
+		return postgres.CreditTransaction{}, ErrInvalidAmount
+	}
+	available, err := account(ctx, q, mediaID, postgres.CreditAccountKindAvailable)
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	// the lock serializes reservations of the media, two bookings can not both spend the same balance
+	if _, err := q.GetCreditAccountForUpdate(ctx, available.ID); err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	balance, err := q.GetCreditAccountBalance(ctx, available.ID)
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	if balance < amount {
+		return postgres.CreditTransaction{}, ErrInsufficientCredits
+	}
+	reserved, err := account(ctx, q, mediaID, postgres.CreditAccountKindReserved)
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	return post(ctx, q, postgres.CreateCreditTransactionParams{
+		Kind:      postgres.CreditTransactionKindReserve,
+		Amount:    amount,
+		BookingID: uuid.NullUUID{UUID: bookingID, Valid: true},
+		ActorID:   actorID,
+	}, posting{available, -amount}, posting{reserved, amount})
+}
+
+// CaptureTx moves the booking's reserved credits to revenue when the booking completes
+func CaptureTx(ctx context.Context, q *postgres.Queries, mediaID string, bookingID uuid.UUID, actorID string) (postgres.CreditTransaction, error) {
+	return settle(ctx, q, mediaID, bookingID, actorID, postgres.CreditTransactionKindCapture)
+}
+
+// ReleaseTx returns the booking's reserved credits to the media when the booking is cancelled or expires
+func ReleaseTx(ctx context.Context, q *postgres.Queries, mediaID string, bookingID uuid.UUID, actorID string) (postgres.CreditTransaction, error) {
+	return settle(ctx, q, mediaID, bookingID, actorID, postgres.CreditTransactionKindRelease)
+}
+
+func settle(ctx context.Context, q *postgres.Queries, mediaID string, bookingID uuid.UUID, actorID string, kind postgres.CreditTransactionKind) (postgres.CreditTransaction, error) {
+	id := uuid.NullUUID{UUID: bookingID, Valid: true}
+	reserved, err := account(ctx, q, mediaID, postgres.CreditAccountKindReserved)
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	reservation, err := q.GetCreditTransactionByBooking(ctx, postgres.GetCreditTransactionByBookingParams{
+		BookingID: id,
+		Kind:      postgres.CreditTransactionKindReserve,
+	})
+	if err == sql.ErrNoRows {
+		return postgres.CreditTransaction{}, ErrNoReservation
+	}
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	for _, k := range []postgres.CreditTransactionKind{postgres.CreditTransactionKindCapture, postgres.CreditTransactionKindRelease} {
+		_, err := q.GetCreditTransactionByBooking(ctx, postgres.GetCreditTransactionByBookingParams{
+			BookingID: id,
+			Kind:      k,
+		})
+		if err == nil {
+			return postgres.CreditTransaction{}, ErrAlreadySettled
+		}
+		if err != sql.ErrNoRows {
+			return postgres.CreditTransaction{}, err
+		}
+	}
+
+	var to postgres.CreditAccount
+	if kind == postgres.CreditTransactionKindCapture {
+		to, err = account(ctx, q, SystemOwner, postgres.CreditAccountKindRevenue)
+	} else {
+		to, err = account(ctx, q, mediaID, postgres.CreditAccountKindAvailable)
+	}
+	if err != nil {
+		return postgres.CreditTransaction{}, err
+	}
+	return post(ctx, q, postgres.CreateCreditTransactionParams{
+		Kind:      kind,
+		Amount:    reservation.Amount,
+		BookingID: id,
+		ActorID:   actorID,
+	}, posting{reserved, -reservation.Amount}, posting{to, reservation.Amount})
+}
+
+// GetBalance returns the available and reserved credits of a media
+func GetBalance(ctx context.Context, db *sql.DB, mediaID string) (Balance, error) {
+	var b Balance
+	err := postgres.ExecTx(ctx, db, func(q *postgres.Queries) error {
+		available, err := account(ctx, q, mediaID, postgres.CreditAccountKindAvailable)
+		if err != nil {
+			return err
+		}
+		reserved, err := account(ctx, q, mediaID, postgres.CreditAccountKindReserved)
+		if err != nil {
+			return err
+		}
+		if b.Available, err = q.GetCreditAccountBalance(ctx, available.ID); err != nil {
+			return err
+		}
+		b.Reserved, err = q.GetCreditAccountBalance(ctx, reserved.ID)
+		return err
+	})
+	return b, err
+}
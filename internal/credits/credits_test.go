@@ -0,0 +1,36 @@
+package credits
+
+import (
+	"context"
+	"testing"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+func TestBalanced(t *testing.T) {
+	var a, b postgres.CreditAccount
+	tests := []struct {
+		name     string
+		postings []posting
+		expected bool
+	}{
+		{"balanced", []posting{{a, -10}, {b, 10}}, true},
+		{"unbalanced", []posting{{a, -10}, {b, 9}}, false},
+		{"single leg", []posting{{a, 0}}, false},
+		{"zero entry", []posting{{a, 0}, {b, 0}}, false},
+		{"empty", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := balanced(test.postings); got != test.expected {
+				t.Errorf("expected %v got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestInvalidAmount(t *testing.T) {
+	if _, err := GrantTx(context.Background(), nil, "media", 0, "admin", ""); err != ErrInvalidAmount {
+		t.Errorf("expected %v got %v", ErrInvalidAmount, err)
+	}
+}
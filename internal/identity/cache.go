@@ -0,0 +1,129 @@
+// Package identity caches verified callers so a token is not verified against the
+// identity provider, and its roles looked up, on every request.
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/byrdapp/byrd-pro-api/internal/policy"
+)
+
+const (
+	// DefaultRecheck is how long a verified token is trusted before it is verified again,
+	// which bounds how long a revoked token or a changed role goes unnoticed
+	DefaultRecheck = time.Minute
+	// DefaultMaxEntries bounds the memory used by the cache
+	DefaultMaxEntries = 10000
+)
+
+// VerifyFunc verifies a token and resolves the caller's identity.
+// It returns when the token expires.
+type VerifyFunc func(ctx context.Context) (policy.Identity, time.Time, error)
+
+type entry struct {
+	id        policy.Identity
+	expiresAt time.Time
+	checkedAt time.Time
+}
+
+// Cache maps the sha256 of a token to the identity it was verified as.
+// Tokens themselves are never stored.
+type Cache struct {
+	recheck    time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]entry
+}
+
+// NewCache creates a cache that verifies tokens again after recheck
+func NewCache(recheck time.Duration, maxEntries int) *Cache {
+	if recheck <= 0 {
+		recheck = DefaultRecheck
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Cache{
+		recheck:    recheck,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[[sha256.Size]byte]entry),
+	}
+}
+
+func hash(token string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(token))
+}
+
+// Get returns the cached identity of token, calling verify when the token is unknown
+// or was last verified more than the recheck interval ago. Failed verifications are not cached.
+func (c *Cache) Get(ctx context.Context, token string, verify VerifyFunc) (policy.Identity, error) {
+	key := hash(token)
+	now := c.now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expiresAt) && now.Sub(e.checkedAt) < c.recheck {
+		return e.id, nil
+	}
+
+	id, expiresAt, err := verify(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil || !now.Before(expiresAt) {
+		delete(c.entries, key)
+		return id, err
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = entry{id: id, expiresAt: expiresAt, checkedAt: now}
+	return id, nil
+}
+
+// evict drops expired entries, and an arbitrary one when none have expired. c.mu must be held.
+func (c *Cache) evict(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+	for k := range c.entries {
+		delete(c.entries, k)
+		return
+	}
+}
+
+// Invalidate forgets token, e.g. when the user logs off
+func (c *Cache) Invalidate(token string) {
+	c.mu.Lock()
+	delete(c.entries, hash(token))
+	c.mu.Unlock()
+}
+
+// InvalidateUID forgets every token of uid, e.g. after their roles changed
+func (c *Cache) InvalidateUID(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if e.id.UID == uid {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Len is the number of cached tokens
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
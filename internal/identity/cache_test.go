@@ -0,0 +1,93 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/byrdapp/byrd-pro-api/internal/policy"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestCache(recheck time.Duration, max int) (*Cache, *clock) {
+	c := NewCache(recheck, max)
+	clk := &clock{t: time.Date(2020, time.April, 15, 10, 0, 0, 0, time.UTC)}
+	c.now = clk.now
+	return c, clk
+}
+
+func counting(calls *int, uid string, expiresAt time.Time, err error) VerifyFunc {
+	return func(ctx context.Context) (policy.Identity, time.Time, error) {
+		*calls++
+		return policy.Identity{UID: uid}, expiresAt, err
+	}
+}
+
+func TestCacheRecheck(t *testing.T) {
+	c, clk := newTestCache(time.Minute, 10)
+	ctx := context.Background()
+	var calls int
+	verify := counting(&calls, "pro", clk.t.Add(time.Hour), nil)
+
+	for i := 0; i < 3; i++ {
+		if id, err := c.Get(ctx, "token", verify); err != nil || id.UID != "pro" {
+			t.Fatalf("unexpected %v %v", id, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 verification got %d", calls)
+	}
+
+	clk.t = clk.t.Add(time.Minute)
+	c.Get(ctx, "token", verify)
+	if calls != 2 {
+		t.Errorf("expected a recheck after the interval, got %d verifications", calls)
+	}
+
+	revoked := errors.New("revoked")
+	clk.t = clk.t.Add(time.Minute)
+	if _, err := c.Get(ctx, "token", counting(&calls, "pro", clk.t.Add(time.Hour), revoked)); err != revoked {
+		t.Errorf("expected %v got %v", revoked, err)
+	}
+	if c.Len() != 0 {
+		t.Error("a token failing verification must be forgotten")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c, clk := newTestCache(time.Hour, 10)
+	ctx := context.Background()
+	var calls int
+	c.Get(ctx, "token", counting(&calls, "pro", clk.t.Add(time.Minute), nil))
+	clk.t = clk.t.Add(2 * time.Minute)
+	c.Get(ctx, "token", counting(&calls, "pro", clk.t.Add(-time.Second), nil))
+	if calls != 2 {
+		t.Errorf("expired token must be verified again, got %d verifications", calls)
+	}
+	if c.Len() != 0 {
+		t.Error("an expired token must not be cached")
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	c, clk := newTestCache(time.Hour, 2)
+	ctx := context.Background()
+	var calls int
+	exp := clk.t.Add(time.Hour)
+	c.Get(ctx, "a", counting(&calls, "media", exp, nil))
+	c.Get(ctx, "b", counting(&calls, "media", exp, nil))
+	c.Get(ctx, "c", counting(&calls, "pro", exp, nil))
+	if c.Len() != 2 {
+		t.Errorf("expected the cache to stay at 2 entries, got %d", c.Len())
+	}
+
+	c.Invalidate("c")
+	c.InvalidateUID("media")
+	if c.Len() != 0 {
+		t.Errorf("expected an empty cache, got %d", c.Len())
+	}
+}
@@ -0,0 +1,200 @@
+// Package policy decides what a verified caller may do with a resource.
+// Handlers resolve the caller's Identity once and ask the Can* functions before
+// reading or changing anything; a denied request gets ErrForbidden.
+package policy
+
+import (
+	"errors"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+// Role is what a user is allowed to act as
+type Role string
+
+const (
+	RoleMedia        Role = "media"
+	RoleProfessional Role = "professional"
+	RoleAdmin        Role = "admin"
+)
+
+var ErrForbidden = errors.New("you are not allowed to access this resource")
+
+// Identity is the verified caller of a request
+type Identity struct {
+	UID   string `json:"uid"`
+	Roles []Role `json:"roles"`
+}
+
+// Has reports whether the identity holds role
+func (i Identity) Has(role Role) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAny reports whether the identity holds at least one of roles
+func (i Identity) HasAny(roles ...Role) bool {
+	for _, role := range roles {
+		if i.Has(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin is shorthand for Has(RoleAdmin)
+func (i Identity) IsAdmin() bool {
+	return i.Has(RoleAdmin)
+}
+
+// ParseRole returns the role named s
+func ParseRole(s string) (Role, bool) {
+	switch r := Role(s); r {
+	case RoleMedia, RoleProfessional, RoleAdmin:
+		return r, true
+	}
+	return "", false
+}
+
+// FromClaims is the identity of uid holding the roles of its token claims
+func FromClaims(uid string, rc storage.RoleClaims) Identity {
+	id := Identity{UID: uid}
+	if rc.Media {
+		id.Roles = append(id.Roles, RoleMedia)
+	}
+	if rc.Professional {
+		id.Roles = append(id.Roles, RoleProfessional)
+	}
+	if rc.Admin {
+		id.Roles = append(id.Roles, RoleAdmin)
+	}
+	return id
+}
+
+// Claims are the role claims holding the roles of the identity, the reverse of FromClaims
+func (i Identity) Claims() storage.RoleClaims {
+	return storage.RoleClaims{
+		Admin:        i.Has(RoleAdmin),
+		Professional: i.Has(RoleProfessional),
+		Media:        i.Has(RoleMedia),
+	}
+}
+
+// SetRole returns rc with role granted or revoked
+func SetRole(rc storage.RoleClaims, role Role, granted bool) storage.RoleClaims {
+	switch role {
+	case RoleMedia:
+		rc.Media = granted
+	case RoleProfessional:
+		rc.Professional = granted
+	case RoleAdmin:
+		rc.Admin = granted
+	}
+	return rc
+}
+
+// CanChangeRole lets admins grant and revoke roles, except revoking their own admin role
+func CanChangeRole(id Identity, uid string, role Role, granted bool) error {
+	return allow(id.IsAdmin() && !(uid == id.UID && role == RoleAdmin && !granted))
+}
+
+func allow(ok bool) error {
+	if ok {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// CanViewBooking allows admins, the owning media and the assigned photographer
+func CanViewBooking(id Identity, b postgres.Booking) error {
+	return allow(id.IsAdmin() || b.MediaID == id.UID || (b.PhotographerID != "" && b.PhotographerID == id.UID))
+}
+
+// CanManageBooking allows admins and the owning media to edit, offer or delete a booking
+func CanManageBooking(id Identity, b postgres.Booking) error {
+	return allow(id.IsAdmin() || b.MediaID == id.UID)
+}
+
+// CanCancelBooking allows admins and the owning media to delete a booking, which cancels it.
+// A disputed booking is only cancelled by an admin resolving the dispute.
+func CanCancelBooking(id Identity, b postgres.Booking) error {
+	if err := CanManageBooking(id, b); err != nil {
+		return err
+	}
+	return CanTransitionBooking(id, b, postgres.BookingStatusCancelled)
+}
+
+// mediaTransitions and photographerTransitions are the statuses each party may move a booking to.
+// Admins may make any transition; whether the transition itself is valid is decided by the booking package.
+var (
+	mediaTransitions = []postgres.BookingStatus{
+		postgres.BookingStatusCancelled,
+		postgres.BookingStatusCompleted,
+		postgres.BookingStatusDisputed,
+	}
+	photographerTransitions = []postgres.BookingStatus{
+		postgres.BookingStatusInProgress,
+		postgres.BookingStatusDelivered,
+		postgres.BookingStatusCancelled,
+		postgres.BookingStatusDisputed,
+	}
+)
+
+// CanTransitionBooking allows the owning media and the assigned photographer to make their own
+// part of the booking lifecycle, and admins to make any transition. Only admins resolve a dispute,
+// so a disputed booking can not be cancelled or completed by either party.
+func CanTransitionBooking(id Identity, b postgres.Booking, to postgres.BookingStatus) error {
+	if id.IsAdmin() {
+		return nil
+	}
+	if b.Status == postgres.BookingStatusDisputed {
+		return ErrForbidden
+	}
+	var allowed []postgres.BookingStatus
+	switch {
+	case b.MediaID == id.UID:
+		allowed = mediaTransitions
+	case b.PhotographerID != "" && b.PhotographerID == id.UID:
+		allowed = photographerTransitions
+	}
+	for _, status := range allowed {
+		if status == to {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// CanListMediaBookings allows a media to list its own bookings and admins to list anyone's
+func CanListMediaBookings(id Identity, mediaID string) error {
+	return allow(id.IsAdmin() || mediaID == id.UID)
+}
+
+// CanListNearbyBookings allows professionals to search the open bookings, requested or offered, of every
+// media so they can find work, and admins to search bookings in any status
+func CanListNearbyBookings(id Identity, status postgres.BookingStatus) error {
+	open := status == postgres.BookingStatusRequested || status == postgres.BookingStatusOffered
+	return allow(id.IsAdmin() || open && id.Has(RoleProfessional))
+}
+
+// CanViewProfile allows users to read their own profile and admins to read any profile.
+// Professional profiles are visible to every signed in user so they can be booked.
+func CanViewProfile(id Identity, profileUID string, isProfessional bool) error {
+	return allow(id.IsAdmin() || profileUID == id.UID || isProfessional)
+}
+
+// CanViewPrivateProfile allows users and admins to read the contact, device and financial
+// fields of a profile, everyone else gets its public view
+func CanViewPrivateProfile(id Identity, profileUID string) error {
+	return allow(id.IsAdmin() || profileUID == id.UID)
+}
+
+// CanListProfiles allows every signed in user to list the profiles they may view
+func CanListProfiles(id Identity) error {
+	return allow(id.UID != "")
+}
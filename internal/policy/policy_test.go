@@ -0,0 +1,186 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+)
+
+var (
+	media        = Identity{UID: "media", Roles: []Role{RoleMedia}}
+	photographer = Identity{UID: "pro", Roles: []Role{RoleProfessional}}
+	stranger     = Identity{UID: "other", Roles: []Role{RoleProfessional}}
+	admin        = Identity{UID: "admin", Roles: []Role{RoleAdmin}}
+	booking      = postgres.Booking{MediaID: "media", PhotographerID: "pro"}
+)
+
+func TestBookingAccess(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           Identity
+		view, manage bool
+	}{
+		{"owner", media, true, true},
+		{"photographer", photographer, true, false},
+		{"stranger", stranger, false, false},
+		{"admin", admin, true, true},
+	}
+	for _, test := range tests {
+		if got := CanViewBooking(test.id, booking) == nil; got != test.view {
+			t.Errorf("%s view: expected %v got %v", test.name, test.view, got)
+		}
+		if got := CanManageBooking(test.id, booking) == nil; got != test.manage {
+			t.Errorf("%s manage: expected %v got %v", test.name, test.manage, got)
+		}
+	}
+
+	unassigned := postgres.Booking{MediaID: "media"}
+	if err := CanViewBooking(Identity{}, unassigned); err != ErrForbidden {
+		t.Errorf("empty identity must not match an unassigned photographer, got %v", err)
+	}
+}
+
+func TestCanTransitionBooking(t *testing.T) {
+	tests := []struct {
+		id      Identity
+		to      postgres.BookingStatus
+		allowed bool
+	}{
+		{media, postgres.BookingStatusCompleted, true},
+		{media, postgres.BookingStatusDelivered, false},
+		{photographer, postgres.BookingStatusDelivered, true},
+		{photographer, postgres.BookingStatusCompleted, false},
+		{stranger, postgres.BookingStatusCancelled, false},
+		{admin, postgres.BookingStatusExpired, true},
+	}
+	for _, test := range tests {
+		if got := CanTransitionBooking(test.id, booking, test.to) == nil; got != test.allowed {
+			t.Errorf("%s -> %s: expected %v got %v", test.id.UID, test.to, test.allowed, got)
+		}
+	}
+
+	// delivered -> disputed -> cancelled would release the credits of a delivered booking
+	delivered := booking
+	delivered.Status = postgres.BookingStatusDelivered
+	if err := CanTransitionBooking(media, delivered, postgres.BookingStatusDisputed); err != nil {
+		t.Errorf("media should dispute a delivered booking, got %v", err)
+	}
+	disputed := booking
+	disputed.Status = postgres.BookingStatusDisputed
+	for _, to := range []postgres.BookingStatus{postgres.BookingStatusCancelled, postgres.BookingStatusCompleted} {
+		for _, id := range []Identity{media, photographer} {
+			if err := CanTransitionBooking(id, disputed, to); err != ErrForbidden {
+				t.Errorf("%s must not move a disputed booking to %s, got %v", id.UID, to, err)
+			}
+		}
+		if err := CanTransitionBooking(admin, disputed, to); err != nil {
+			t.Errorf("admin should resolve a dispute to %s, got %v", to, err)
+		}
+	}
+}
+
+func TestCanCancelBooking(t *testing.T) {
+	disputed := booking
+	disputed.Status = postgres.BookingStatusDisputed
+	tests := []struct {
+		name    string
+		id      Identity
+		b       postgres.Booking
+		allowed bool
+	}{
+		{"owner", media, booking, true},
+		{"photographer", photographer, booking, false},
+		{"stranger", stranger, booking, false},
+		{"admin", admin, booking, true},
+		// deleting a disputed booking would release its credits past the dispute
+		{"owner of disputed", media, disputed, false},
+		{"admin of disputed", admin, disputed, true},
+	}
+	for _, test := range tests {
+		if got := CanCancelBooking(test.id, test.b) == nil; got != test.allowed {
+			t.Errorf("%s: expected %v got %v", test.name, test.allowed, got)
+		}
+	}
+}
+
+func TestCanListNearbyBookings(t *testing.T) {
+	tests := []struct {
+		id      Identity
+		status  postgres.BookingStatus
+		allowed bool
+	}{
+		{photographer, postgres.BookingStatusRequested, true},
+		{photographer, postgres.BookingStatusOffered, true},
+		{photographer, postgres.BookingStatusAccepted, false},
+		{photographer, postgres.BookingStatusCompleted, false},
+		{media, postgres.BookingStatusRequested, false},
+		{admin, postgres.BookingStatusCompleted, true},
+	}
+	for _, test := range tests {
+		if got := CanListNearbyBookings(test.id, test.status) == nil; got != test.allowed {
+			t.Errorf("%s %s: expected %v got %v", test.id.UID, test.status, test.allowed, got)
+		}
+	}
+}
+
+func TestCanViewProfile(t *testing.T) {
+	if err := CanViewProfile(stranger, "media", false); err != ErrForbidden {
+		t.Errorf("expected %v got %v", ErrForbidden, err)
+	}
+	if err := CanViewProfile(media, "pro", true); err != nil {
+		t.Errorf("professional profiles are public, got %v", err)
+	}
+	if err := CanViewProfile(media, "media", false); err != nil {
+		t.Errorf("own profile must be visible, got %v", err)
+	}
+	if err := CanViewPrivateProfile(media, "pro"); err != ErrForbidden {
+		t.Errorf("expected %v got %v", ErrForbidden, err)
+	}
+	if err := CanViewPrivateProfile(admin, "pro"); err != nil {
+		t.Errorf("admins read every profile, got %v", err)
+	}
+}
+
+func TestHasAny(t *testing.T) {
+	if !media.HasAny(RoleProfessional, RoleMedia) {
+		t.Error("media should hold one of professional, media")
+	}
+	if photographer.HasAny(RoleMedia, RoleAdmin) || photographer.HasAny() {
+		t.Error("photographer should not hold media or admin")
+	}
+}
+
+func TestRoleClaims(t *testing.T) {
+	rc := SetRole(storage.RoleClaims{}, RoleProfessional, true)
+	rc = SetRole(rc, RoleAdmin, true)
+	rc = SetRole(rc, RoleAdmin, false)
+	id := FromClaims("pro", rc)
+	if !id.Has(RoleProfessional) || id.IsAdmin() || id.Has(RoleMedia) {
+		t.Errorf("expected only the professional role, got %v", id.Roles)
+	}
+	if _, ok := ParseRole("superuser"); ok {
+		t.Error("unknown role was parsed")
+	}
+}
+
+func TestCanChangeRole(t *testing.T) {
+	tests := []struct {
+		id      Identity
+		uid     string
+		role    Role
+		granted bool
+		allowed bool
+	}{
+		{admin, "pro", RoleAdmin, true, true},
+		{admin, "pro", RoleProfessional, false, true},
+		{admin, admin.UID, RoleAdmin, false, false},
+		{admin, admin.UID, RoleMedia, true, true},
+		{media, "media", RoleAdmin, true, false},
+	}
+	for _, test := range tests {
+		if got := CanChangeRole(test.id, test.uid, test.role, test.granted) == nil; got != test.allowed {
+			t.Errorf("%s sets %s of %s to %v: expected %v got %v", test.id.UID, test.role, test.uid, test.granted, test.allowed, got)
+		}
+	}
+}
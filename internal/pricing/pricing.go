@@ -0,0 +1,195 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCredits     = errors.New("a booking must use at least one credit")
+	ErrBadInterval   = errors.New("booking must end after it starts")
+	ErrRulesVersion  = errors.New("pricing rules must have a version")
+	ErrNegativePrice = errors.New("pricing rules must not produce negative prices")
+)
+
+// Line item codes returned on a quote
+const (
+	ItemCredits  = "credits"
+	ItemDuration = "duration"
+	ItemProLevel = "pro_level"
+	ItemWeekend  = "weekend"
+	ItemNight    = "night"
+	ItemCountry  = "country"
+	ItemMinimum  = "minimum"
+)
+
+// Rules is one version of the pricing configuration.
+// Multipliers are factors (1.25 adds 25%), surcharges are fractions of the running subtotal (0.5 adds 50%).
+type Rules struct {
+	Version      string `json:"version"`
+	CreditPrice  int32  `json:"creditPrice"`
+	MinimumPrice int32  `json:"minimumPrice"`
+	// hours included in the credits before HourlyRate is charged per started hour
+	IncludedHours int32 `json:"includedHours"`
+	HourlyRate    int32 `json:"hourlyRate"`
+	// keyed by Profile.ProLevel
+	ProLevelMultipliers map[int32]float64 `json:"proLevelMultipliers"`
+	WeekendSurcharge    float64           `json:"weekendSurcharge"`
+	NightSurcharge      float64           `json:"nightSurcharge"`
+	// night is from NightStartHour until NightEndHour the next morning, in the booking's time zone
+	NightStartHour int `json:"nightStartHour"`
+	NightEndHour   int `json:"nightEndHour"`
+	// keyed by upper case ISO 3166 alpha-2 country code
+	CountryMultipliers map[string]float64 `json:"countryMultipliers"`
+}
+
+// DefaultRules keeps the original price of 15 per credit with no surcharges
+var DefaultRules = Rules{
+	Version:        "2020-04-default",
+	CreditPrice:    15,
+	NightStartHour: 22,
+	NightEndHour:   6,
+}
+
+// Request is what a booking is priced from
+type Request struct {
+	Credits   int32     `json:"credits"`
+	DateStart time.Time `json:"dateStart"`
+	DateEnd   time.Time `json:"dateEnd"`
+	ProLevel  int32     `json:"proLevel,omitempty"`
+	Country   string    `json:"country,omitempty"`
+}
+
+// LineItem is one component of a quote
+type LineItem struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Amount      int32  `json:"amount"`
+}
+
+// Quote is an itemised price. Total is the sum of the item amounts.
+type Quote struct {
+	Version string     `json:"version"`
+	Items   []LineItem `json:"items"`
+	Total   int32      `json:"total"`
+}
+
+// LoadRules reads JSON rules from path, falling back to DefaultRules when path is empty
+func LoadRules(path string) (*Rules, error) {
+	if path == "" {
+		rules := DefaultRules
+		return &rules, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules Rules
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, err
+	}
+	return &rules, rules.Validate()
+}
+
+// Validate checks the rules can not produce a negative price
+func (r *Rules) Validate() error {
+	if r.Version == "" {
+		return ErrRulesVersion
+	}
+	if r.CreditPrice < 0 || r.MinimumPrice < 0 || r.HourlyRate < 0 || r.WeekendSurcharge < 0 || r.NightSurcharge < 0 {
+		return ErrNegativePrice
+	}
+	for _, m := range r.ProLevelMultipliers {
+		if m < 0 {
+			return ErrNegativePrice
+		}
+	}
+	for _, m := range r.CountryMultipliers {
+		if m < 0 {
+			return ErrNegativePrice
+		}
+	}
+	return nil
+}
+
+// Quote prices a booking request. Items are applied in order, each surcharge on the running subtotal:
+// credits, duration, pro level, weekend, night, country and finally a top-up to the minimum price.
+func (r *Rules) Quote(req Request) (*Quote, error) {
+	if req.Credits <= 0 {
+		return nil, ErrNoCredits
+	}
+	if !req.DateEnd.After(req.DateStart) {
+		return nil, ErrBadInterval
+	}
+	q := &Quote{Version: r.Version}
+	q.add(ItemCredits, "credits", req.Credits*r.CreditPrice)
+
+	hours := int32(math.Ceil(req.DateEnd.Sub(req.DateStart).Hours()))
+	if extra := hours - r.IncludedHours; r.HourlyRate > 0 && extra > 0 {
+		q.add(ItemDuration, "additional hours", extra*r.HourlyRate)
+	}
+	if m, ok := r.ProLevelMultipliers[req.ProLevel]; ok {
+		q.add(ItemProLevel, "professional level", percentOf(q.Total, m-1))
+	}
+	if r.WeekendSurcharge > 0 && touchesWeekend(req.DateStart, req.DateEnd) {
+		q.add(ItemWeekend, "weekend surcharge", percentOf(q.Total, r.WeekendSurcharge))
+	}
+	if r.NightSurcharge > 0 && r.touchesNight(req.DateStart, req.DateEnd) {
+		q.add(ItemNight, "night surcharge", percentOf(q.Total, r.NightSurcharge))
+	}
+	if m, ok := r.CountryMultipliers[strings.ToUpper(req.Country)]; ok {
+		q.add(ItemCountry, "country adjustment", percentOf(q.Total, m-1))
+	}
+	if q.Total < r.MinimumPrice {
+		q.add(ItemMinimum, "minimum price", r.MinimumPrice-q.Total)
+	}
+	return q, nil
+}
+
+func (q *Quote) add(code, description string, amount int32) {
+	if amount == 0 {
+		return
+	}
+	q.Items = append(q.Items, LineItem{Code: code, Description: description, Amount: amount})
+	q.Total += amount
+}
+
+func percentOf(total int32, fraction float64) int32 {
+	return int32(math.Round(float64(total) * fraction))
+}
+
+// touchesWeekend reports whether any part of [start, end) falls on a saturday or sunday
+func touchesWeekend(start, end time.Time) bool {
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			return true
+		}
+	}
+	return false
+}
+
+// touchesNight reports whether any hour of [start, end) falls in the night window
+func (r *Rules) touchesNight(start, end time.Time) bool {
+	if r.NightStartHour == r.NightEndHour {
+		return false
+	}
+	if end.Sub(start) >= 24*time.Hour {
+		return true
+	}
+	for t := start.Truncate(time.Hour); t.Before(end); t = t.Add(time.Hour) {
+		h := t.Hour()
+		if r.NightStartHour > r.NightEndHour && (h >= r.NightStartHour || h < r.NightEndHour) {
+			return true
+		}
+		if r.NightStartHour < r.NightEndHour && h >= r.NightStartHour && h < r.NightEndHour {
+			return true
+		}
+	}
+	return false
+}
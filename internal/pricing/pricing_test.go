@@ -0,0 +1,79 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+)
+
+// 2020-04-15 is a wednesday
+func date(day, hour int) time.Time {
+	return time.Date(2020, time.April, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestDefaultRulesKeepCreditPrice(t *testing.T) {
+	q, err := DefaultRules.Quote(Request{Credits: 4, DateStart: date(15, 10), DateEnd: date(15, 12)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Total != 60 || len(q.Items) != 1 || q.Items[0].Code != ItemCredits {
+		t.Errorf("expected a single credits item of 60 got %+v", q)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	rules := Rules{
+		Version:             "test",
+		CreditPrice:         100,
+		MinimumPrice:        250,
+		IncludedHours:       2,
+		HourlyRate:          50,
+		ProLevelMultipliers: map[int32]float64{2: 1.5},
+		WeekendSurcharge:    0.5,
+		NightSurcharge:      0.25,
+		NightStartHour:      22,
+		NightEndHour:        6,
+		CountryMultipliers:  map[string]float64{"SE": 1.1},
+	}
+	tests := []struct {
+		name     string
+		req      Request
+		expected int32
+		items    []string
+	}{
+		{"weekday", Request{Credits: 3, DateStart: date(15, 10), DateEnd: date(15, 12)}, 300, []string{ItemCredits}},
+		{"minimum", Request{Credits: 1, DateStart: date(15, 10), DateEnd: date(15, 11)}, 250, []string{ItemCredits, ItemMinimum}},
+		{"duration", Request{Credits: 3, DateStart: date(15, 10), DateEnd: date(15, 14)}, 400, []string{ItemCredits, ItemDuration}},
+		{"pro level", Request{Credits: 3, DateStart: date(15, 10), DateEnd: date(15, 12), ProLevel: 2}, 450, []string{ItemCredits, ItemProLevel}},
+		{"weekend", Request{Credits: 3, DateStart: date(18, 10), DateEnd: date(18, 12)}, 450, []string{ItemCredits, ItemWeekend}},
+		{"night", Request{Credits: 4, DateStart: date(15, 21), DateEnd: date(15, 23)}, 500, []string{ItemCredits, ItemNight}},
+		{"country", Request{Credits: 3, DateStart: date(15, 10), DateEnd: date(15, 12), Country: "se"}, 330, []string{ItemCredits, ItemCountry}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := rules.Quote(test.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.Total != test.expected {
+				t.Errorf("expected total %v got %v (%+v)", test.expected, q.Total, q.Items)
+			}
+			if len(q.Items) != len(test.items) {
+				t.Fatalf("expected items %v got %+v", test.items, q.Items)
+			}
+			for i, code := range test.items {
+				if q.Items[i].Code != code {
+					t.Errorf("expected item %v got %v", code, q.Items[i].Code)
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	if _, err := DefaultRules.Quote(Request{Credits: 0, DateStart: date(15, 10), DateEnd: date(15, 12)}); err != ErrNoCredits {
+		t.Errorf("expected %v got %v", ErrNoCredits, err)
+	}
+	if _, err := DefaultRules.Quote(Request{Credits: 1, DateStart: date(15, 10), DateEnd: date(15, 10)}); err != ErrBadInterval {
+		t.Errorf("expected %v got %v", ErrBadInterval, err)
+	}
+}
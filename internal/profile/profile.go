@@ -0,0 +1,289 @@
+// Package profile filters, sorts and pages the firebase profiles for /profiles.
+// The realtime database can only order by one child and not filter on others,
+// so the query runs over the cached profiles tree, see firebase.WithProfileCache.
+// Pages use a cursor holding the sort value and uid of the last profile, which
+// keeps paging stable when profiles are added between requests.
+package profile
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var (
+	ErrInvalidQuery  = errors.New("invalid profiles query")
+	ErrInvalidCursor = errors.New("invalid or expired profiles cursor")
+	ErrUnknownField  = errors.New("unknown or private profile field")
+)
+
+// sortField is a field profiles can be sorted by
+type sortField struct {
+	numeric bool
+	str     func(p *storage.FirebaseProfile) string
+	num     func(p *storage.FirebaseProfile) int64
+}
+
+var sortFields = map[string]sortField{
+	"userId":              {str: func(p *storage.FirebaseProfile) string { return p.UserID }},
+	"displayName":         {str: func(p *storage.FirebaseProfile) string { return strings.ToLower(p.DisplayName) }},
+	"country":             {str: func(p *storage.FirebaseProfile) string { return strings.ToLower(p.Country) }},
+	"acceptedAssignments": {numeric: true, num: func(p *storage.FirebaseProfile) int64 { return int64(p.AcceptedAssignments) }},
+	"soldStories":         {numeric: true, num: func(p *storage.FirebaseProfile) int64 { return int64(p.SoldStories) }},
+	"uploadedStories":     {numeric: true, num: func(p *storage.FirebaseProfile) int64 { return int64(p.UploadedStories) }},
+}
+
+// Query of /profiles
+type Query struct {
+	Country        string
+	IsProfessional *bool
+	IsMedia        *bool
+	IsPress        *bool
+	// Sort is a field of sortFields, prefixed with - for descending order
+	Sort string
+	// Fields projects the profiles to these json fields, all visible fields when empty
+	Fields []string
+	Limit  int
+	Cursor string
+}
+
+func queryBool(q url.Values, key string) (*bool, error) {
+	v := q.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, ErrInvalidQuery
+	}
+	return &b, nil
+}
+
+// ParseQuery reads country, isProfessional, isMedia, isPress, sort, fields, limit and cursor
+func ParseQuery(q url.Values) (Query, error) {
+	query := Query{
+		Country: q.Get("country"),
+		Sort:    q.Get("sort"),
+		Cursor:  q.Get("cursor"),
+		Limit:   DefaultLimit,
+	}
+	var err error
+	if query.IsProfessional, err = queryBool(q, "isProfessional"); err != nil {
+		return query, err
+	}
+	if query.IsMedia, err = queryBool(q, "isMedia"); err != nil {
+		return query, err
+	}
+	if query.IsPress, err = queryBool(q, "isPress"); err != nil {
+		return query, err
+	}
+	if v := q.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 || query.Limit > MaxLimit {
+			return query, ErrInvalidQuery
+		}
+	}
+	if query.Sort == "" {
+		query.Sort = "userId"
+	}
+	if _, ok := sortFields[strings.TrimPrefix(query.Sort, "-")]; !ok {
+		return query, ErrInvalidQuery
+	}
+	if v := q.Get("fields"); v != "" {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				query.Fields = append(query.Fields, f)
+			}
+		}
+	}
+	return query, nil
+}
+
+func (q Query) matches(p *storage.FirebaseProfile) bool {
+	return (q.Country == "" || strings.EqualFold(q.Country, p.Country)) &&
+		(q.IsProfessional == nil || *q.IsProfessional == p.IsProfessional) &&
+		(q.IsMedia == nil || *q.IsMedia == p.IsMedia) &&
+		(q.IsPress == nil || *q.IsPress == p.IsPress)
+}
+
+// position of a profile in the sort order
+type position struct {
+	Sort string `json:"s"`
+	Str  string `json:"v,omitempty"`
+	Num  int64  `json:"n,omitempty"`
+	UID  string `json:"u"`
+}
+
+func positionOf(sortName string, f sortField, p *storage.FirebaseProfile) position {
+	pos := position{Sort: sortName, UID: p.UserID}
+	if f.numeric {
+		pos.Num = f.num(p)
+	} else {
+		pos.Str = f.str(p)
+	}
+	return pos
+}
+
+// compare orders by the sort value, then by uid so equal values have a stable order
+func compare(f sortField, a, b position) int {
+	switch {
+	case f.numeric && a.Num < b.Num:
+		return -1
+	case f.numeric && a.Num > b.Num:
+		return 1
+	case !f.numeric && a.Str != b.Str:
+		return strings.Compare(a.Str, b.Str)
+	}
+	return strings.Compare(a.UID, b.UID)
+}
+
+func encodeCursor(pos position) string {
+	b, _ := json.Marshal(pos)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor, sortName string) (position, error) {
+	var pos position
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || json.Unmarshal(b, &pos) != nil || pos.Sort != sortName {
+		return pos, ErrInvalidCursor
+	}
+	return pos, nil
+}
+
+// Visible decides whether the caller may see a profile in the list
+type Visible func(p *storage.FirebaseProfile) bool
+
+// Page of profiles, NextCursor is empty on the last page
+type Page struct {
+	Profiles   []map[string]interface{} `json:"profiles"`
+	NextCursor string                   `json:"nextCursor,omitempty"`
+}
+
+// List returns the page of the visible profiles matching q. Profiles are projected from
+// their public view, or from the full profile when full is set.
+func List(prfs []*storage.FirebaseProfile, q Query, visible Visible, full bool) (Page, error) {
+	fields, err := projection(q.Fields, full)
+	if err != nil {
+		return Page{}, err
+	}
+	sortName := q.Sort
+	desc := strings.HasPrefix(sortName, "-")
+	f := sortFields[strings.TrimPrefix(sortName, "-")]
+
+	type item struct {
+		p   *storage.FirebaseProfile
+		pos position
+	}
+	var items []item
+	for _, p := range prfs {
+		if p != nil && p.UserID != "" && q.matches(p) && visible(p) {
+			items = append(items, item{p, positionOf(sortName, f, p)})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		c := compare(f, items[i].pos, items[j].pos)
+		return (c < 0) != desc && c != 0
+	})
+
+	start := 0
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor, sortName)
+		if err != nil {
+			return Page{}, err
+		}
+		start = sort.Search(len(items), func(i int) bool {
+			c := compare(f, items[i].pos, after)
+			return (c > 0 && !desc) || (c < 0 && desc)
+		})
+	}
+	end := start + q.Limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	page := Page{Profiles: make([]map[string]interface{}, 0, end-start)}
+	for _, it := range items[start:end] {
+		var view interface{} = it.p.Public()
+		if full {
+			view = it.p
+		}
+		m, err := project(view, fields)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Profiles = append(page.Profiles, m)
+	}
+	if end < len(items) {
+		page.NextCursor = encodeCursor(items[end-1].pos)
+	}
+	return page, nil
+}
+
+// jsonFields lists the json names of the fields of a struct
+func jsonFields(v interface{}) map[string]bool {
+	t := reflect.TypeOf(v)
+	fields := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+var (
+	publicFields = jsonFields(storage.PublicProfile{})
+	fullFields   = jsonFields(storage.FirebaseProfile{})
+)
+
+// projection validates the requested fields against the view, userId is always included
+func projection(requested []string, full bool) (map[string]bool, error) {
+	if len(requested) == 0 {
+		return nil, nil
+	}
+	allowed := publicFields
+	if full {
+		allowed = fullFields
+	}
+	fields := map[string]bool{"userId": true}
+	for _, f := range requested {
+		if !allowed[f] {
+			return nil, ErrUnknownField
+		}
+		fields[f] = true
+	}
+	return fields, nil
+}
+
+// project encodes the view and keeps the fields, all fields when fields is nil
+func project(view interface{}, fields map[string]bool) (map[string]interface{}, error) {
+	b, err := json.Marshal(view)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return m, nil
+	}
+	for k := range m {
+		if !fields[k] {
+			delete(m, k)
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,152 @@
+package profile
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+var profiles = []*storage.FirebaseProfile{
+	{UserID: "d", DisplayName: "Dina", Country: "DK", IsProfessional: true, SoldStories: 3, Email: "d@byrd.news", WithdrawableAmount: 900},
+	{UserID: "a", DisplayName: "anna", Country: "SE", IsProfessional: true, SoldStories: 3},
+	{UserID: "c", DisplayName: "Carl", Country: "dk", IsMedia: true, SoldStories: 1},
+	{UserID: "b", DisplayName: "Bo", Country: "DK", IsProfessional: true, IsPress: true, SoldStories: 7},
+	{DisplayName: "no id"},
+}
+
+func everyone(*storage.FirebaseProfile) bool { return true }
+
+func uids(p Page) []string {
+	var ids []string
+	for _, m := range p.Profiles {
+		ids = append(ids, m["userId"].(string))
+	}
+	return ids
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{"", true},
+		{"country=DK&isProfessional=true&sort=-soldStories&fields=displayName,country&limit=200", true},
+		{"isMedia=maybe", false},
+		{"limit=0", false},
+		{"limit=201", false},
+		{"sort=email", false},
+	}
+	for _, test := range tests {
+		v, _ := url.ParseQuery(test.query)
+		if _, err := ParseQuery(v); (err == nil) != test.ok {
+			t.Errorf("%q: expected ok %v got %v", test.query, test.ok, err)
+		}
+	}
+	q, _ := ParseQuery(url.Values{})
+	if q.Sort != "userId" || q.Limit != DefaultLimit {
+		t.Errorf("expected the defaults got %+v", q)
+	}
+}
+
+func TestListFilterAndSort(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"a", "b", "c", "d"}},
+		{"country=dk", []string{"b", "c", "d"}},
+		{"isProfessional=true&isPress=false", []string{"a", "d"}},
+		{"isMedia=true", []string{"c"}},
+		{"sort=displayName", []string{"a", "b", "c", "d"}},
+		{"sort=-soldStories", []string{"b", "d", "a", "c"}},
+		{"sort=soldStories", []string{"c", "a", "d", "b"}},
+	}
+	for _, test := range tests {
+		v, _ := url.ParseQuery(test.query)
+		q, _ := ParseQuery(v)
+		page, err := List(profiles, q, everyone, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := uids(page); !equal(got, test.want) {
+			t.Errorf("%q: expected %v got %v", test.query, test.want, got)
+		}
+	}
+}
+
+func TestListPages(t *testing.T) {
+	for _, sort := range []string{"userId", "-soldStories", "country"} {
+		q, _ := ParseQuery(url.Values{"sort": {sort}})
+		all, _ := List(profiles, q, everyone, false)
+
+		q.Limit = 1
+		var got []string
+		for {
+			page, err := List(profiles, q, everyone, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, uids(page)...)
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if want := uids(all); !equal(got, want) {
+			t.Errorf("%s: expected %v got %v", sort, want, got)
+		}
+	}
+
+	q, _ := ParseQuery(url.Values{"limit": {"1"}})
+	page, _ := List(profiles, q, everyone, false)
+	q.Sort, q.Cursor = "-userId", page.NextCursor
+	if _, err := List(profiles, q, everyone, false); err != ErrInvalidCursor {
+		t.Errorf("expected %v for a cursor of another sort got %v", ErrInvalidCursor, err)
+	}
+}
+
+func TestListViews(t *testing.T) {
+	q, _ := ParseQuery(url.Values{"country": {"DK"}, "isPress": {"false"}, "isMedia": {"false"}})
+	page, _ := List(profiles, q, everyone, false)
+	if _, ok := page.Profiles[0]["email"]; ok {
+		t.Error("public view exposes the email")
+	}
+	if _, ok := page.Profiles[0]["withdrawableAmount"]; ok {
+		t.Error("public view exposes the withdrawable amount")
+	}
+	if page, _ = List(profiles, q, everyone, true); page.Profiles[0]["email"] != "d@byrd.news" {
+		t.Errorf("expected the email in the full view got %v", page.Profiles[0])
+	}
+
+	q.Fields = []string{"displayName"}
+	page, _ = List(profiles, q, everyone, false)
+	if len(page.Profiles[0]) != 2 || page.Profiles[0]["displayName"] != "Dina" {
+		t.Errorf("expected userId and displayName got %v", page.Profiles[0])
+	}
+	q.Fields = []string{"withdrawableAmount"}
+	if _, err := List(profiles, q, everyone, false); err != ErrUnknownField {
+		t.Errorf("expected %v got %v", ErrUnknownField, err)
+	}
+	if _, err := List(profiles, q, everyone, true); err != nil {
+		t.Errorf("admins may project private fields, got %v", err)
+	}
+
+	pros := func(p *storage.FirebaseProfile) bool { return p.IsProfessional }
+	q, _ = ParseQuery(url.Values{})
+	if page, _ = List(profiles, q, pros, false); !equal(uids(page), []string{"a", "b", "d"}) {
+		t.Errorf("expected only visible profiles got %v", uids(page))
+	}
+}
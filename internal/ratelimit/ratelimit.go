@@ -0,0 +1,126 @@
+// Package ratelimit implements token bucket rate limits. A bucket holds up to
+// Limit.Burst tokens and refills at Burst tokens per Limit.Per; every request
+// takes a token and is refused when the bucket is empty.
+//
+// Buckets live in a Store: Memory for a single instance, or the Redis store in
+// internal/storage/redis when several instances share the limits.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit of a bucket: Burst requests at once, refilled at Burst per Per
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// interval is the time to refill a single token
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Burst)
+}
+
+// Result of taking a token
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket
+	Limit int
+	// Remaining tokens after this request
+	Remaining int
+	// RetryAfter is the wait until a token is available, zero when allowed
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps the buckets
+type Store interface {
+	// Take takes a token from the bucket of key
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// Take refills a bucket that had tokens at last to now and takes a token from it.
+// It returns the tokens left in the bucket and the result; stores persist the tokens with now.
+func Take(tokens float64, last, now time.Time, l Limit) (float64, Result) {
+	if elapsed := now.Sub(last); elapsed > 0 {
+		tokens = math.Min(float64(l.Burst), tokens+float64(elapsed)/float64(l.interval()))
+	}
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	return tokens, ResultOf(tokens, allowed, l)
+}
+
+// ResultOf describes a bucket left with tokens after a take that was allowed or not
+func ResultOf(tokens float64, allowed bool, l Limit) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     l.Burst,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(l.Burst) - tokens) * float64(l.interval())),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) * float64(l.interval()))
+	}
+	return r
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	per    time.Duration
+}
+
+// Memory keeps buckets in memory of this instance
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+	now     func() time.Time
+	takes   int
+}
+
+// every sweepEvery takes the full buckets are dropped, a full bucket is the same as none
+const sweepEvery = 10000
+
+// NewMemory creates an in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]bucket),
+		now:     time.Now,
+	}
+}
+
+// Take takes a token from the bucket of key, creating a full bucket for unknown keys
+func (m *Memory) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = bucket{tokens: float64(l.Burst), last: now}
+	}
+	var r Result
+	b.tokens, r = Take(b.tokens, b.last, now, l)
+	b.last, b.per = now, l.Per
+	m.buckets[key] = b
+
+	if m.takes++; m.takes >= sweepEvery {
+		m.takes = 0
+		m.sweep(now)
+	}
+	return r, nil
+}
+
+// sweep drops the buckets that refilled by now
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= b.per {
+			delete(m.buckets, key)
+		}
+	}
+}
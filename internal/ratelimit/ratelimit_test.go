@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryTake(t *testing.T) {
+	now := time.Date(2020, 4, 22, 12, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	l := Limit{Burst: 3, Per: 3 * time.Second}
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		r, _ := m.Take(ctx, "ip", l)
+		if !r.Allowed || r.Remaining != i || r.Limit != 3 {
+			t.Errorf("take %d: unexpected %+v", 3-i, r)
+		}
+	}
+	r, _ := m.Take(ctx, "ip", l)
+	if r.Allowed || r.RetryAfter != time.Second || r.Reset != 3*time.Second {
+		t.Errorf("expected refusal with a retry after 1s, got %+v", r)
+	}
+	if r, _ := m.Take(ctx, "other", l); !r.Allowed {
+		t.Error("buckets of other keys must be independent")
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	r, _ = m.Take(ctx, "ip", l)
+	if !r.Allowed || r.Remaining != 0 || r.Reset != 2500*time.Millisecond {
+		t.Errorf("expected a refilled token, got %+v", r)
+	}
+
+	now = now.Add(time.Hour)
+	r, _ = m.Take(ctx, "ip", l)
+	if !r.Allowed || r.Remaining != 2 {
+		t.Errorf("bucket must not refill beyond its burst, got %+v", r)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+	m.Take(ctx, "short", Limit{Burst: 1, Per: time.Second})
+	m.Take(ctx, "long", Limit{Burst: 1, Per: time.Hour})
+
+	m.sweep(now.Add(time.Minute))
+	if _, ok := m.buckets["short"]; ok {
+		t.Error("refilled bucket was kept")
+	}
+	if _, ok := m.buckets["long"]; !ok {
+		t.Error("bucket of a longer limit was dropped before it refilled")
+	}
+}
@@ -3,37 +3,56 @@ package server
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"strings"
 	"time"
 
-	"github.com/davecgh/go-spew/spew"
+	"github.com/byrdapp/timestamp/timeparser"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/sendgrid/sendgrid-go"
 
+	"github.com/byrdapp/byrd-pro-api/internal/apikey"
+	"github.com/byrdapp/byrd-pro-api/internal/booking"
+	"github.com/byrdapp/byrd-pro-api/internal/credits"
 	"github.com/byrdapp/byrd-pro-api/internal/mail"
+	"github.com/byrdapp/byrd-pro-api/internal/policy"
+	"github.com/byrdapp/byrd-pro-api/internal/pricing"
+	"github.com/byrdapp/byrd-pro-api/internal/profile"
 	"github.com/byrdapp/byrd-pro-api/internal/storage"
 	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
-	"github.com/byrdapp/byrd-pro-api/public/conversion"
+	"github.com/byrdapp/byrd-pro-api/public/geo"
+	"github.com/byrdapp/byrd-pro-api/public/ics"
 	"github.com/byrdapp/byrd-pro-api/public/metadata"
 	"github.com/byrdapp/byrd-pro-api/public/thumbnail"
+	utils "github.com/byrdapp/byrd-pro-api/public/time"
 )
 
-var signOut = func(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "POST" {
-		w.Header().Set("Content-Type", "application/json")
-		http.SetCookie(w, &http.Cookie{
-			Name:   "user_token",
-			Value:  "",
-			MaxAge: 0,
-		})
-		http.Redirect(w, r, "/login", http.StatusFound)
+// POST /logoff
+// revokes every session of the caller, the refresh tokens and the id tokens issued until now
+func (s *server) signOut() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			uid := uidFromContext(r.Context())
+			if err := s.auth.RevokeSessions(r.Context(), uid); err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			s.identities.InvalidateUID(uid)
+			if err := json.NewEncoder(w).Encode(&simpleResponse{Msg: "signed out"}); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
 	}
 }
 
@@ -44,16 +63,24 @@ type Credentials struct {
 }
 
 type credsResponse struct {
-	IsPro   bool `json:"isPro"`
-	IsAdmin bool `json:"isAdmin"`
+	IsPro   bool          `json:"isPro"`
+	IsMedia bool          `json:"isMedia"`
+	IsAdmin bool          `json:"isAdmin"`
+	Roles   []policy.Role `json:"roles"`
+}
+
+// sessionResponse is the session of a signed in user together with its roles
+type sessionResponse struct {
+	*storage.Session
+	credsResponse
 }
 
+// POST /login
+// exchanges email and password for an id token and a refresh token
 func (s *server) loginGetUserAccess() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// ? verify here, that the user is a pro user
 		if r.Method == http.MethodPost {
 			w.Header().Set("Content-Type", "application/json")
-			var err error
 			var creds Credentials
 			if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
 				s.writeClient(w, http.StatusBadRequest)
@@ -65,38 +92,82 @@ func (s *server) loginGetUserAccess() http.HandlerFunc {
 				return
 			}
 
-			usr, err := s.fb.GetProfileByEmail(r.Context(), creds.Email)
-			if err != nil {
-				s.writeClient(w, http.StatusNotFound)
+			sess, err := s.auth.SignIn(r.Context(), creds.Email, creds.Password)
+			if err == storage.ErrInvalidCredentials {
+				s.writeClient(w, StatusInvalidCredentials)
 				return
 			}
-
-			isPro, err := s.fb.IsProfessional(r.Context(), usr.UID)
-			if !isPro || err != nil {
-				s.writeClient(w, http.StatusForbidden)
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
 				return
 			}
+			s.writeSession(w, r, sess)
+		}
+	}
+}
 
-			// Is user an admin? Set claims as such.
-			// claims := make(map[string]interface{})
-			isAdmin, err := s.fb.IsAdminUID(r.Context(), usr.UID)
-			if err != nil {
-				s.writeClient(w, http.StatusForbidden)
+// refresh token sent to /reauthenticate
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// POST /reauthenticate
+// exchanges a refresh token for a new id token. The refresh token may be rotated, always use the returned one.
+func (s *server) refreshSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			var req refreshRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				s.writeClient(w, http.StatusBadRequest)
 				return
 			}
-			credsRes := credsResponse{
-				IsPro:   isPro,
-				IsAdmin: isAdmin,
+			defer r.Body.Close()
+			if req.RefreshToken == "" {
+				s.writeClient(w, http.StatusBadRequest)
+				return
 			}
 
-			if err := json.NewEncoder(w).Encode(&credsRes); err != nil {
-				s.writeClient(w, StatusJSONEncode)
+			sess, err := s.auth.Refresh(r.Context(), req.RefreshToken)
+			if err == storage.ErrInvalidRefreshToken {
+				s.writeClient(w, StatusInvalidRefreshToken)
 				return
 			}
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			s.writeSession(w, r, sess)
 		}
 	}
 }
 
+// writeSession verifies the fresh id token, which also caches its identity, and writes it with the roles of the user
+func (s *server) writeSession(w http.ResponseWriter, r *http.Request, sess *storage.Session) {
+	id, _, err := s.verifyIdentity(r.Context(), sess.IDToken)
+	if err != nil {
+		s.writeClient(w, http.StatusUnauthorized).LogError(err)
+		return
+	}
+	if len(id.Roles) == 0 {
+		s.writeClient(w, StatusForbiddenResource)
+		return
+	}
+	res := sessionResponse{
+		Session: sess,
+		credsResponse: credsResponse{
+			IsPro:   id.Has(policy.RoleProfessional),
+			IsMedia: id.Has(policy.RoleMedia),
+			IsAdmin: id.IsAdmin(),
+			Roles:   id.Roles,
+		},
+	}
+	if err := json.NewEncoder(w).Encode(&res); err != nil {
+		s.writeClient(w, StatusJSONEncode)
+		return
+	}
+}
+
 // /profile/decode func attempts to return a profile from a given client UID header
 func (s *server) decodeTokenGetProfile() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -107,7 +178,7 @@ func (s *server) decodeTokenGetProfile() http.HandlerFunc {
 				s.writeClient(w, StatusBadTokenHeader)
 				return
 			}
-			fbtoken, err := s.fb.VerifyToken(r.Context(), clientToken)
+			fbtoken, err := s.auth.VerifyToken(r.Context(), clientToken)
 			if err != nil {
 				s.writeClient(w, StatusBadTokenHeader)
 				return
@@ -135,25 +206,62 @@ func (s *server) getProfileByID() http.HandlerFunc {
 			defer cancel()
 			val, err := s.fb.GetProfile(ctx, params["id"])
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				s.writeClient(w, http.StatusNotFound).LogError(err)
+				return
+			}
+			view, ok := s.profileView(w, r, params["id"], val)
+			if !ok {
+				return
 			}
-			if err := json.NewEncoder(w).Encode(val); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			if err := json.NewEncoder(w).Encode(view); err != nil {
+				s.writeClient(w, StatusJSONEncode).LogError(err)
 			}
 		}
 	}
 }
 
-// getProfiles endpoint: /profiles
+// profileView writes a forbidden response unless the caller may read the profile of uid.
+// Owners and admins get the full profile, everyone else its public view.
+func (s *server) profileView(w http.ResponseWriter, r *http.Request, uid string, profile *storage.FirebaseProfile) (interface{}, bool) {
+	id := identityFromContext(r.Context())
+	if err := policy.CanViewProfile(id, uid, profile.IsProfessional); err != nil {
+		s.writeClient(w, StatusForbiddenResource)
+		return nil, false
+	}
+	if err := policy.CanViewPrivateProfile(id, uid); err != nil {
+		return profile.Public(), true
+	}
+	return profile, true
+}
+
+// GET /profiles?country=&isProfessional=&isMedia=&isPress=&sort=&fields=&limit=&cursor=
 func (s *server) getProfiles() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		r.Header.Set("content-type", "application/json")
-		medias, err := s.fb.GetProfiles(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		id := identityFromContext(r.Context())
+		if err := policy.CanListProfiles(id); err != nil {
+			s.writeClient(w, StatusForbiddenResource)
+			return
+		}
+		query, err := profile.ParseQuery(r.URL.Query())
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, err.Error()).LogError(err)
+			return
+		}
+		prfs, err := s.fb.GetProfiles(r.Context())
+		if err != nil {
+			s.writeClient(w, http.StatusInternalServerError).LogError(err)
+			return
+		}
+		visible := func(p *storage.FirebaseProfile) bool {
+			return policy.CanViewProfile(id, p.UserID, p.IsProfessional) == nil
+		}
+		page, err := profile.List(prfs, query, visible, id.IsAdmin())
 		if err != nil {
-			s.writeClient(w, http.StatusInternalServerError)
+			s.writeError(w, http.StatusBadRequest, err.Error()).LogError(err)
 			return
 		}
-		if err := json.NewEncoder(w).Encode(medias); err != nil {
+		if err := json.NewEncoder(w).Encode(page); err != nil {
 			s.writeClient(w, StatusJSONEncode)
 		}
 	}
@@ -162,7 +270,8 @@ func (s *server) getProfiles() http.HandlerFunc {
 // getExif receives body with img files
 // it attempts to fetch EXIF data from each image
 // if no exif data, the error message will be added to the response without breaking out of the loop until EOF.
-// endpoint: exif/${type=image/video}/?preview:bool
+// endpoint: exif/${type=image/video}/?preview:bool&full:bool
+// full adds a dump of every exif tag of each image
 func (s *server) exifImages() http.HandlerFunc {
 	const thumbXSize, thumbYSize = 160, 120
 	type response struct {
@@ -171,7 +280,7 @@ func (s *server) exifImages() http.HandlerFunc {
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			var withPreview bool
+			var withPreview, withFull bool
 			// w.Header().Set("Content-Type", "multipart/form-data")
 			_, cancel := context.WithTimeout(r.Context(), time.Second*30)
 			defer cancel()
@@ -187,7 +296,11 @@ func (s *server) exifImages() http.HandlerFunc {
 			}
 
 			withPreview = strings.EqualFold(r.URL.Query().Get("preview"), "true")
+			withFull = strings.EqualFold(r.URL.Query().Get("full"), "true")
 			var res []*response
+			// XMP sidecars are merged into the image of the same name, e.g. img_1.xmp into img_1.cr2
+			var names []string
+			sidecars := make(map[string][]byte)
 
 			mr, err := r.MultipartReader()
 			if err != nil {
@@ -206,6 +319,15 @@ func (s *server) exifImages() http.HandlerFunc {
 				}
 				fileName := strings.ToLower(part.FileName())
 				var data response
+				if path.Ext(fileName) == ".xmp" {
+					b, err := ioutil.ReadAll(part)
+					if err != nil {
+						s.writeClient(w, http.StatusBadRequest)
+						return
+					}
+					sidecars[strings.TrimSuffix(fileName, ".xmp")] = b
+					continue
+				}
 				if !metadata.SupportedImageSuffix(fileName) {
 					s.writeClient(w, http.StatusUnsupportedMediaType)
 					return
@@ -219,7 +341,11 @@ func (s *server) exifImages() http.HandlerFunc {
 				defer part.Close()
 
 				br := bytes.NewReader(b)
-				m, err := metadata.DecodeImage(br)
+				decode := metadata.DecodeImage
+				if withFull {
+					decode = metadata.DecodeImageFull
+				}
+				m, err := decode(br)
 				if err != nil {
 					s.Errorf("parsed exif error: %v on file: %v", err, fileName)
 				}
@@ -238,6 +364,14 @@ func (s *server) exifImages() http.HandlerFunc {
 					data.Thumbnail = thumb
 				}
 				res = append(res, &data)
+				names = append(names, strings.TrimSuffix(fileName, path.Ext(fileName)))
+			}
+			for i, data := range res {
+				if sidecar, ok := sidecars[names[i]]; ok && data.Meta != nil {
+					if err := data.Meta.MergeSidecar(sidecar); err != nil {
+						s.Warnf("xmp sidecar of %s: %v", names[i], err)
+					}
+				}
 			}
 
 			if err := json.NewEncoder(w).Encode(res); err != nil {
@@ -327,7 +461,11 @@ func (s *server) getProProfile() http.HandlerFunc {
 			s.writeClient(w, http.StatusNotFound)
 			return
 		}
-		if err := json.NewEncoder(w).Encode(pro); err != nil {
+		view, ok := s.profileView(w, r, params["id"], pro)
+		if !ok {
+			return
+		}
+		if err := json.NewEncoder(w).Encode(view); err != nil {
 			s.writeClient(w, StatusJSONEncode)
 			return
 		}
@@ -338,13 +476,18 @@ func (s *server) getProProfile() http.HandlerFunc {
  * Booking postgres
  */
 
-//  GET /booking/{uid}
+// GET /booking/task/{uid}
 func (s *server) getBookingsByUID() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			w.Header().Set("Content-Type", "application/json")
 			params := mux.Vars(r)
 			userId := params["uid"]
+			id := identityFromContext(r.Context())
+			if err := policy.CanListMediaBookings(id, userId); err != nil {
+				s.writeClient(w, StatusForbiddenResource)
+				return
+			}
 			bookings, err := s.pq.GetBookingsByMediaUID(r.Context(), userId)
 			if err != nil {
 				s.writeClient(w, http.StatusBadRequest)
@@ -359,6 +502,98 @@ func (s *server) getBookingsByUID() http.HandlerFunc {
 	}
 }
 
+// bookingPricing builds the pricing request of a booking on the server so a client can not pick its own price.
+// The pro level is read from the profile of the photographer, a booking nobody accepted yet is priced at the
+// base level. The country is read from the firebase profile of the media.
+func (s *server) bookingPricing(ctx context.Context, b postgres.CreateBookingParams, photographerID string) (pricing.Request, error) {
+	req := pricing.Request{
+		Credits:   b.Credits,
+		DateStart: time.Time(b.DateStart),
+		DateEnd:   time.Time(b.DateEnd),
+	}
+	if photographerID == "" {
+		return req, s.bookingCountry(ctx, b.MediaID, &req)
+	}
+	level, err := s.pq.GetProLevel(ctx, photographerID)
+	switch err {
+	case nil:
+		req.ProLevel = level
+	case sql.ErrNoRows:
+		// no profile row is priced at the base level
+	default:
+		return req, err
+	}
+	return req, s.bookingCountry(ctx, b.MediaID, &req)
+}
+
+// bookingCountry sets the country of req from the firebase profile of the media
+func (s *server) bookingCountry(ctx context.Context, mediaID string, req *pricing.Request) error {
+	if s.fb == nil {
+		return nil
+	}
+	prf, err := s.fb.GetProfile(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+	req.Country = prf.Country
+	return nil
+}
+
+// repriceBooking quotes a booking for new dates or a new photographer, see booking.Pricer
+func (s *server) repriceBooking(ctx context.Context, b postgres.Booking, start, end time.Time) (*pricing.Quote, error) {
+	req, err := s.bookingPricing(ctx, postgres.CreateBookingParams{
+		MediaID:   b.MediaID,
+		Credits:   b.Credits,
+		DateStart: timeparser.Timestamp(start),
+		DateEnd:   timeparser.Timestamp(end),
+	}, b.PhotographerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.pricing.Quote(req)
+}
+
+// bookingMedia returns the media a booking is for, media book for themselves and only admins can book on behalf of a media
+func bookingMedia(id policy.Identity, mediaID string) string {
+	if !id.IsAdmin() || mediaID == "" {
+		return id.UID
+	}
+	return mediaID
+}
+
+// POST /booking/quote
+// returns the itemised price a booking would get from the current pricing rules. Bookings are priced
+// at the base level until a professional accepts them, then at the level of that professional.
+func (s *server) quoteBooking() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			var req postgres.CreateBookingParams
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+			req.MediaID = bookingMedia(identityFromContext(r.Context()), req.MediaID)
+
+			pr, err := s.bookingPricing(r.Context(), req, "")
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			quote, err := s.pricing.Quote(pr)
+			if err != nil {
+				s.writePricingError(w, err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(quote); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
 // POST /booking/task
 func (s *server) createBooking() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -369,24 +604,46 @@ func (s *server) createBooking() http.HandlerFunc {
 				return
 			}
 			defer r.Body.Close()
-			spew.Dump(req)
+			req.MediaID = bookingMedia(identityFromContext(r.Context()), req.MediaID)
 
 			// * if bad date
 			if req.DateStart.IsZero() || req.DateEnd.IsZero() || req.DateEnd.Unix() < time.Now().Unix() {
 				s.writeClient(w, StatusBadDateTime)
 				return
 			}
+			if err := (geo.Point{Lat: req.Lat, Lng: req.Lng}).Validate(); err != nil {
+				s.writeClient(w, StatusBadLocation)
+				return
+			}
 
-			req.Price = req.Credits * 15
-
-			// ? minimum price cap??
-			uuid, err := s.pq.CreateBooking(r.Context(), req)
+			pr, err := s.bookingPricing(r.Context(), req, "")
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			quote, err := s.pricing.Quote(pr)
 			if err != nil {
+				s.writePricingError(w, err)
+				return
+			}
+			req.Price = quote.Total
+			req.PricingVersion = quote.Version
+
+			id, err := booking.Create(r.Context(), s.db, req, uidFromContext(r.Context()))
+			switch err {
+			case nil:
+			case credits.ErrInvalidAmount:
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			case credits.ErrInsufficientCredits:
+				s.writeClient(w, StatusInsufficientCredits)
+				return
+			default:
 				s.writeClient(w, http.StatusForbidden).LogError(err)
 				return
 			}
 
-			if err := json.NewEncoder(w).Encode(uuid); err != nil {
+			if err := json.NewEncoder(w).Encode(id); err != nil {
 				s.writeClient(w, http.StatusInternalServerError)
 				return
 			}
@@ -394,62 +651,952 @@ func (s *server) createBooking() http.HandlerFunc {
 	}
 }
 
-func (s *server) acceptBooking() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {}
+// GET /booking/nearby?lat=&lng=&radius=&limit=&status=
+// or GET /booking/nearby?bbox=minLat,minLng,maxLat,maxLng
+// radius is in km. Bookings are returned nearest first, status defaults to the open ones (requested, offered)
+// and only admins search other statuses. The rows leave out who booked and the terms of the booking.
+func (s *server) getNearbyBookings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			q := r.URL.Query()
+			center, box, radius, err := parseNearbyQuery(q)
+			if err != nil {
+				s.writeClient(w, StatusBadLocation)
+				return
+			}
+			limit, err := queryInt(q, "limit", defaultNearbyLimit)
+			if err != nil || limit <= 0 || limit > maxNearbyLimit {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+
+			statuses := []postgres.BookingStatus{postgres.BookingStatusRequested, postgres.BookingStatusOffered}
+			if v := q.Get("status"); v != "" {
+				statuses = statuses[:0]
+				for _, raw := range strings.Split(v, ",") {
+					status, err := booking.ParseStatus(raw)
+					if err != nil {
+						s.writeClient(w, http.StatusBadRequest)
+						return
+					}
+					if err := policy.CanListNearbyBookings(identityFromContext(r.Context()), status); err != nil {
+						s.writeClient(w, StatusForbiddenResource)
+						return
+					}
+					statuses = append(statuses, status)
+				}
+			}
+
+			bookings, err := s.pq.ListBookingsNearby(r.Context(), postgres.ListBookingsNearbyParams{
+				Lat:        center.Lat,
+				Lng:        center.Lng,
+				MinLat:     box.MinLat,
+				MaxLat:     box.MaxLat,
+				MinLng:     box.MinLng,
+				MaxLng:     box.MaxLng,
+				Statuses:   statuses,
+				RadiusKm:   radius,
+				MaxResults: int32(limit),
+			})
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(bookings); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
 }
 
-// PUT /booking/{bookingID}
-func (s *server) updateBooking() http.HandlerFunc {
+// GET /booking/task/{bookingID}/professionals?radius=&limit=
+// professionals with a known location near the booking, nearest first
+func (s *server) getNearbyProfessionals() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPut {
+		if r.Method == http.MethodGet {
 			w.Header().Set("Content-Type", "application/json")
-			var b storage.Booking
-			var err error
-			params := mux.Vars(r)
-			bookingID, ok := params["bookingID"]
-			if !ok {
+			q := r.URL.Query()
+			radius, err := queryFloat(q, "radius", defaultNearbyRadiusKm)
+			if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+				s.writeClient(w, StatusBadLocation)
+				return
+			}
+			limit, err := queryInt(q, "limit", defaultNearbyLimit)
+			if err != nil || limit <= 0 || limit > maxNearbyLimit {
 				s.writeClient(w, http.StatusBadRequest)
 				return
 			}
 
-			b.ID = bookingID
-			b.Task = r.FormValue("task")
-			b.IsActive, err = conversion.ParseBool(r.FormValue("isActive"))
+			b, _, ok := s.authorizeBooking(w, r, policy.CanManageBooking)
+			if !ok {
+				return
+			}
+			center := geo.Point{Lat: b.Lat, Lng: b.Lng}
+			box := center.BoundingBox(radius)
+			pros, err := s.pq.ListProfilesNearby(r.Context(), postgres.ListProfilesNearbyParams{
+				Lat:        center.Lat,
+				Lng:        center.Lng,
+				MinLat:     box.MinLat,
+				MaxLat:     box.MaxLat,
+				MinLng:     box.MinLng,
+				MaxLng:     box.MaxLng,
+				RadiusKm:   radius,
+				MaxResults: int32(limit),
+			})
 			if err != nil {
-				s.writeClient(w, http.StatusBadRequest)
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(pros); err != nil {
+				s.writeClient(w, StatusJSONEncode)
 				return
 			}
+		}
+	}
+}
 
-			// if err := pq.UpdateBooking(r.Context(), &b); err != nil {
-			// 	s.WriteClient(w, http.StatusInternalServerError)
-			// 	return
-			// }
+// GET /booking/photographer/{uid}/busy?from=&to=
+// from and to are unix seconds or millis and default to the next 30 days
+func (s *server) getPhotographerBusy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			from, to, err := queryRange(r.URL.Query())
+			if err != nil {
+				s.writeClient(w, StatusBadDateTime)
+				return
+			}
+			intervals, err := s.pq.ListPhotographerBusyIntervals(r.Context(), postgres.ListPhotographerBusyIntervalsParams{
+				PhotographerID: mux.Vars(r)["uid"],
+				RangeStart:     from,
+				RangeEnd:       to,
+			})
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(intervals); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
 
-			if err := json.NewEncoder(w).Encode(&b); err != nil {
-				s.writeClient(w, http.StatusInternalServerError)
+// GET /availability/{uid}?from=&to=
+// free intervals of the professional: weekly slots (any time when none are set) and available exceptions, minus busy time and bookings
+func (s *server) getAvailability() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			from, to, err := queryRange(r.URL.Query())
+			if err != nil {
+				s.writeClient(w, StatusBadDateTime)
+				return
+			}
+			free, err := booking.FreeIntervals(r.Context(), s.pq, mux.Vars(r)["uid"], utils.NewTime(from, to))
+			if err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(free); err != nil {
+				s.writeClient(w, StatusJSONEncode)
 				return
 			}
 		}
 	}
 }
 
-// DELETE /bookings/{bookingID}
-func (s *server) deleteBooking() http.HandlerFunc {
+// PUT /availability/slots
+// replaces the weekly availability of the calling professional
+func (s *server) updateAvailabilitySlots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.Header().Set("Content-Type", "application/json")
+			var slots []postgres.CreateAvailabilitySlotParams
+			if err := json.NewDecoder(r.Body).Decode(&slots); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+
+			created, err := booking.ReplaceSlots(r.Context(), s.db, uidFromContext(r.Context()), slots)
+			if err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(created); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// POST /availability/exceptions
+// marks a period as available or unavailable for the calling professional, overriding the weekly slots
+func (s *server) createAvailabilityException() http.HandlerFunc {
+	type request struct {
+		Kind      postgres.AvailabilityKind `json:"kind"`
+		DateStart time.Time                 `json:"date_start"`
+		DateEnd   time.Time                 `json:"date_end"`
+		Summary   string                    `json:"summary"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			var req request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+			if req.Kind == "" {
+				req.Kind = postgres.AvailabilityKindUnavailable
+			}
+			if req.Kind != postgres.AvailabilityKindAvailable && req.Kind != postgres.AvailabilityKindUnavailable {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+			if !req.DateEnd.After(req.DateStart) {
+				s.writeClient(w, StatusBadDateTime)
+				return
+			}
+
+			ex, err := s.pq.CreateAvailabilityException(r.Context(), postgres.CreateAvailabilityExceptionParams{
+				PhotographerID: uidFromContext(r.Context()),
+				Kind:           req.Kind,
+				DateStart:      req.DateStart,
+				DateEnd:        req.DateEnd,
+				Source:         "manual",
+				Summary:        req.Summary,
+			})
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			if err := json.NewEncoder(w).Encode(ex); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// DELETE /availability/exceptions/{id}
+func (s *server) deleteAvailabilityException() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodDelete {
 			w.Header().Set("Content-Type", "application/json")
-			params := mux.Vars(r)
-			userUID, err := uuid.FromBytes([]byte(params["bookingID"]))
+			id, err := uuid.Parse(mux.Vars(r)["id"])
 			if err != nil {
 				s.writeClient(w, http.StatusBadRequest)
 				return
 			}
-			if err := s.pq.DeleteBooking(r.Context(), userUID); err != nil {
-				s.writeClient(w, http.StatusInternalServerError)
+			n, err := s.pq.DeleteAvailabilityException(r.Context(), postgres.DeleteAvailabilityExceptionParams{
+				ID:             id,
+				PhotographerID: uidFromContext(r.Context()),
+			})
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if n == 0 {
+				s.writeClient(w, http.StatusNotFound)
+				return
+			}
+			s.writeClient(w, http.StatusOK)
+		}
+	}
+}
+
+// POST /calendar/import
+// body is an iCalendar (text/calendar) file. Its events replace the previously imported busy time of the calling professional.
+func (s *server) importCalendar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			defer r.Body.Close()
+			events, err := ics.Parse(http.MaxBytesReader(w, r.Body, maxCalendarImportSize))
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, err.Error()).LogError(err)
+				return
+			}
+			created, err := booking.ImportEvents(r.Context(), s.db, uidFromContext(r.Context()), events)
+			if err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(created); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// POST /calendar/token
+// issues a new secret for the calling professional's ICS feed, invalidating the previous one
+func (s *server) createCalendarToken() http.HandlerFunc {
+	type response struct {
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			uid := uidFromContext(r.Context())
+			token, err := booking.NewFeedToken(r.Context(), s.pq, uid)
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			res := response{
+				Token: token,
+				URL:   "/calendar/" + url.PathEscape(uid) + "/bookings.ics?token=" + token,
+			}
+			if err := json.NewEncoder(w).Encode(&res); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// GET /calendar/{uid}/bookings.ics?token=
+// ICS feed of the professional's accepted bookings. Calendar apps can not send a firebase token,
+// so the feed is authenticated by the secret from /calendar/token instead.
+func (s *server) getCalendarFeed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			uid := mux.Vars(r)["uid"]
+			if err := booking.VerifyFeedToken(r.Context(), s.pq, uid, r.URL.Query().Get("token")); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				s.writeBookingError(w, err)
+				return
+			}
+			now := time.Now()
+			bookings, err := s.pq.ListPhotographerBookings(r.Context(), postgres.ListPhotographerBookingsParams{
+				PhotographerID: uid,
+				RangeStart:     now.Add(-calendarFeedHistory),
+				RangeEnd:       now.Add(maxBusyRange),
+			})
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+			if err := ics.Write(w, ics.Calendar{
+				Name:   "Byrd bookings",
+				Events: booking.Events(bookings),
+			}); err != nil {
+				s.Errorf("%v", err)
+			}
+		}
+	}
+}
+
+// PUT /booking/accepted
+// the calling professional accepts the booking offered to them and is set as its photographer,
+// the booking is repriced at their pro level
+func (s *server) acceptBooking() http.HandlerFunc {
+	type request struct {
+		ID uuid.UUID `json:"id"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.Header().Set("Content-Type", "application/json")
+			var req request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+
+			t, err := booking.AcceptOffer(r.Context(), s.db, req.ID, uidFromContext(r.Context()), s.repriceBooking)
+			if err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+
+			if err := json.NewEncoder(w).Encode(&t); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// POST /booking/task/{bookingID}/offers
+// expiresIn is the offer lifetime in seconds and defaults to booking.DefaultOfferTTL
+func (s *server) offerBooking() http.HandlerFunc {
+	type request struct {
+		PhotographerIDs []string `json:"photographerIds"`
+		ExpiresIn       int64    `json:"expiresIn,omitempty"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			b, id, ok := s.authorizeBooking(w, r, policy.CanManageBooking)
+			if !ok {
+				return
+			}
+			var req request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+
+			ttl := time.Duration(req.ExpiresIn) * time.Second
+			offers, err := booking.Offer(r.Context(), s.db, b.ID, req.PhotographerIDs, ttl, id.UID)
+			if err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+
+			if err := json.NewEncoder(w).Encode(offers); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// GET /booking/task/{bookingID}/offers
+func (s *server) getBookingOffers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			b, _, ok := s.authorizeBooking(w, r, policy.CanManageBooking)
+			if !ok {
+				return
+			}
+			offers, err := booking.ListOffers(r.Context(), s.db, b.ID)
+			if err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(offers); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// PUT /booking/task/{bookingID}/status
+// moves the booking through its lifecycle, a booking is accepted through PUT /booking/accepted instead
+func (s *server) transitionBooking() http.HandlerFunc {
+	type request struct {
+		Status string `json:"status"`
+		Reason string `json:"reason,omitempty"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.Header().Set("Content-Type", "application/json")
+			var req request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+
+			status, err := booking.ParseStatus(req.Status)
+			if err != nil {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+			b, id, ok := s.authorizeBooking(w, r, func(id policy.Identity, b postgres.Booking) error {
+				return policy.CanTransitionBooking(id, b, status)
+			})
+			if !ok {
+				return
+			}
+
+			t, err := booking.Transition(r.Context(), s.db, b.ID, status, id.UID, req.Reason)
+			if err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+
+			if err := json.NewEncoder(w).Encode(&t); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// GET /booking/task/{bookingID}/transitions
+func (s *server) getBookingTransitions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			b, _, ok := s.authorizeBooking(w, r, policy.CanViewBooking)
+			if !ok {
+				return
+			}
+			transitions, err := s.pq.ListBookingTransitions(r.Context(), b.ID)
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(transitions); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// GET /credits/balance
+func (s *server) getCreditBalance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			balance, err := credits.GetBalance(r.Context(), s.db, uidFromContext(r.Context()))
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(&balance); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// GET /credits/history?limit=
+// ledger entries on the calling media's accounts, newest first
+func (s *server) getCreditHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			s.writeCreditHistory(w, r, uidFromContext(r.Context()))
+		}
+	}
+}
+
+// GET /admin/credits/{uid}?limit=
+func (s *server) getMediaCreditHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			s.writeCreditHistory(w, r, mux.Vars(r)["uid"])
+		}
+	}
+}
+
+func (s *server) writeCreditHistory(w http.ResponseWriter, r *http.Request, ownerID string) {
+	type response struct {
+		Balance credits.Balance                 `json:"balance"`
+		History []postgres.ListCreditHistoryRow `json:"history"`
+	}
+	limit, err := queryInt(r.URL.Query(), "limit", defaultHistoryLimit)
+	if err != nil || limit <= 0 || limit > maxHistoryLimit {
+		s.writeClient(w, http.StatusBadRequest)
+		return
+	}
+	var res response
+	if res.Balance, err = credits.GetBalance(r.Context(), s.db, ownerID); err != nil {
+		s.writeClient(w, http.StatusInternalServerError).LogError(err)
+		return
+	}
+	res.History, err = s.pq.ListCreditHistory(r.Context(), postgres.ListCreditHistoryParams{
+		OwnerID: ownerID,
+		Limit:   int32(limit),
+	})
+	if err != nil {
+		s.writeClient(w, http.StatusInternalServerError).LogError(err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(&res); err != nil {
+		s.writeClient(w, StatusJSONEncode)
+		return
+	}
+}
+
+// POST /admin/credits/grant
+func (s *server) grantCredits() http.HandlerFunc {
+	type request struct {
+		MediaID string `json:"mediaId"`
+		Amount  int64  `json:"amount"`
+		Memo    string `json:"memo,omitempty"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			var req request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+			if req.MediaID == "" {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+
+			var t postgres.CreditTransaction
+			err := postgres.ExecTx(r.Context(), s.db, func(q *postgres.Queries) error {
+				var err error
+				t, err = credits.GrantTx(r.Context(), q, req.MediaID, req.Amount, uidFromContext(r.Context()), req.Memo)
+				return err
+			})
+			if err == credits.ErrInvalidAmount {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(&t); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// GET /admin/roles/{uid}
+func (s *server) getRoles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			uid := mux.Vars(r)["uid"]
+			rc, err := s.roleClaims(r.Context(), uid)
+			if err != nil {
+				s.writeClient(w, http.StatusNotFound).LogError(err)
+				return
+			}
+			id := policy.FromClaims(uid, rc)
+			if err := json.NewEncoder(w).Encode(&id); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// roleClaims returns the role claims of uid. Users without role claims yet hold the roles
+// verifyIdentity resolves for them, so changing one of their roles keeps the others.
+func (s *server) roleClaims(ctx context.Context, uid string) (storage.RoleClaims, error) {
+	rc, ok, err := s.auth.GetRoleClaims(ctx, uid)
+	if err != nil || ok {
+		return rc, err
+	}
+	id, err := s.resolveIdentity(ctx, uid)
+	if err != nil {
+		return rc, err
+	}
+	return id.Claims(), nil
+}
+
+// PUT /admin/roles/{uid}/{role} grants and DELETE revokes the role.
+// Revoking also revokes the sessions of the user, so tokens still holding the role stop working.
+func (s *server) changeRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut || r.Method == http.MethodDelete {
+			w.Header().Set("Content-Type", "application/json")
+			uid := mux.Vars(r)["uid"]
+			role, ok := policy.ParseRole(mux.Vars(r)["role"])
+			if !ok {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+			granted := r.Method == http.MethodPut
+			if err := policy.CanChangeRole(identityFromContext(r.Context()), uid, role, granted); err != nil {
+				s.writeClient(w, StatusForbiddenResource)
+				return
+			}
+
+			rc, err := s.roleClaims(r.Context(), uid)
+			if err != nil {
+				s.writeClient(w, http.StatusNotFound).LogError(err)
+				return
+			}
+			rc = policy.SetRole(rc, role, granted)
+			if err := s.auth.SetRoleClaims(r.Context(), uid, rc); err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if !granted {
+				if err := s.auth.RevokeSessions(r.Context(), uid); err != nil {
+					s.writeClient(w, http.StatusInternalServerError).LogError(err)
+					return
+				}
+			}
+			// api keys act as the media account, they stop with the role
+			if role == policy.RoleMedia && !granted {
+				if err := s.pq.RevokeMediaApiKeys(r.Context(), uid); err != nil {
+					s.writeClient(w, http.StatusInternalServerError).LogError(err)
+					return
+				}
+			}
+			s.identities.InvalidateUID(uid)
+
+			id := policy.FromClaims(uid, rc)
+			if err := json.NewEncoder(w).Encode(&id); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// apiKeyOwner is the media account whose keys are managed: the caller, or for admins the mediaId query
+func apiKeyOwner(r *http.Request) string {
+	id := identityFromContext(r.Context())
+	if mediaID := r.URL.Query().Get("mediaId"); id.IsAdmin() && mediaID != "" {
+		return mediaID
+	}
+	return id.UID
+}
+
+// POST /apikeys
+// the key is only in this response, store it right away
+func (s *server) createAPIKey() http.HandlerFunc {
+	type request struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	type response struct {
+		Secret string `json:"key"`
+		apikey.Key
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			var req request
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+			scopes, err := apikey.ParseScopes(req.Scopes)
+			if err != nil {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+
+			secret, key, err := apikey.Create(r.Context(), s.pq, apiKeyOwner(r), uidFromContext(r.Context()), req.Name, scopes)
+			if err == apikey.ErrTooManyKeys {
+				s.writeClient(w, StatusTooManyAPIKeys)
+				return
+			}
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			if err := json.NewEncoder(w).Encode(&response{Secret: secret, Key: key}); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// GET /apikeys
+func (s *server) listAPIKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			keys, err := apikey.List(r.Context(), s.pq, apiKeyOwner(r))
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(&keys); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// DELETE /apikeys/{id}
+func (s *server) revokeAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.Header().Set("Content-Type", "application/json")
+			id, err := uuid.Parse(mux.Vars(r)["id"])
+			if err != nil {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+			err = apikey.Revoke(r.Context(), s.pq, id, apiKeyOwner(r))
+			if err == apikey.ErrNotFound {
+				s.writeClient(w, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// writePricingError maps errors from the pricing package to a client status
+func (s *server) writePricingError(w http.ResponseWriter, err error) {
+	switch err {
+	case pricing.ErrNoCredits:
+		s.writeClient(w, http.StatusBadRequest)
+	case pricing.ErrBadInterval:
+		s.writeClient(w, StatusBadDateTime)
+	default:
+		s.writeClient(w, http.StatusInternalServerError).LogError(err)
+	}
+}
+
+// authorizeBooking loads the booking of the route and checks the caller against check.
+// The error response is written and false returned when the request should not continue.
+func (s *server) authorizeBooking(w http.ResponseWriter, r *http.Request, check func(policy.Identity, postgres.Booking) error) (postgres.Booking, policy.Identity, bool) {
+	bookingID, err := uuid.Parse(mux.Vars(r)["bookingID"])
+	if err != nil {
+		s.writeClient(w, http.StatusBadRequest)
+		return postgres.Booking{}, policy.Identity{}, false
+	}
+	id := identityFromContext(r.Context())
+	b, err := s.pq.GetBooking(r.Context(), bookingID)
+	if err != nil {
+		s.writeBookingError(w, err)
+		return b, id, false
+	}
+	if err := check(id, b); err != nil {
+		s.writeBookingError(w, err)
+		return b, id, false
+	}
+	return b, id, true
+}
+
+// writeBookingError maps errors from the booking package and postgres to a client status
+func (s *server) writeBookingError(w http.ResponseWriter, err error) {
+	switch err {
+	case sql.ErrNoRows:
+		s.writeClient(w, http.StatusNotFound)
+	case booking.ErrInvalidTransition:
+		s.writeClient(w, StatusBadBookingTransition)
+	case booking.ErrNoPhotographers:
+		s.writeClient(w, http.StatusBadRequest)
+	case booking.ErrOfferExpired:
+		s.writeClient(w, StatusOfferExpired)
+	case booking.ErrOfferUnavailable:
+		s.writeClient(w, StatusOfferUnavailable)
+	case booking.ErrBookingConflict:
+		s.writeClient(w, StatusBookingConflict)
+	case booking.ErrUnavailable:
+		s.writeClient(w, StatusPhotographerUnavailable)
+	case booking.ErrInvalidSlot, booking.ErrEmptyPatch, booking.ErrEmptyTask:
+		s.writeClient(w, http.StatusBadRequest)
+	case policy.ErrForbidden:
+		s.writeClient(w, StatusForbiddenResource)
+	case booking.ErrVersionConflict:
+		s.writeClient(w, StatusBookingVersionConflict)
+	case booking.ErrNotEditable, booking.ErrDatesLocked:
+		s.writeClient(w, StatusBookingNotEditable)
+	case geo.ErrInvalidPoint:
+		s.writeClient(w, StatusBadLocation)
+	case booking.ErrInvalidInterval:
+		s.writeClient(w, StatusBadDateTime)
+	case booking.ErrInvalidFeedToken:
+		s.writeClient(w, http.StatusUnauthorized)
+	default:
+		s.writeClient(w, http.StatusInternalServerError).LogError(err)
+	}
+}
+
+// PUT /booking/task/{bookingID}
+// partial update of the task, dates or location. Only the owning media or an admin can edit a booking,
+// and the body must carry the version last read so concurrent edits are not silently overwritten.
+// New dates are repriced, and can only be set until a photographer accepted the booking.
+func (s *server) updateBooking() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.Header().Set("Content-Type", "application/json")
+			bookingID, err := uuid.Parse(mux.Vars(r)["bookingID"])
+			if err != nil {
+				s.writeClient(w, http.StatusBadRequest)
+				return
+			}
+			var patch booking.Patch
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&patch); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+
+			id := identityFromContext(r.Context())
+			b, err := booking.Update(r.Context(), s.db, bookingID, patch, id, s.repriceBooking)
+			if err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(&b); err != nil {
+				s.writeClient(w, StatusJSONEncode)
+				return
+			}
+		}
+	}
+}
+
+// DELETE /booking/task/{bookingID}
+// cancels the booking and releases its reserved credits, bookings past in_progress can no longer be deleted
+// and only an admin deletes a disputed booking
+func (s *server) deleteBooking() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.Header().Set("Content-Type", "application/json")
+			b, id, ok := s.authorizeBooking(w, r, policy.CanCancelBooking)
+			if !ok {
+				return
+			}
+			if _, err := booking.Cancel(r.Context(), s.db, b.ID, id.UID, "deleted"); err != nil {
+				s.writeBookingError(w, err)
+				return
+			}
+			s.writeClient(w, http.StatusOK)
+			return
+		}
+	}
+}
+
+// PUT /profile/location
+// sets the location of the calling user, used to find professionals near a booking
+func (s *server) updateProfileLocation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.Header().Set("Content-Type", "application/json")
+			var p geo.Point
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				s.writeClient(w, StatusJSONDecode).LogError(err)
+				return
+			}
+			defer r.Body.Close()
+			if err := p.Validate(); err != nil {
+				s.writeClient(w, StatusBadLocation)
+				return
+			}
+			if err := s.pq.UpdateProfileLocation(r.Context(), postgres.UpdateProfileLocationParams{
+				UserID: uidFromContext(r.Context()),
+				Lat:    sql.NullFloat64{Float64: p.Lat, Valid: true},
+				Lng:    sql.NullFloat64{Float64: p.Lng, Valid: true},
+			}); err != nil {
+				s.writeClient(w, http.StatusInternalServerError).LogError(err)
 				return
 			}
 			s.writeClient(w, http.StatusOK)
-			return
 		}
 	}
 }
@@ -462,17 +1609,17 @@ func (s *server) sendMail() http.HandlerFunc {
 			client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API"))
 			err := json.NewDecoder(r.Body).Decode(&req)
 			if err != nil {
-				http.Error(w, "Wrong body: "+err.Error(), http.StatusInternalServerError)
+				s.writeClient(w, StatusJSONDecode).LogError(err)
 				return
 			}
 			defer r.Body.Close()
 			resp, err := req.SendMail(client)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				s.writeClient(w, http.StatusBadGateway).LogError(err)
 				return
 			}
 			if err := json.NewEncoder(w).Encode(resp); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				s.writeClient(w, StatusJSONEncode).LogError(err)
 			}
 		}
 	}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/byrdapp/byrd-pro-api/internal/identity"
+	"github.com/byrdapp/byrd-pro-api/internal/policy"
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+	"github.com/byrdapp/byrd-pro-api/public/logger"
+)
+
+// fakeRoles keeps the role claims of users in memory, the lookups are those of users without claims
+type fakeRoles struct {
+	storage.IdentityProvider
+	claims              map[string]storage.RoleClaims
+	professional, media map[string]bool
+}
+
+func (f *fakeRoles) GetRoleClaims(ctx context.Context, uid string) (storage.RoleClaims, bool, error) {
+	rc, ok := f.claims[uid]
+	return rc, ok, nil
+}
+
+func (f *fakeRoles) SetRoleClaims(ctx context.Context, uid string, rc storage.RoleClaims) error {
+	f.claims[uid] = rc
+	return nil
+}
+
+func (f *fakeRoles) IsAdminUID(ctx context.Context, uid string) (bool, error) { return false, nil }
+
+func (f *fakeRoles) IsProfessional(ctx context.Context, uid string) (bool, error) {
+	return f.professional[uid], nil
+}
+
+func (f *fakeRoles) IsMedia(ctx context.Context, uid string) (bool, error) { return f.media[uid], nil }
+
+func TestChangeRole(t *testing.T) {
+	auth := &fakeRoles{
+		claims:       map[string]storage.RoleClaims{"claimed": {Media: true}},
+		professional: map[string]bool{"unclaimed": true, "claimed": true},
+	}
+	s := &server{
+		auth:          auth,
+		identities:    identity.NewCache(identity.DefaultRecheck, identity.DefaultMaxEntries),
+		loggerService: logger.NewLogger(),
+	}
+	tests := []struct {
+		name, uid string
+		want      storage.RoleClaims
+	}{
+		// a professional known only through their profile keeps the role when granted media
+		{"without claims", "unclaimed", storage.RoleClaims{Professional: true, Media: true}},
+		// claims once set are the roles of the user, the profile is no longer consulted
+		{"with claims", "claimed", storage.RoleClaims{Media: true}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/admin/roles/"+tt.uid+"/media", nil)
+		req = withIdentity(mux.SetURLVars(req, map[string]string{"uid": tt.uid, "role": "media"}),
+			policy.Identity{UID: "admin", Roles: []policy.Role{policy.RoleAdmin}})
+		rec := httptest.NewRecorder()
+		s.changeRole()(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d got %d", tt.name, http.StatusOK, rec.Code)
+		}
+		if got := auth.claims[tt.uid]; got != tt.want {
+			t.Errorf("%s: expected claims %+v got %+v", tt.name, tt.want, got)
+		}
+		var id policy.Identity
+		if err := json.NewDecoder(rec.Body).Decode(&id); err != nil {
+			t.Fatal(err)
+		}
+		if id.Claims() != tt.want {
+			t.Errorf("%s: expected the roles of %+v got %+v", tt.name, tt.want, id)
+		}
+	}
+}
@@ -1,25 +1,193 @@
 package server
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/byrdapp/byrd-pro-api/internal/apikey"
+	"github.com/byrdapp/byrd-pro-api/internal/policy"
+	"github.com/byrdapp/byrd-pro-api/internal/ratelimit"
 	"github.com/byrdapp/byrd-pro-api/internal/slack"
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+const (
+	userToken    = "user_token"
+	apiKeyHeader = "X-API-Key"
 )
 
+type ctxKey int
+
 const (
-	userToken = "user_token"
+	ctxKeyIdentity ctxKey = iota
+	ctxKeyRequestLog
 )
 
-func (s *server) loggerMw(next http.Handler) http.Handler {
+// identityFromContext returns the caller resolved by isAuth
+func identityFromContext(ctx context.Context) policy.Identity {
+	id, _ := ctx.Value(ctxKeyIdentity).(policy.Identity)
+	return id
+}
+
+// withIdentity stores the caller's identity in the request context and the access log
+func withIdentity(r *http.Request, id policy.Identity) *http.Request {
+	if rl, ok := r.Context().Value(ctxKeyRequestLog).(*requestLog); ok {
+		rl.uid = id.UID
+	}
+	return r.WithContext(context.WithValue(r.Context(), ctxKeyIdentity, id))
+}
+
+// requestIDFromContext returns the id of the request set by requestID
+func requestIDFromContext(ctx context.Context) string {
+	if rl, ok := ctx.Value(ctxKeyRequestLog).(*requestLog); ok {
+		return rl.id
+	}
+	return ""
+}
+
+// uidFromContext returns the verified token UID of the caller
+func uidFromContext(ctx context.Context) string {
+	return identityFromContext(ctx).UID
+}
+
+// resolveIdentity asks the authenticator which roles uid holds, for tokens issued before role claims
+func (s *server) resolveIdentity(ctx context.Context, uid string) (policy.Identity, error) {
+	id := policy.Identity{UID: uid}
+	for _, lookup := range []struct {
+		role policy.Role
+		has  func(context.Context, string) (bool, error)
+	}{
+		{policy.RoleMedia, s.auth.IsMedia},
+		{policy.RoleProfessional, s.auth.IsProfessional},
+		{policy.RoleAdmin, s.auth.IsAdminUID},
+	} {
+		ok, err := lookup.has(ctx, uid)
+		if err != nil {
+			return id, err
+		}
+		if ok {
+			id.Roles = append(id.Roles, lookup.role)
+		}
+	}
+	return id, nil
+}
+
+// requestLog is what the handlers of a request add to its access log
+type requestLog struct {
+	id  string
+	uid string
+}
+
+// maxRequestIDLength bounds the X-Request-ID accepted from clients and proxies
+const maxRequestIDLength = 128
+
+// validRequestID accepts ids of letters, digits and -_.: so they can be logged as they are
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID keeps the X-Request-ID of the caller, or generates one, and echoes it in the response.
+// It is the first middleware so every response and log line of the request carries the id.
+func (s *server) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(headerRequestID)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(headerRequestID, id)
+		rl := &requestLog{id: id}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyRequestLog, rl)))
+	})
+}
+
+// statusWriter records the status and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status    int
+	bytes     int64
+	errorCode string
+	err       error
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streamed responses through the writer
+func (sw *statusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// accessLog writes a json line per request to the access logger, with the uid of signed in callers
+// and the error code and logged cause of failed requests, see writeError.
+func (s *server) accessLog(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("<< %s %s %v\n", r.Method, r.URL.Path, time.Since(start))
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		fields := logrus.Fields{
+			"requestId":  requestIDFromContext(r.Context()),
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"status":     sw.status,
+			"bytes":      sw.bytes,
+			"durationMs": float64(time.Since(start).Microseconds()) / 1000,
+			"ip":         s.clientIP(r),
+			"userAgent":  r.UserAgent(),
+		}
+		if rl, ok := r.Context().Value(ctxKeyRequestLog).(*requestLog); ok && rl.uid != "" {
+			fields["uid"] = rl.uid
+		}
+		if sw.errorCode != "" {
+			fields["errorCode"] = sw.errorCode
+		}
+		if sw.err != nil {
+			fields["error"] = sw.err.Error()
+		}
+		entry := s.access.WithFields(fields)
+		switch {
+		case sw.status >= http.StatusInternalServerError:
+			entry.Error("request")
+		case sw.status >= http.StatusBadRequest:
+			entry.Warn("request")
+		default:
+			entry.Info("request")
+		}
 	})
 }
 
@@ -27,7 +195,7 @@ func (s *server) recoverFunc(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				s.writeClient(w, http.StatusInternalServerError).LogError(err.(error))
+				s.writeClient(w, StatusPanic).LogError(fmt.Errorf("panic: %v", err))
 
 				var recoverReason string
 				switch recovered := err.(type) {
@@ -36,10 +204,10 @@ func (s *server) recoverFunc(next http.Handler) http.Handler {
 				case string:
 					recoverReason = recovered
 				default:
-					recoverReason = recovered.(error).Error()
+					recoverReason = fmt.Sprint(recovered)
 				}
 
-				if os.Getenv("PANIC_NOTIFICATIONS") == "true" {
+				if os.Getenv("PANIC_NOTIFICATIONS") == "true" && s.fb != nil {
 					prf, err := s.fb.GetProfileByToken(r.Context(), r.Header.Get("user_token"))
 					if err != nil {
 						s.Errorf("profile was not found / header not present")
@@ -57,57 +225,144 @@ func (s *server) recoverFunc(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// isAdmin only lets admins through
 func (s *server) isAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return s.isAuth(next, policy.RoleAdmin)
+}
+
+// isAuth verifies the user token and stores the caller's identity in the request context.
+// Verified tokens are cached, see identity.Cache.
+// When roles are given the caller must hold one of them; admins are let through on every route.
+func (s *server) isAuth(next http.HandlerFunc, roles ...policy.Role) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+
 		headerToken := r.Header.Get(userToken)
 		if headerToken == "" {
 			s.writeClient(w, StatusBadTokenHeader)
 			return
 		}
-
-		token, err := s.fb.VerifyToken(r.Context(), headerToken)
-		if err != nil {
+		id, badToken, err := s.verifyIdentity(r.Context(), headerToken)
+		if badToken {
 			s.writeClient(w, StatusBadTokenHeader)
 			return
 		}
-
-		if ok, err := s.fb.IsAdminUID(r.Context(), token.UID); ok && err == nil {
-			next(w, r)
+		if err != nil {
+			s.writeClient(w, http.StatusUnauthorized).LogError(err)
 			return
 		}
-		err = errors.New("No admin rights found:")
-		s.writeClient(w, http.StatusBadRequest).LogError(err)
+		if len(id.Roles) == 0 || (len(roles) > 0 && !id.IsAdmin() && !id.HasAny(roles...)) {
+			s.writeClient(w, StatusForbiddenResource)
+			return
+		}
+		next(w, withIdentity(r, id))
 	}
 }
 
-func (s *server) isAuth(next http.HandlerFunc) http.HandlerFunc {
+// isAuthOrKey is isAuth for routes media integrations may call with an api key.
+// A request with an X-API-Key header acts as the media account of the key when the key holds scope;
+// other requests are authenticated by isAuth with roles.
+func (s *server) isAuthOrKey(next http.HandlerFunc, scope apikey.Scope, roles ...policy.Role) http.HandlerFunc {
+	userAuth := s.isAuth(next, roles...)
 	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" {
+			userAuth(w, r)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
-
-		headerToken := r.Header.Get(userToken)
-		// ? verify here, that the user is a pro user
-		if headerToken == "" {
-			s.writeClient(w, StatusBadTokenHeader)
+		k, err := apikey.Verify(r.Context(), s.pq, key)
+		if err == apikey.ErrInvalidKey {
+			s.writeClient(w, StatusInvalidAPIKey)
 			return
 		}
-		token, err := s.fb.VerifyToken(r.Context(), headerToken)
 		if err != nil {
-			s.writeClient(w, StatusBadTokenHeader)
-			http.RedirectHandler("/login", http.StatusFound)
+			s.writeClient(w, http.StatusInternalServerError).LogError(err)
+			return
+		}
+		id := policy.Identity{UID: k.MediaID, Roles: []policy.Role{policy.RoleMedia}}
+		if !k.Allows(scope) || (len(roles) > 0 && !id.HasAny(roles...)) {
+			s.writeClient(w, StatusForbiddenResource)
 			return
 		}
+		next(w, withIdentity(r, id))
+	}
+}
 
-		isPro, err := s.fb.IsProfessional(r.Context(), token.UID)
+// verifyIdentity returns the identity of a user token through the identity cache.
+// Roles are read from the claims of the token, falling back to resolveIdentity for tokens without them.
+// badToken is true when the token itself was rejected, as opposed to the role lookup failing.
+func (s *server) verifyIdentity(ctx context.Context, idToken string) (id policy.Identity, badToken bool, err error) {
+	id, err = s.identities.Get(ctx, idToken, func(ctx context.Context) (policy.Identity, time.Time, error) {
+		token, err := s.auth.VerifyToken(ctx, idToken)
 		if err != nil {
-			http.RedirectHandler("/login", http.StatusBadRequest)
+			badToken = true
+			return policy.Identity{}, time.Time{}, err
+		}
+		if rc, ok := storage.RoleClaimsFrom(token.Claims); ok {
+			return policy.FromClaims(token.UID, rc), time.Unix(token.Expires, 0), nil
+		}
+		// users without role claims yet, see scripts/claims
+		id, err := s.resolveIdentity(ctx, token.UID)
+		return id, time.Unix(token.Expires, 0), err
+	})
+	return id, badToken, err
+}
+
+// rate limits of the routes, see rateLimit
+var (
+	loginLimit   = ratelimit.Limit{Burst: 10, Per: time.Minute}
+	refreshLimit = ratelimit.Limit{Burst: 30, Per: time.Minute}
+	// image and video metadata share a bucket, both run ffprobe/ffmpeg
+	metaLimit = ratelimit.Limit{Burst: 20, Per: time.Minute}
+)
+
+// rateLimit takes a token from the caller's bucket of route name and refuses the request with 429 when it is empty.
+// Signed in callers are counted per uid, so wrap it inside isAuth; anonymous ones per client ip.
+// When the store fails the request is let through, the limiter must not take the API down.
+func (s *server) rateLimit(name string, l ratelimit.Limit, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := name + ":ip:" + s.clientIP(r)
+		if uid := uidFromContext(r.Context()); uid != "" {
+			key = name + ":uid:" + uid
+		}
+		res, err := s.limiter.Take(r.Context(), key, l)
+		if err != nil {
+			s.Errorf("rate limit %s: %v", name, err)
+			next(w, r)
 			return
 		}
-		if !isPro {
-			s.writeClient(w, http.StatusUnauthorized)
-			http.RedirectHandler("/login", http.StatusFound)
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			h.Set("Retry-After", seconds(res.RetryAfter))
+			h.Set("Content-Type", "application/json")
+			s.writeClient(w, http.StatusTooManyRequests)
 			return
 		}
 		next(w, r)
 	}
 }
+
+// seconds rounds d up to whole seconds for the RateLimit-Reset and Retry-After headers
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// clientIP is the address of the caller. With TRUST_PROXY=true the API runs behind a load balancer
+// and it is the last X-Forwarded-For entry, the one added by the balancer.
+func (s *server) clientIP(r *http.Request) string {
+	if s.trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			parts := strings.Split(fwd, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
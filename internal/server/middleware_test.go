@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/byrdapp/byrd-pro-api/public/logger"
+)
+
+// testServer returns a server of which the access log is written to the returned buffer
+func testServer() (*server, *bytes.Buffer) {
+	var buf bytes.Buffer
+	access := logger.NewAccessLogger()
+	access.SetOutput(&buf)
+	return &server{router: mux.NewRouter(), access: access, loggerService: logger.NewLogger()}, &buf
+}
+
+// accessLine decodes the single access log line of buf
+func accessLine(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	var line map[string]interface{}
+	if err := json.NewDecoder(buf).Decode(&line); err != nil {
+		t.Fatalf("expected an access log line: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected one access log line, also got %s", buf.String())
+	}
+	return line
+}
+
+func TestRequestID(t *testing.T) {
+	s, _ := testServer()
+	var seen string
+	h := s.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name, header string
+		echoed       bool
+	}{
+		{"kept", "req-1.a:b_c", true},
+		{"missing", "", false},
+		{"invalid", "bad id\n", false},
+		{"too long", string(bytes.Repeat([]byte("a"), maxRequestIDLength+1)), false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set(headerRequestID, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(headerRequestID)
+		if id != seen {
+			t.Errorf("%s: the response id %q differs from the context id %q", tt.name, id, seen)
+		}
+		if tt.echoed && id != tt.header {
+			t.Errorf("%s: expected %q echoed got %q", tt.name, tt.header, id)
+		}
+		if _, err := uuid.Parse(id); !tt.echoed && err != nil {
+			t.Errorf("%s: expected a generated uuid got %q", tt.name, id)
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  float64
+		bytes   float64
+	}{
+		{"implicit ok", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		}, http.StatusOK, 5},
+		{"created", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("{}"))
+			_, _ = w.Write([]byte("\n"))
+		}, http.StatusCreated, 3},
+		{"no body", func(w http.ResponseWriter, r *http.Request) {}, http.StatusOK, 0},
+	}
+	for _, tt := range tests {
+		s, buf := testServer()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/path", nil)
+		req.Header.Set(headerRequestID, "req-1")
+		s.middleware(tt.handler).ServeHTTP(rec, req)
+
+		line := accessLine(t, buf)
+		if line["status"] != tt.status || line["bytes"] != tt.bytes || float64(rec.Body.Len()) != tt.bytes {
+			t.Errorf("%s: expected status %v and %v bytes got %v", tt.name, tt.status, tt.bytes, line)
+		}
+		if line["requestId"] != "req-1" || line["path"] != "/path" || line["method"] != http.MethodGet {
+			t.Errorf("%s: unexpected request fields %v", tt.name, line)
+		}
+	}
+
+	// errors are logged with their code and cause
+	s, buf := testServer()
+	s.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.writeClient(w, StatusJSONDecode).LogError(ErrJSONDecoding)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	line := accessLine(t, buf)
+	if line["status"] != float64(http.StatusBadRequest) || line["errorCode"] != "json_decode" || line["error"] != ErrJSONDecoding.Error() {
+		t.Errorf("unexpected error fields %v", line)
+	}
+}
+
+func TestUnmatchedRoutes(t *testing.T) {
+	tests := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/no/such/route", http.StatusNotFound, "not_found"},
+		{http.MethodPost, "/", http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+	for _, tt := range tests {
+		s, buf := testServer()
+		s.Routes()
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected status %d got %d", tt.method, tt.path, tt.status, rec.Code)
+		}
+		id := rec.Header().Get(headerRequestID)
+		if id == "" {
+			t.Errorf("%s %s: expected a request id", tt.method, tt.path)
+		}
+		var res errorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Code != tt.code || res.RequestID != id {
+			t.Errorf("%s %s: unexpected body %+v", tt.method, tt.path, res)
+		}
+		if line := accessLine(t, buf); line["status"] != float64(tt.status) || line["requestId"] != id {
+			t.Errorf("%s %s: unexpected access log %v", tt.method, tt.path, line)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/byrdapp/timestamp/timeparser"
+
+	"github.com/byrdapp/byrd-pro-api/public/geo"
+)
+
+const (
+	defaultNearbyRadiusKm = 20
+	maxNearbyRadiusKm     = 500
+	defaultNearbyLimit    = 50
+	maxNearbyLimit        = 500
+	defaultHistoryLimit   = 100
+	maxHistoryLimit       = 1000
+	defaultBusyRange      = 30 * 24 * time.Hour
+	maxBusyRange          = 366 * 24 * time.Hour
+	maxCalendarImportSize = 1 << 20
+	calendarFeedHistory   = 30 * 24 * time.Hour
+)
+
+// queryFloat parses key from the query, returning fallback when it is absent
+func queryFloat(q url.Values, key string, fallback float64) (float64, error) {
+	v := q.Get(key)
+	if v == "" {
+		return fallback, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// queryInt parses key from the query, returning fallback when it is absent
+func queryInt(q url.Values, key string, fallback int) (int, error) {
+	v := q.Get(key)
+	if v == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// queryTime parses key from the query as unix seconds or millis, returning fallback when it is absent
+func queryTime(q url.Values, key string, fallback time.Time) (time.Time, error) {
+	v := q.Get(key)
+	if v == "" {
+		return fallback, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Time(timeparser.New(i)), nil
+}
+
+// queryRange parses from and to, defaulting to defaultBusyRange from now, and limits the range to maxBusyRange
+func queryRange(q url.Values) (from, to time.Time, err error) {
+	if from, err = queryTime(q, "from", time.Now()); err != nil {
+		return from, to, err
+	}
+	if to, err = queryTime(q, "to", from.Add(defaultBusyRange)); err != nil {
+		return from, to, err
+	}
+	if !to.After(from) || to.Sub(from) > maxBusyRange {
+		return from, to, ErrBadDateRequest
+	}
+	return from, to, nil
+}
+
+// parseNearbyQuery reads either a bbox=minLat,minLng,maxLat,maxLng or a lat, lng and radius (km) search area.
+// For a bbox the center is the middle of the box and the radius reaches its farthest corner, both are
+// bounded by maxNearbyRadiusKm.
+func parseNearbyQuery(q url.Values) (center geo.Point, box geo.Box, radiusKm float64, err error) {
+	if v := q.Get("bbox"); v != "" {
+		parts := strings.Split(v, ",")
+		if len(parts) != 4 {
+			return center, box, 0, geo.ErrInvalidPoint
+		}
+		var corners [4]float64
+		for i, part := range parts {
+			if corners[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+				return center, box, 0, err
+			}
+		}
+		box = geo.Box{MinLat: corners[0], MinLng: corners[1], MaxLat: corners[2], MaxLng: corners[3]}
+		if err := box.Validate(); err != nil {
+			return center, box, 0, err
+		}
+		center = box.Center()
+		for _, corner := range []geo.Point{
+			{Lat: box.MinLat, Lng: box.MinLng},
+			{Lat: box.MinLat, Lng: box.MaxLng},
+			{Lat: box.MaxLat, Lng: box.MinLng},
+			{Lat: box.MaxLat, Lng: box.MaxLng},
+		} {
+			if d := center.DistanceKm(corner); d > radiusKm {
+				radiusKm = d
+			}
+		}
+		if radiusKm > maxNearbyRadiusKm {
+			return center, box, 0, geo.ErrInvalidPoint
+		}
+		return center, box, radiusKm, nil
+	}
+
+	if center.Lat, err = strconv.ParseFloat(q.Get("lat"), 64); err != nil {
+		return center, box, 0, err
+	}
+	if center.Lng, err = strconv.ParseFloat(q.Get("lng"), 64); err != nil {
+		return center, box, 0, err
+	}
+	if err := center.Validate(); err != nil {
+		return center, box, 0, err
+	}
+	radiusKm, err = queryFloat(q, "radius", defaultNearbyRadiusKm)
+	if err != nil {
+		return center, box, 0, err
+	}
+	if radiusKm <= 0 || radiusKm > maxNearbyRadiusKm {
+		return center, box, 0, geo.ErrInvalidPoint
+	}
+	return center, center.BoundingBox(radiusKm), radiusKm, nil
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseNearbyQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{"lat=55.67&lng=12.56", true},
+		{"lat=55.67&lng=12.56&radius=501", false},
+		{"bbox=55.6,12.5,55.7,12.6", true},
+		// the corners of a box are bounded by the radius cap too
+		{"bbox=-90,-180,90,180", false},
+		{"bbox=50,5,60,20", false},
+		{"bbox=55.6,12.5,55.7", false},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, radius, err := parseNearbyQuery(q)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s: expected ok %v got %v", tt.query, tt.ok, err)
+		}
+		if err == nil && (radius <= 0 || radius > maxNearbyRadiusKm) {
+			t.Errorf("%s: expected a radius within the cap got %v", tt.query, radius)
+		}
+	}
+}
@@ -2,10 +2,16 @@ package server
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
+	"strings"
 
-	"errors"
+	"github.com/byrdapp/byrd-pro-api/internal/apikey"
+	"github.com/byrdapp/byrd-pro-api/internal/booking"
+	"github.com/byrdapp/byrd-pro-api/internal/credits"
+	"github.com/byrdapp/byrd-pro-api/internal/policy"
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+	"github.com/byrdapp/byrd-pro-api/public/geo"
 )
 
 type simpleResponse struct {
@@ -15,7 +21,7 @@ type simpleResponse struct {
 
 type HttpStatusCode int
 
-var ErrPanicRecover = errors.New("")
+var ErrPanicRecover = errors.New("the request failed unexpectedly")
 var ErrJSONEncoding = errors.New("json marshall encoding to byte array")
 var ErrJSONDecoding = errors.New("json unmarshall decoding")
 var ErrBadTokenHeader = errors.New("no or wrong token found in header")
@@ -30,58 +36,180 @@ const (
 	StatusBadTokenHeader
 	StatusBadDateTime
 	StatusNotMultipart
+	StatusBadBookingTransition
+	StatusOfferExpired
+	StatusOfferUnavailable
+	StatusBadLocation
+	StatusInsufficientCredits
+	StatusBookingConflict
+	StatusBookingVersionConflict
+	StatusBookingNotEditable
+	StatusForbiddenResource
+	StatusInvalidCredentials
+	StatusInvalidRefreshToken
+	StatusInvalidAPIKey
+	StatusTooManyAPIKeys
+	StatusPhotographerUnavailable
 )
 
 var StatusText = map[HttpStatusCode]error{
+	StatusPanic:          ErrPanicRecover,
 	StatusJSONEncode:     ErrJSONEncoding,
 	StatusJSONDecode:     ErrJSONDecoding,
 	StatusBadTokenHeader: ErrBadTokenHeader,
 	StatusBadDateTime:    ErrBadDateRequest,
 	StatusNotMultipart:   ErrNotMultiplart,
+
+	StatusBadBookingTransition: booking.ErrInvalidTransition,
+	StatusOfferExpired:         booking.ErrOfferExpired,
+	StatusOfferUnavailable:     booking.ErrOfferUnavailable,
+	StatusBadLocation:          geo.ErrInvalidPoint,
+	StatusInsufficientCredits:  credits.ErrInsufficientCredits,
+	StatusBookingConflict:      booking.ErrBookingConflict,
+
+	StatusBookingVersionConflict:  booking.ErrVersionConflict,
+	StatusBookingNotEditable:      booking.ErrNotEditable,
+	StatusForbiddenResource:       policy.ErrForbidden,
+	StatusInvalidCredentials:      storage.ErrInvalidCredentials,
+	StatusInvalidRefreshToken:     storage.ErrInvalidRefreshToken,
+	StatusInvalidAPIKey:           apikey.ErrInvalidKey,
+	StatusTooManyAPIKeys:          apikey.ErrTooManyKeys,
+	StatusPhotographerUnavailable: booking.ErrUnavailable,
+}
+
+// statusHeader is the http status written for each custom code, custom codes are never sent as the status itself
+var statusHeader = map[HttpStatusCode]int{
+	StatusPanic:                   http.StatusInternalServerError,
+	StatusJSONEncode:              http.StatusInternalServerError,
+	StatusJSONDecode:              http.StatusBadRequest,
+	StatusBadTokenHeader:          http.StatusUnauthorized,
+	StatusBadDateTime:             http.StatusBadRequest,
+	StatusNotMultipart:            http.StatusUnsupportedMediaType,
+	StatusBadBookingTransition:    http.StatusConflict,
+	StatusOfferExpired:            http.StatusGone,
+	StatusOfferUnavailable:        http.StatusConflict,
+	StatusBadLocation:             http.StatusBadRequest,
+	StatusInsufficientCredits:     http.StatusPaymentRequired,
+	StatusBookingConflict:         http.StatusConflict,
+	StatusBookingVersionConflict:  http.StatusPreconditionFailed,
+	StatusBookingNotEditable:      http.StatusConflict,
+	StatusForbiddenResource:       http.StatusForbidden,
+	StatusInvalidCredentials:      http.StatusUnauthorized,
+	StatusInvalidRefreshToken:     http.StatusUnauthorized,
+	StatusInvalidAPIKey:           http.StatusUnauthorized,
+	StatusTooManyAPIKeys:          http.StatusConflict,
+	StatusPhotographerUnavailable: http.StatusConflict,
+}
+
+// errorCodes are the machine readable codes of the custom statuses, clients switch on these.
+// Standard statuses use their snake cased status text, e.g. not_found.
+var errorCodes = map[HttpStatusCode]string{
+	StatusPanic:                   "panic",
+	StatusJSONEncode:              "json_encode",
+	StatusJSONDecode:              "json_decode",
+	StatusBadTokenHeader:          "bad_token_header",
+	StatusBadDateTime:             "bad_date_time",
+	StatusNotMultipart:            "not_multipart",
+	StatusBadBookingTransition:    "bad_booking_transition",
+	StatusOfferExpired:            "offer_expired",
+	StatusOfferUnavailable:        "offer_unavailable",
+	StatusBadLocation:             "bad_location",
+	StatusInsufficientCredits:     "insufficient_credits",
+	StatusBookingConflict:         "booking_conflict",
+	StatusBookingVersionConflict:  "booking_version_conflict",
+	StatusBookingNotEditable:      "booking_not_editable",
+	StatusForbiddenResource:       "forbidden_resource",
+	StatusInvalidCredentials:      "invalid_credentials",
+	StatusInvalidRefreshToken:     "invalid_refresh_token",
+	StatusInvalidAPIKey:           "invalid_api_key",
+	StatusTooManyAPIKeys:          "too_many_api_keys",
+	StatusPhotographerUnavailable: "photographer_unavailable",
 }
 
-// writes client or returns json encoding error
-func (s *server) writeClient(w http.ResponseWriter, code HttpStatusCode) (jsonerr HttpStatusCode) {
-	enc := json.NewEncoder(w)
-	msg, ok := code.StatusText()
-	if !ok {
-		if err := enc.Encode(&simpleResponse{
-			Code: http.StatusInternalServerError,
-			Msg:  "statuswriter failed output",
-		}); err != nil {
-			s.Errorf("%v", err)
+// headerRequestID carries the id of a request, the response echoes it
+const headerRequestID = "X-Request-ID"
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+}
+
+// writeClient writes the response of code, see writeError
+func (s *server) writeClient(w http.ResponseWriter, code HttpStatusCode) clientError {
+	return s.writeError(w, code, nil)
+}
+
+// writeError writes an error response of code with details for the client, e.g. the invalid field.
+// Codes below 400 acknowledge a request with a simpleResponse instead.
+func (s *server) writeError(w http.ResponseWriter, code HttpStatusCode, details interface{}) clientError {
+	w.Header().Set("Content-Type", "application/json")
+	status, msg := code.Status()
+	w.WriteHeader(status)
+
+	var res interface{} = &simpleResponse{Code: status, Msg: msg}
+	if status >= http.StatusBadRequest {
+		if sw, ok := w.(*statusWriter); ok {
+			sw.errorCode = code.ErrorCode()
+		}
+		res = &errorResponse{
+			Code:      code.ErrorCode(),
+			Message:   msg,
+			Details:   details,
+			RequestID: w.Header().Get(headerRequestID),
 		}
-		return
 	}
-	w.WriteHeader(int(code))
-	res := &simpleResponse{
-		Code: int(code),
-		Msg:  msg,
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.Errorf("%v", err)
 	}
-	if err := enc.Encode(res); err != nil {
-		return StatusJSONEncode
+	return clientError{code: code, w: w}
+}
+
+// clientError is a written response, LogError adds its cause to the access log of the request
+type clientError struct {
+	code HttpStatusCode
+	w    http.ResponseWriter
+}
+
+func (ce clientError) LogError(err error) {
+	if sw, ok := ce.w.(*statusWriter); ok && err != nil {
+		sw.err = err
+	}
+}
+
+// Status returns the http status and message of code. Unknown codes are internal server errors.
+func (code HttpStatusCode) Status() (int, string) {
+	if text := http.StatusText(int(code)); text != "" {
+		return int(code), text
 	}
-	return 0
+	if err, ok := StatusText[code]; ok {
+		return statusHeader[code], err.Error()
+	}
+	return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
 }
 
-// Output log if necessary
+// ErrorCode returns the machine readable code of code
+func (code HttpStatusCode) ErrorCode() string {
+	if c, ok := errorCodes[code]; ok {
+		return c
+	}
+	status, msg := code.Status()
+	if int(code) != status {
+		msg = http.StatusText(status)
+	}
+	msg = strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(msg)
+	return strings.ToLower(msg)
+}
+
+// StatusText returns the message of code and whether code is known
 func (code HttpStatusCode) StatusText() (string, bool) {
 	if http.StatusText(int(code)) != "" {
-		// s.Warnf("http stdlib code: %v", code)
 		return http.StatusText(int(code)), true
-	} else {
-		if val, ok := StatusText[code]; ok {
-			// s.Warnf("custom http code: %v", code)
-			return val.Error(), ok
-		} else {
-			// s.Warnf("code %v - possibly nil pointer err", int(code))
-			return "unknown error occurred internally - contact Simon on Slack.", false
-		}
 	}
-}
-
-func (code HttpStatusCode) LogError(err error) {
-	log.Printf("%v", err)
-	msg, ok := code.StatusText()
-	log.Printf("err val: %+v \n ClientMsg: %s (statuscode anticipated and defined in API?: %v)", err, msg, ok)
+	if val, ok := StatusText[code]; ok {
+		return val.Error(), ok
+	}
+	return http.StatusText(http.StatusInternalServerError), false
 }
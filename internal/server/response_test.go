@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/byrdapp/byrd-pro-api/public/logger"
+)
+
+func TestStatusAndErrorCode(t *testing.T) {
+	tests := []struct {
+		code   HttpStatusCode
+		status int
+		want   string
+	}{
+		{StatusPanic, http.StatusInternalServerError, "panic"},
+		{StatusJSONEncode, http.StatusInternalServerError, "json_encode"},
+		{StatusJSONDecode, http.StatusBadRequest, "json_decode"},
+		{StatusBadTokenHeader, http.StatusUnauthorized, "bad_token_header"},
+		{StatusBadDateTime, http.StatusBadRequest, "bad_date_time"},
+		{StatusNotMultipart, http.StatusUnsupportedMediaType, "not_multipart"},
+		{StatusBadBookingTransition, http.StatusConflict, "bad_booking_transition"},
+		{StatusOfferExpired, http.StatusGone, "offer_expired"},
+		{StatusOfferUnavailable, http.StatusConflict, "offer_unavailable"},
+		{StatusBadLocation, http.StatusBadRequest, "bad_location"},
+		{StatusInsufficientCredits, http.StatusPaymentRequired, "insufficient_credits"},
+		{StatusBookingConflict, http.StatusConflict, "booking_conflict"},
+		{StatusBookingVersionConflict, http.StatusPreconditionFailed, "booking_version_conflict"},
+		{StatusBookingNotEditable, http.StatusConflict, "booking_not_editable"},
+		{StatusForbiddenResource, http.StatusForbidden, "forbidden_resource"},
+		{StatusInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+		{StatusInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token"},
+		{StatusInvalidAPIKey, http.StatusUnauthorized, "invalid_api_key"},
+		{StatusTooManyAPIKeys, http.StatusConflict, "too_many_api_keys"},
+		{StatusPhotographerUnavailable, http.StatusConflict, "photographer_unavailable"},
+		// standard statuses are sent as they are
+		{http.StatusNotFound, http.StatusNotFound, "not_found"},
+		{http.StatusTooManyRequests, http.StatusTooManyRequests, "too_many_requests"},
+		{HttpStatusCode(999), http.StatusInternalServerError, "internal_server_error"},
+	}
+	for _, tt := range tests {
+		status, msg := tt.code.Status()
+		if status != tt.status {
+			t.Errorf("%d: expected status %d got %d", tt.code, tt.status, status)
+		}
+		if msg == "" {
+			t.Errorf("%d: expected a message", tt.code)
+		}
+		if got := tt.code.ErrorCode(); got != tt.want {
+			t.Errorf("%d: expected code %s got %s", tt.code, tt.want, got)
+		}
+	}
+
+	// every custom code needs a message, a status and a code
+	for code := HttpStatusCode(StatusPanic); code <= StatusPhotographerUnavailable; code++ {
+		if _, ok := StatusText[code]; !ok {
+			t.Errorf("%d has no message", code)
+		}
+		if _, ok := statusHeader[code]; !ok {
+			t.Errorf("%d has no status", code)
+		}
+		if _, ok := errorCodes[code]; !ok {
+			t.Errorf("%d has no code", code)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	s := &server{loggerService: logger.NewLogger()}
+	h := s.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.writeError(w, StatusBadLocation, map[string]string{"field": "lat"})
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/booking/task", nil)
+	req.Header.Set(headerRequestID, "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected a json response got %s", ct)
+	}
+	var res struct {
+		Code      string            `json:"code"`
+		Message   string            `json:"message"`
+		Details   map[string]string `json:"details"`
+		RequestID string            `json:"requestId"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != "bad_location" || res.Message != StatusText[StatusBadLocation].Error() ||
+		res.Details["field"] != "lat" || res.RequestID != "req-1" {
+		t.Errorf("unexpected error body %+v", res)
+	}
+}
+
+func TestWriteClientSuccess(t *testing.T) {
+	s := &server{loggerService: logger.NewLogger()}
+	rec := httptest.NewRecorder()
+	s.writeClient(rec, http.StatusOK)
+
+	var res simpleResponse
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || res.Code != http.StatusOK || res.Msg != "OK" {
+		t.Errorf("unexpected response %d %+v", rec.Code, res)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,11 +14,20 @@ import (
 	mux "github.com/gorilla/mux"
 	_ "github.com/lib/pq"
 	"github.com/rs/cors"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/net/http2"
 
+	"github.com/byrdapp/byrd-pro-api/internal/apikey"
+	"github.com/byrdapp/byrd-pro-api/internal/cache"
+	"github.com/byrdapp/byrd-pro-api/internal/identity"
+	"github.com/byrdapp/byrd-pro-api/internal/policy"
+	"github.com/byrdapp/byrd-pro-api/internal/pricing"
+	"github.com/byrdapp/byrd-pro-api/internal/ratelimit"
 	"github.com/byrdapp/byrd-pro-api/internal/storage"
 	firebase "github.com/byrdapp/byrd-pro-api/internal/storage/firebase"
+	"github.com/byrdapp/byrd-pro-api/internal/storage/jwtauth"
 	"github.com/byrdapp/byrd-pro-api/internal/storage/postgres"
+	"github.com/byrdapp/byrd-pro-api/internal/storage/redis"
 	"github.com/byrdapp/byrd-pro-api/public/logger"
 )
 
@@ -32,10 +42,18 @@ type loggerService interface {
 
 // server is used in main.go
 type server struct {
-	srv    *http.Server
-	router *mux.Router
-	pq     *postgres.Queries
-	fb     storage.FBService
+	srv        *http.Server
+	router     *mux.Router
+	db         *sql.DB
+	pq         *postgres.Queries
+	fb         storage.FBService
+	auth       storage.IdentityProvider
+	identities *identity.Cache
+	pricing    *pricing.Rules
+	limiter    ratelimit.Store
+	trustProxy bool
+	// access logs a json line per request, see accessLog
+	access *logrus.Logger
 	loggerService
 }
 
@@ -45,7 +63,8 @@ func NewServer() (*server, error) {
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"http://localhost:4200", "http://localhost:4201", "http://localhost", "https://pro.development.byrd.news", "https://pro.dev.byrd.news", "https://pro.byrd.news"},
 		AllowedMethods: []string{"GET", "PUT", "POST", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Content-Type", "Accept", "Content-Length", "X-Requested-By", "User-Agent", "user_token"},
+		AllowedHeaders: []string{"Content-Type", "Accept", "Content-Length", "X-Requested-By", "User-Agent", "user_token", headerRequestID},
+		ExposedHeaders: []string{headerRequestID, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 	})
 
 	httpsSrv := &http.Server{
@@ -71,30 +90,126 @@ func NewServer() (*server, error) {
 	}
 	pq := postgres.New(conn)
 
-	fbsrv, err := firebase.NewFB()
+	// the local authenticator runs without google credentials, firebase is then only used when configured
+	var fbsrv storage.FBService
+	if os.Getenv("AUTH_PROVIDER") != "local" || os.Getenv("FB_DATABASE_URL") != "" {
+		if fbsrv, err = firebase.NewFB(); err != nil {
+			return nil, err
+		}
+	}
+	authenticator, err := newAuthenticator(fbsrv)
 	if err != nil {
 		return nil, err
 	}
 
+	rules, err := pricing.LoadRules(os.Getenv("PRICING_RULES_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	// rate limits and cached reads are shared by the instances through redis, or kept per instance without REDIS_URL
+	var limiter ratelimit.Store = ratelimit.NewMemory()
+	var kv cache.Cache = cache.NewMemory()
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		client, err := redis.NewClient(url)
+		if err != nil {
+			return nil, err
+		}
+		limiter = redis.NewRateLimitStore(client)
+		kv = redis.NewCache(client)
+	}
+	if fbsrv != nil {
+		ttl, err := envDuration("PROFILE_CACHE_TTL")
+		if err != nil {
+			return nil, err
+		}
+		fbsrv = firebase.WithProfileCache(fbsrv, kv, ttl)
+	}
+
 	return &server{
 		srv:           httpsSrv,
 		router:        r,
+		db:            conn,
 		pq:            pq,
 		fb:            fbsrv,
+		auth:          authenticator,
+		identities:    identity.NewCache(identity.DefaultRecheck, identity.DefaultMaxEntries),
+		pricing:       rules,
+		limiter:       limiter,
+		trustProxy:    os.Getenv("TRUST_PROXY") == "true",
+		access:        logger.NewAccessLogger(),
 		loggerService: logger.NewLogger(),
 	}, nil
 }
 
+// newAuthenticator picks the identity provider from AUTH_PROVIDER: firebase (default) or local.
+// local verifies HS256 tokens with AUTH_JWT_SECRET and/or RS256 tokens with the keys in AUTH_JWKS_FILE,
+// checking AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE when set. Users in AUTH_USERS_FILE can sign in and get
+// HS256 tokens valid for AUTH_TOKEN_TTL, refreshed for AUTH_REFRESH_TTL (durations like 1h).
+func newAuthenticator(fb storage.FBService) (storage.IdentityProvider, error) {
+	switch provider := os.Getenv("AUTH_PROVIDER"); provider {
+	case "", "firebase":
+		return fb, nil
+	case "local":
+		tokenTTL, err := envDuration("AUTH_TOKEN_TTL")
+		if err != nil {
+			return nil, err
+		}
+		refreshTTL, err := envDuration("AUTH_REFRESH_TTL")
+		if err != nil {
+			return nil, err
+		}
+		local, err := jwtauth.New(jwtauth.Config{
+			Secret:     []byte(os.Getenv("AUTH_JWT_SECRET")),
+			JWKSFile:   os.Getenv("AUTH_JWKS_FILE"),
+			Issuer:     os.Getenv("AUTH_JWT_ISSUER"),
+			Audience:   os.Getenv("AUTH_JWT_AUDIENCE"),
+			UsersFile:  os.Getenv("AUTH_USERS_FILE"),
+			TokenTTL:   tokenTTL,
+			RefreshTTL: refreshTTL,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return local, nil
+	default:
+		return nil, fmt.Errorf("unknown AUTH_PROVIDER %q", provider)
+	}
+}
+
+// envDuration parses key as a time.Duration, zero when it is not set
+func envDuration(key string) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", key, err)
+	}
+	return d, nil
+}
+
+// middleware wraps every response, the router only runs it for matched routes so it also wraps the 404 and 405 handlers
+func (s *server) middleware(next http.Handler) http.Handler {
+	return s.requestID(s.accessLog(s.recoverFunc(next)))
+}
+
 func (s *server) Routes() {
-	s.router.Use(s.recoverFunc, s.loggerMw)
+	s.router.Use(s.middleware)
+	s.router.NotFoundHandler = s.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.writeClient(w, http.StatusNotFound)
+	}))
+	s.router.MethodNotAllowedHandler = s.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.writeClient(w, http.StatusMethodNotAllowed)
+	}))
 
 	s.router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusTooEarly)
 	}).Methods("GET")
-	s.router.HandleFunc("/login", s.loginGetUserAccess()).Methods("POST")
 
 	// * Private endpoints
-	s.router.HandleFunc("/reauthenticate", s.isAuth(s.loginGetUserAccess())).Methods("GET")
+	// isAuth without roles lets any signed in media or professional through
 	s.router.HandleFunc("/secure", s.isAuth(func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte(`{"msg": "Secure msg from byrd-pro-api service"}`))
 	})).Methods("GET")
@@ -103,24 +218,57 @@ func (s *server) Routes() {
 		_, _ = w.Write([]byte(`{"msg": "Secure msg from byrd-pro-api service to ADMINS!"}`))
 	})).Methods("GET")
 
-	s.router.HandleFunc("/logoff", signOut).Methods("POST")
-	s.router.HandleFunc("/meta/image", s.isAuth(s.exifImages())).Methods("POST")
-	s.router.HandleFunc("/meta/video", s.isAuth(s.exifVideo())).Methods("POST")
+	s.router.HandleFunc("/admin/credits/grant", s.isAdmin(s.grantCredits())).Methods("POST")
+	s.router.HandleFunc("/admin/credits/{uid}", s.isAdmin(s.getMediaCreditHistory())).Methods("GET")
+	s.router.HandleFunc("/admin/roles/{uid}", s.isAdmin(s.getRoles())).Methods("GET")
+	s.router.HandleFunc("/admin/roles/{uid}/{role}", s.isAdmin(s.changeRole())).Methods("PUT", "DELETE")
 
-	s.router.HandleFunc("/profiles", s.isAuth(s.getProfiles())).Methods("GET")
-	s.router.HandleFunc("/profile/{id}", s.isAuth(s.getProfileByID())).Methods("GET")
+	s.router.HandleFunc("/login", s.rateLimit("login", loginLimit, s.loginGetUserAccess())).Methods("POST")
+	s.router.HandleFunc("/reauthenticate", s.rateLimit("reauthenticate", refreshLimit, s.refreshSession())).Methods("POST")
+	s.router.HandleFunc("/logoff", s.isAuth(s.signOut())).Methods("POST")
+	s.router.HandleFunc("/meta/image", s.isAuth(s.rateLimit("meta", metaLimit, s.exifImages()), policy.RoleProfessional)).Methods("POST")
+	s.router.HandleFunc("/meta/video", s.isAuth(s.rateLimit("meta", metaLimit, s.exifVideo()), policy.RoleProfessional)).Methods("POST")
+
+	// profiles are stored in firebase, without it only the postgres backed routes are served
+	if s.fb != nil {
+		s.router.HandleFunc("/profiles", s.isAuth(s.getProfiles())).Methods("GET")
+		s.router.HandleFunc("/profile/{id}", s.isAuth(s.getProfileByID())).Methods("GET")
+
+		s.router.HandleFunc("/auth/profile/token", s.isAuth(s.decodeTokenGetProfile())).Methods("GET")
+		s.router.HandleFunc("/profile/{id}", s.isAuth(s.getProProfile())).Methods("GET")
+	}
 
-	s.router.HandleFunc("/auth/profile/token", s.isAuth(s.decodeTokenGetProfile())).Methods("GET")
-	s.router.HandleFunc("/profile/{id}", s.isAuth(s.getProProfile())).Methods("GET")
+	s.router.HandleFunc("/profile/location", s.isAuth(s.updateProfileLocation(), policy.RoleProfessional)).Methods("PUT")
 
-	s.router.HandleFunc("/booking/task/{uid}", s.isAuth(s.getBookingsByUID())).Methods("GET")
+	s.router.HandleFunc("/booking/task/{uid}", s.isAuthOrKey(s.getBookingsByUID(), apikey.ScopeBookingsRead, policy.RoleMedia)).Methods("GET")
+	s.router.HandleFunc("/booking/nearby", s.isAuth(s.getNearbyBookings(), policy.RoleProfessional)).Methods("GET")
+	s.router.HandleFunc("/booking/photographer/{uid}/busy", s.isAuth(s.getPhotographerBusy())).Methods("GET")
+	s.router.HandleFunc("/booking/task/{bookingID}/professionals", s.isAuthOrKey(s.getNearbyProfessionals(), apikey.ScopeBookingsRead, policy.RoleMedia)).Methods("GET")
 
-	s.router.HandleFunc("/booking/task", s.isAuth(s.createBooking())).Methods("POST")
-	s.router.HandleFunc("/booking/accepted", s.isAuth(s.acceptBooking())).Methods("PUT") // ==> update accepted true/false
+	s.router.HandleFunc("/booking/quote", s.isAuthOrKey(s.quoteBooking(), apikey.ScopeBookingsWrite, policy.RoleMedia)).Methods("POST")
+	s.router.HandleFunc("/booking/task", s.isAuthOrKey(s.createBooking(), apikey.ScopeBookingsWrite, policy.RoleMedia)).Methods("POST")
+	s.router.HandleFunc("/booking/accepted", s.isAuth(s.acceptBooking(), policy.RoleProfessional)).Methods("PUT")
+	s.router.HandleFunc("/booking/task/{bookingID}/offers", s.isAuthOrKey(s.offerBooking(), apikey.ScopeBookingsWrite, policy.RoleMedia)).Methods("POST")
+	s.router.HandleFunc("/booking/task/{bookingID}/offers", s.isAuthOrKey(s.getBookingOffers(), apikey.ScopeBookingsRead, policy.RoleMedia)).Methods("GET")
+	s.router.HandleFunc("/booking/task/{bookingID}/status", s.isAuthOrKey(s.transitionBooking(), apikey.ScopeBookingsWrite)).Methods("PUT")
+	s.router.HandleFunc("/booking/task/{bookingID}/transitions", s.isAuthOrKey(s.getBookingTransitions(), apikey.ScopeBookingsRead)).Methods("GET")
 	// s.router.HandleFunc("/booking/task/{proUID}", s.isAuth(createSpecficBooking)).Methods("POST")
 
-	s.router.HandleFunc("/booking/task/{bookingID}", s.isAuth(s.updateBooking())).Methods("PUT")
-	s.router.HandleFunc("/booking/task/{bookingID}", s.isAuth(s.deleteBooking())).Methods("DELETE")
+	s.router.HandleFunc("/booking/task/{bookingID}", s.isAuthOrKey(s.updateBooking(), apikey.ScopeBookingsWrite, policy.RoleMedia)).Methods("PUT")
+	s.router.HandleFunc("/booking/task/{bookingID}", s.isAuthOrKey(s.deleteBooking(), apikey.ScopeBookingsWrite, policy.RoleMedia)).Methods("DELETE")
+	s.router.HandleFunc("/availability/slots", s.isAuth(s.updateAvailabilitySlots(), policy.RoleProfessional)).Methods("PUT")
+	s.router.HandleFunc("/availability/exceptions", s.isAuth(s.createAvailabilityException(), policy.RoleProfessional)).Methods("POST")
+	s.router.HandleFunc("/availability/exceptions/{id}", s.isAuth(s.deleteAvailabilityException(), policy.RoleProfessional)).Methods("DELETE")
+	s.router.HandleFunc("/availability/{uid}", s.isAuth(s.getAvailability())).Methods("GET")
+	s.router.HandleFunc("/calendar/import", s.isAuth(s.importCalendar(), policy.RoleProfessional)).Methods("POST")
+	s.router.HandleFunc("/calendar/token", s.isAuth(s.createCalendarToken(), policy.RoleProfessional)).Methods("POST")
+	s.router.HandleFunc("/calendar/{uid}/bookings.ics", s.getCalendarFeed()).Methods("GET")
+	s.router.HandleFunc("/credits/balance", s.isAuthOrKey(s.getCreditBalance(), apikey.ScopeCreditsRead, policy.RoleMedia)).Methods("GET")
+	s.router.HandleFunc("/credits/history", s.isAuthOrKey(s.getCreditHistory(), apikey.ScopeCreditsRead, policy.RoleMedia)).Methods("GET")
+	// api keys are managed with a user login only, a key can not create or revoke keys
+	s.router.HandleFunc("/apikeys", s.isAuth(s.createAPIKey(), policy.RoleMedia)).Methods("POST")
+	s.router.HandleFunc("/apikeys", s.isAuth(s.listAPIKeys(), policy.RoleMedia)).Methods("GET")
+	s.router.HandleFunc("/apikeys/{id}", s.isAuth(s.revokeAPIKey(), policy.RoleMedia)).Methods("DELETE")
 	s.router.HandleFunc("/mail/send", s.isAuth(s.sendMail())).Methods("POST")
 	// s.router.HandleFunc("/booking/task" /** isAdmin() middleware? */, isAuth(getProfileWithBookings)).Methods("GET")
 }
@@ -0,0 +1,86 @@
+package firebase
+
+import (
+	"context"
+	"time"
+
+	"github.com/byrdapp/byrd-pro-api/internal/cache"
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+// DefaultProfileTTL is how long profile reads are cached. Apps write profiles to
+// firebase directly, so changes show up in the API after at most this long.
+const DefaultProfileTTL = 5 * time.Minute
+
+const (
+	profileKeyPrefix = "profile:"
+	profilesKey      = "profiles"
+)
+
+// cachedProfiles caches the profile reads of a FBService
+type cachedProfiles struct {
+	storage.FBService
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// WithProfileCache caches GetProfile and GetProfiles of fb in c for ttl.
+// Cache errors are logged and fall back to reading firebase.
+func WithProfileCache(fb storage.FBService, c cache.Cache, ttl time.Duration) storage.FBService {
+	if ttl <= 0 {
+		ttl = DefaultProfileTTL
+	}
+	return &cachedProfiles{FBService: fb, cache: c, ttl: ttl}
+}
+
+// GetProfile reads the profile of uid from the cache before firebase
+func (c *cachedProfiles) GetProfile(ctx context.Context, uid string) (*storage.FirebaseProfile, error) {
+	key := profileKeyPrefix + uid
+	var prf storage.FirebaseProfile
+	err := cache.GetJSON(ctx, c.cache, key, &prf)
+	if err == nil {
+		return &prf, nil
+	}
+	if err != cache.ErrMiss {
+		log.Warnf("profile cache: %v", err)
+	}
+	p, err := c.FBService.GetProfile(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.SetJSON(ctx, c.cache, key, p, c.ttl); err != nil {
+		log.Warnf("profile cache: %v", err)
+	}
+	return p, nil
+}
+
+// GetProfiles reads the whole profiles tree from the cache before firebase
+func (c *cachedProfiles) GetProfiles(ctx context.Context) ([]*storage.FirebaseProfile, error) {
+	var prfs []*storage.FirebaseProfile
+	err := cache.GetJSON(ctx, c.cache, profilesKey, &prfs)
+	if err == nil {
+		return prfs, nil
+	}
+	if err != cache.ErrMiss {
+		log.Warnf("profile cache: %v", err)
+	}
+	prfs, err = c.FBService.GetProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.SetJSON(ctx, c.cache, profilesKey, prfs, c.ttl); err != nil {
+		log.Warnf("profile cache: %v", err)
+	}
+	return prfs, nil
+}
+
+// PutProfileData writes through and drops the cached profile
+func (c *cachedProfiles) PutProfileData(uid string, prop string, value string) error {
+	if err := c.FBService.PutProfileData(uid, prop, value); err != nil {
+		return err
+	}
+	if _, err := c.cache.Del(context.Background(), profileKeyPrefix+uid, profilesKey); err != nil {
+		log.Warnf("profile cache: %v", err)
+	}
+	return nil
+}
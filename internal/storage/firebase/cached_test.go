@@ -0,0 +1,58 @@
+package firebase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/byrdapp/byrd-pro-api/internal/cache"
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+// countingFB counts the profile reads reaching firebase
+type countingFB struct {
+	storage.FBService
+	reads int
+}
+
+func (f *countingFB) GetProfile(ctx context.Context, uid string) (*storage.FirebaseProfile, error) {
+	f.reads++
+	return &storage.FirebaseProfile{UserID: uid, IsProfessional: true}, nil
+}
+
+func (f *countingFB) GetProfiles(ctx context.Context) ([]*storage.FirebaseProfile, error) {
+	f.reads++
+	return []*storage.FirebaseProfile{{UserID: "a"}, {UserID: "b"}}, nil
+}
+
+func (f *countingFB) PutProfileData(uid string, prop string, value string) error {
+	return nil
+}
+
+func TestProfileCache(t *testing.T) {
+	fb := &countingFB{}
+	c := WithProfileCache(fb, cache.NewMemory(), time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		p, err := c.GetProfile(ctx, "pro")
+		if err != nil || p.UserID != "pro" || !p.IsProfessional {
+			t.Fatalf("unexpected profile %+v %v", p, err)
+		}
+		if prfs, _ := c.GetProfiles(ctx); len(prfs) != 2 {
+			t.Fatalf("expected 2 profiles got %d", len(prfs))
+		}
+	}
+	if fb.reads != 2 {
+		t.Errorf("expected 2 firebase reads got %d", fb.reads)
+	}
+
+	if err := c.PutProfileData("pro", "displayName", "Simon"); err != nil {
+		t.Fatal(err)
+	}
+	c.GetProfile(ctx, "pro")
+	c.GetProfiles(ctx)
+	if fb.reads != 4 {
+		t.Errorf("expected the update to drop the cached profiles, got %d reads", fb.reads)
+	}
+}
@@ -27,6 +27,8 @@ type Firebase struct {
 	Client  *db.Client
 	Auth    *auth.Client
 	Context context.Context // context.Background() - use r.Context()
+	// APIKey is the web api key used for the password sign in and token refresh endpoints
+	APIKey string
 }
 
 // ! Get profile params to switch profile type (reg, media, pro)
@@ -58,6 +60,7 @@ func NewFB() (storage.FBService, error) {
 		Client:  client,
 		Context: ctx,
 		Auth:    fbAuth,
+		APIKey:  os.Getenv("FB_WEB_API_KEY"),
 	}, nil
 }
 
@@ -130,6 +133,9 @@ func (db *Firebase) GetProfile(ctx context.Context, uid string) (*storage.Fireba
 	if err != nil {
 		return nil, err
 	}
+	if prf.UserID == "" {
+		prf.UserID = uid
+	}
 	return &prf, nil
 }
 
@@ -175,6 +181,10 @@ func (db *Firebase) GetProfiles(ctx context.Context) ([]*storage.FirebaseProfile
 		if err := r.Unmarshal(&p); err != nil {
 			return nil, errors.Wrap(err, "unmarshall struct error")
 		}
+		// older profiles were written without their own id
+		if p.UserID == "" {
+			p.UserID = r.Key()
+		}
 		prfs = append(prfs, &p)
 	}
 	return prfs, nil
@@ -221,21 +231,33 @@ func (db *Firebase) VerifyToken(ctx context.Context, clientToken string) (*auth.
 	return t, nil
 }
 
-// IsAdminClaims returns token as a string. ! Not Used in admin middleware.go.
-// ! currently not in use because of method below
+// IsAdminClaims is true when the custom claims of a verified token hold the admin role
 func (db *Firebase) IsAdminClaims(claims map[string]interface{}) bool {
-	// The claims can be accessed on the user record.
-	log.Infoln(claims)
-	if admin, ok := claims["is_admin"]; ok {
-		if admin.(bool) {
-			return admin.(bool)
-		}
+	rc, _ := storage.RoleClaimsFrom(claims)
+	return rc.Admin
+}
+
+// GetRoleClaims reads the role claims set on the auth user of uid
+func (db *Firebase) GetRoleClaims(ctx context.Context, uid string) (storage.RoleClaims, bool, error) {
+	user, err := db.Auth.GetUser(ctx, uid)
+	if err != nil {
+		return storage.RoleClaims{}, false, err
+	}
+	rc, ok := storage.RoleClaimsFrom(user.CustomClaims)
+	return rc, ok, nil
+}
+
+// SetRoleClaims writes the role claims of uid, keeping its other custom claims
+func (db *Firebase) SetRoleClaims(ctx context.Context, uid string, rc storage.RoleClaims) error {
+	user, err := db.Auth.GetUser(ctx, uid)
+	if err != nil {
+		return err
 	}
-	return false
+	return db.Auth.SetCustomUserClaims(ctx, uid, rc.Merge(user.CustomClaims))
 }
 
-// IsAdminUID will return true if the uid is found in the admin fb storage
-// It's being called in loginCreateToken handler
+// IsAdminUID will return true if the uid is found in the admin fb storage.
+// Only used for tokens without role claims, see storage.RoleClaimsFrom.
 func (db *Firebase) IsAdminUID(ctx context.Context, uid string) (bool, error) {
 	path := os.Getenv("ENV") + "/admins"
 	ref := db.Client.NewRef(path)
@@ -249,17 +271,20 @@ func (db *Firebase) IsAdminUID(ctx context.Context, uid string) (bool, error) {
 	return false, nil
 }
 
-// IsAdminUID will return true if the uid is found in the admin fb storage
-// It's being called in loginCreateToken handler
+// IsProfessional will return true if the profile of uid is a professional
 func (db *Firebase) IsProfessional(ctx context.Context, uid string) (isPro bool, err error) {
-	var profile storage.FirebaseProfile
-	path := os.Getenv("ENV") + "/profiles"
-	ref := db.Client.NewRef(path).Child(uid)
-	if err := ref.Get(ctx, &profile); err != nil {
+	profile, err := db.GetProfile(ctx, uid)
+	if err != nil {
 		return false, err
 	}
-	if !profile.IsProfessional {
-		return false, errors.Errorf("User %s is not a professional", profile.DisplayName)
+	return profile.IsProfessional, nil
+}
+
+// IsMedia will return true if the profile of uid is a media or press account
+func (db *Firebase) IsMedia(ctx context.Context, uid string) (bool, error) {
+	profile, err := db.GetProfile(ctx, uid)
+	if err != nil {
+		return false, err
 	}
-	return true, nil
+	return profile.IsMedia || profile.IsPress, nil
 }
@@ -0,0 +1,133 @@
+package firebase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+var (
+	signInURL  = "https://identitytoolkit.googleapis.com/v1/accounts:signInWithPassword"
+	refreshURL = "https://securetoken.googleapis.com/v1/token"
+
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// ErrNoAPIKey is returned by SignIn and Refresh when FB_WEB_API_KEY is not set
+var ErrNoAPIKey = errors.New("firebase: FB_WEB_API_KEY is required for sign in")
+
+type restError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// SignIn checks email and password against firebase auth and returns the tokens of the user
+func (db *Firebase) SignIn(ctx context.Context, email, password string) (*storage.Session, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"email":             email,
+		"password":          password,
+		"returnSecureToken": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		LocalID      string `json:"localId"`
+		IDToken      string `json:"idToken"`
+		RefreshToken string `json:"refreshToken"`
+		ExpiresIn    string `json:"expiresIn"`
+	}
+	if err := db.post(ctx, signInURL, "application/json", bytes.NewReader(body), &res); err != nil {
+		return nil, err
+	}
+	return newSession(res.LocalID, res.IDToken, res.RefreshToken, res.ExpiresIn), nil
+}
+
+// Refresh exchanges a firebase refresh token for a new id token
+func (db *Firebase) Refresh(ctx context.Context, refreshToken string) (*storage.Session, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}
+	var res struct {
+		UserID       string `json:"user_id"`
+		IDToken      string `json:"id_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    string `json:"expires_in"`
+	}
+	if err := db.post(ctx, refreshURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), &res); err != nil {
+		return nil, err
+	}
+	return newSession(res.UserID, res.IDToken, res.RefreshToken, res.ExpiresIn), nil
+}
+
+// RevokeSessions revokes the refresh tokens of uid. VerifyToken rejects id tokens issued before the revocation.
+func (db *Firebase) RevokeSessions(ctx context.Context, uid string) error {
+	return db.Auth.RevokeRefreshTokens(ctx, uid)
+}
+
+func newSession(uid, idToken, refreshToken, expiresIn string) *storage.Session {
+	secs, err := strconv.Atoi(expiresIn)
+	if err != nil || secs <= 0 {
+		secs = 3600
+	}
+	return &storage.Session{
+		UID:          uid,
+		IDToken:      idToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(secs) * time.Second),
+	}
+}
+
+// post calls a firebase auth REST endpoint and decodes the response into v.
+// Rejected credentials and refresh tokens are mapped to the storage errors.
+func (db *Firebase) post(ctx context.Context, endpoint, contentType string, body io.Reader, v interface{}) error {
+	if db.APIKey == "" {
+		return ErrNoAPIKey
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint+"?key="+url.QueryEscape(db.APIKey), body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "firebase: auth request")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var e restError
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return errors.Errorf("firebase: auth request failed with status %d", res.StatusCode)
+		}
+		return restErr(e.Error.Message)
+	}
+	return errors.Wrap(json.NewDecoder(res.Body).Decode(v), "firebase: decode auth response")
+}
+
+// restErr maps the error messages of the identity toolkit and secure token APIs
+func restErr(msg string) error {
+	// messages may carry a reason, e.g. "TOO_MANY_ATTEMPTS_TRY_LATER : Access to this account..."
+	code := strings.TrimSpace(strings.SplitN(msg, ":", 2)[0])
+	switch code {
+	case "EMAIL_NOT_FOUND", "INVALID_PASSWORD", "INVALID_LOGIN_CREDENTIALS", "INVALID_EMAIL", "USER_DISABLED", "MISSING_PASSWORD":
+		return storage.ErrInvalidCredentials
+	case "TOKEN_EXPIRED", "INVALID_REFRESH_TOKEN", "USER_NOT_FOUND", "MISSING_REFRESH_TOKEN":
+		return storage.ErrInvalidRefreshToken
+	}
+	return errors.Errorf("firebase: %s", msg)
+}
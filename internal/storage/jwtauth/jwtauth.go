@@ -0,0 +1,282 @@
+// Package jwtauth verifies locally issued JWTs so the API can run without Firebase,
+// e.g. in integration tests and on-prem staging. Tokens are signed with a shared
+// HS256 secret or with RS256 keys published in a JWKS file.
+//
+// The uid is the sub claim and roles come from the is_admin, is_professional and
+// is_media claims. Roles are remembered per uid when a token is verified, which is
+// how the Is* lookups of storage.Authenticator are answered.
+//
+// With a secret and a users file the package also signs users in and issues its
+// own HS256 id tokens together with opaque refresh tokens, see session.go.
+package jwtauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"firebase.google.com/go/auth"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+var (
+	ErrNoKeys       = errors.New("jwtauth: a secret or a JWKS file is required")
+	ErrInvalidToken = errors.New("jwtauth: token is invalid")
+	ErrUnknownKey   = errors.New("jwtauth: token is signed with an unknown key")
+	ErrUnknownUser  = errors.New("jwtauth: no verified token seen for user")
+	ErrNoSigningKey = errors.New("jwtauth: a secret is required to issue tokens")
+)
+
+// Config of the local authenticator. At least one of Secret and JWKSFile must be set.
+// Issuer and Audience are checked when set. UsersFile enables SignIn and requires Secret.
+type Config struct {
+	Secret    []byte
+	JWKSFile  string
+	Issuer    string
+	Audience  string
+	UsersFile string
+	// TokenTTL and RefreshTTL default to DefaultTokenTTL and DefaultRefreshTTL
+	TokenTTL   time.Duration
+	RefreshTTL time.Duration
+}
+
+// Local verifies tokens issued outside Firebase
+type Local struct {
+	secret   []byte
+	keys     map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+
+	users      map[string]User
+	tokenTTL   time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+
+	mu      sync.RWMutex
+	roles   map[string]storage.RoleClaims
+	refresh map[string]refreshSession
+	revoked map[string]int64
+}
+
+var _ storage.IdentityProvider = (*Local)(nil)
+
+// New creates a local authenticator from cfg
+func New(cfg Config) (*Local, error) {
+	l := &Local{
+		secret:     cfg.Secret,
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		users:      make(map[string]User),
+		tokenTTL:   cfg.TokenTTL,
+		refreshTTL: cfg.RefreshTTL,
+		now:        time.Now,
+		roles:      make(map[string]storage.RoleClaims),
+		refresh:    make(map[string]refreshSession),
+		revoked:    make(map[string]int64),
+	}
+	if l.tokenTTL <= 0 {
+		l.tokenTTL = DefaultTokenTTL
+	}
+	if l.refreshTTL <= 0 {
+		l.refreshTTL = DefaultRefreshTTL
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		l.keys = keys
+	}
+	if len(l.secret) == 0 && len(l.keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if cfg.UsersFile != "" {
+		if len(l.secret) == 0 {
+			return nil, ErrNoSigningKey
+		}
+		users, err := LoadUsers(cfg.UsersFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			l.users[strings.ToLower(u.Email)] = u
+		}
+	}
+	return l, nil
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// LoadJWKS reads the RSA signing keys of a JWKS file, keyed by kid
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set jwks
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, errors.Wrap(err, "jwtauth: decode jwks")
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.Wrapf(err, "jwtauth: modulus of key %q", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, errors.Wrapf(err, "jwtauth: exponent of key %q", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func (l *Local) key(t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() || len(l.secret) == 0 {
+			return nil, ErrUnknownKey
+		}
+		return l.secret, nil
+	case *jwt.SigningMethodRSA:
+		if t.Method.Alg() != jwt.SigningMethodRS256.Alg() {
+			return nil, ErrUnknownKey
+		}
+		kid, _ := t.Header["kid"].(string)
+		if k, ok := l.keys[kid]; ok {
+			return k, nil
+		}
+		return nil, ErrUnknownKey
+	}
+	return nil, ErrUnknownKey
+}
+
+// VerifyToken checks the signature, expiry, issuer and audience of idToken
+func (l *Local) VerifyToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(idToken, claims, l.key); err != nil {
+		return nil, errors.Wrap(ErrInvalidToken, err.Error())
+	}
+	if l.issuer != "" && !claims.VerifyIssuer(l.issuer, true) {
+		return nil, errors.Wrap(ErrInvalidToken, "issuer mismatch")
+	}
+	if l.audience != "" && !claims.VerifyAudience(l.audience, true) {
+		return nil, errors.Wrap(ErrInvalidToken, "audience mismatch")
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, errors.Wrap(ErrInvalidToken, "missing sub")
+	}
+
+	t := &auth.Token{
+		UID:     sub,
+		Subject: sub,
+		Claims:  make(map[string]interface{}),
+	}
+	t.Issuer, _ = claims["iss"].(string)
+	t.Audience, _ = claims["aud"].(string)
+	if exp, ok := claims["exp"].(float64); ok {
+		t.Expires = int64(exp)
+	}
+	if iat, ok := claims["iat"].(float64); ok {
+		t.IssuedAt = int64(iat)
+	}
+	l.mu.RLock()
+	revokedAt, revoked := l.revoked[sub]
+	l.mu.RUnlock()
+	if revoked && t.IssuedAt < revokedAt {
+		return nil, errors.Wrap(ErrInvalidToken, "token is revoked")
+	}
+	for k, v := range claims {
+		switch k {
+		case "sub", "iss", "aud", "exp", "iat", "nbf":
+		default:
+			t.Claims[k] = v
+		}
+	}
+
+	rc, _ := storage.RoleClaimsFrom(claims)
+	l.mu.Lock()
+	l.roles[sub] = rc
+	l.mu.Unlock()
+	return t, nil
+}
+
+func (l *Local) rolesOf(uid string) (storage.RoleClaims, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	r, ok := l.roles[uid]
+	if !ok {
+		return r, ErrUnknownUser
+	}
+	return r, nil
+}
+
+// IsAdminUID is true when the last verified token of uid had the is_admin claim
+func (l *Local) IsAdminUID(ctx context.Context, uid string) (bool, error) {
+	r, err := l.rolesOf(uid)
+	return r.Admin, err
+}
+
+// IsProfessional is true when the last verified token of uid had the is_professional claim
+func (l *Local) IsProfessional(ctx context.Context, uid string) (bool, error) {
+	r, err := l.rolesOf(uid)
+	return r.Professional, err
+}
+
+// IsMedia is true when the last verified token of uid had the is_media claim
+func (l *Local) IsMedia(ctx context.Context, uid string) (bool, error) {
+	r, err := l.rolesOf(uid)
+	return r.Media, err
+}
+
+// GetRoleClaims returns the roles of uid in the users file, or of its last verified token
+func (l *Local) GetRoleClaims(ctx context.Context, uid string) (storage.RoleClaims, bool, error) {
+	rc, err := l.rolesOf(uid)
+	return rc, err == nil, err
+}
+
+// SetRoleClaims changes the roles of a user in the users file. The change is kept in
+// memory only and is in the tokens issued afterwards. Tokens of other issuers carry their
+// own claims, for their users ErrUnknownUser is returned.
+func (l *Local) SetRoleClaims(ctx context.Context, uid string, rc storage.RoleClaims) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	found := false
+	for email, u := range l.users {
+		if u.UID == uid {
+			u.IsAdmin, u.IsProfessional, u.IsMedia = rc.Admin, rc.Professional, rc.Media
+			l.users[email] = u
+			found = true
+		}
+	}
+	if !found {
+		return ErrUnknownUser
+	}
+	l.roles[uid] = rc
+	return nil
+}
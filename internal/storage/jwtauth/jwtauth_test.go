@@ -0,0 +1,128 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+var secret = []byte("local-test-secret")
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestVerifyHS256(t *testing.T) {
+	l, err := New(Config{Secret: secret, Issuer: "byrd-staging"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour).Unix()
+
+	token, err := l.VerifyToken(ctx, sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{
+		"sub": "media-1", "iss": "byrd-staging", "exp": exp, "is_media": true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.UID != "media-1" || token.Expires != exp {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if ok, err := l.IsMedia(ctx, "media-1"); !ok || err != nil {
+		t.Errorf("expected media role, got %v %v", ok, err)
+	}
+	if ok, err := l.IsAdminUID(ctx, "media-1"); ok || err != nil {
+		t.Errorf("expected no admin role, got %v %v", ok, err)
+	}
+	if _, err := l.IsProfessional(ctx, "unknown"); err != ErrUnknownUser {
+		t.Errorf("expected %v got %v", ErrUnknownUser, err)
+	}
+
+	invalid := []jwt.MapClaims{
+		{"sub": "media-1", "iss": "byrd-staging", "exp": time.Now().Add(-time.Minute).Unix()},
+		{"sub": "media-1", "iss": "someone-else", "exp": exp},
+		{"iss": "byrd-staging", "exp": exp},
+	}
+	for _, claims := range invalid {
+		if _, err := l.VerifyToken(ctx, sign(t, jwt.SigningMethodHS256, secret, "", claims)); errors.Cause(err) != ErrInvalidToken {
+			t.Errorf("%v: expected %v got %v", claims, ErrInvalidToken, err)
+		}
+	}
+	if _, err := l.VerifyToken(ctx, sign(t, jwt.SigningMethodHS256, []byte("other"), "", jwt.MapClaims{"sub": "x", "iss": "byrd-staging"})); err == nil {
+		t.Error("token signed with another secret was accepted")
+	}
+}
+
+func TestVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	set := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "staging-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	b, _ := json.Marshal(set)
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := New(Config{JWKSFile: path, Audience: "byrd-pro-api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	claims := jwt.MapClaims{"sub": "pro-1", "aud": "byrd-pro-api", "is_professional": true}
+
+	if _, err := l.VerifyToken(ctx, sign(t, jwt.SigningMethodRS256, key, "staging-1", claims)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := l.IsProfessional(ctx, "pro-1"); !ok {
+		t.Error("expected professional role")
+	}
+	if _, err := l.VerifyToken(ctx, sign(t, jwt.SigningMethodRS256, key, "rotated", claims)); errors.Cause(err) != ErrInvalidToken {
+		t.Errorf("unknown kid: expected %v got %v", ErrInvalidToken, err)
+	}
+	// without a secret configured, HS256 tokens must never verify
+	if _, err := l.VerifyToken(ctx, sign(t, jwt.SigningMethodHS256, []byte("guess"), "staging-1", claims)); err == nil {
+		t.Error("HS256 token accepted without a configured secret")
+	}
+}
+
+func TestNewWithoutKeys(t *testing.T) {
+	if _, err := New(Config{}); err != ErrNoKeys {
+		t.Errorf("expected %v got %v", ErrNoKeys, err)
+	}
+}
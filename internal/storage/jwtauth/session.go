@@ -0,0 +1,212 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+const (
+	// DefaultTokenTTL is the lifetime of an issued id token, the same as firebase
+	DefaultTokenTTL = time.Hour
+	// DefaultRefreshTTL is how long an unused refresh token stays valid
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 100000
+	passwordSaltSize   = 16
+)
+
+// ErrBadPasswordHash is returned by LoadUsers when a password is not made by HashPassword
+var ErrBadPasswordHash = errors.New("jwtauth: password hash is not " + passwordScheme)
+
+// User of the users file. Password is the output of HashPassword.
+type User struct {
+	UID            string `json:"uid"`
+	Email          string `json:"email"`
+	Password       string `json:"password"`
+	IsAdmin        bool   `json:"isAdmin"`
+	IsProfessional bool   `json:"isProfessional"`
+	IsMedia        bool   `json:"isMedia"`
+}
+
+// refreshSession points at the user by email, so a refresh picks up changed roles
+type refreshSession struct {
+	uid, email string
+	expiresAt  time.Time
+}
+
+// LoadUsers reads the JSON array of users allowed to sign in
+func LoadUsers(path string) ([]User, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	if err := json.Unmarshal(b, &users); err != nil {
+		return nil, errors.Wrap(err, "jwtauth: decode users")
+	}
+	for _, u := range users {
+		if u.UID == "" || u.Email == "" {
+			return nil, errors.New("jwtauth: users need a uid and an email")
+		}
+		if _, _, _, err := splitPasswordHash(u.Password); err != nil {
+			return nil, errors.Wrapf(err, "jwtauth: user %q", u.UID)
+		}
+	}
+	return users, nil
+}
+
+// HashPassword returns a salted PBKDF2-SHA256 hash of password for the users file
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := passwordKey([]byte(password), salt, passwordIterations)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func splitPasswordHash(encoded string) (iterations int, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return 0, nil, nil, ErrBadPasswordHash
+	}
+	if iterations, err = strconv.Atoi(parts[1]); err != nil || iterations <= 0 {
+		return 0, nil, nil, ErrBadPasswordHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return 0, nil, nil, ErrBadPasswordHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil || len(key) != sha256.Size {
+		return 0, nil, nil, ErrBadPasswordHash
+	}
+	return iterations, salt, key, nil
+}
+
+func checkPassword(encoded, password string) bool {
+	iterations, salt, key, err := splitPasswordHash(encoded)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(passwordKey([]byte(password), salt, iterations), key) == 1
+}
+
+// passwordKey derives the PBKDF2-HMAC-SHA256 key of a password
+func passwordKey(password, salt []byte, iterations int) []byte {
+	return pbkdf2.Key(password, salt, iterations, sha256.Size, sha256.New)
+}
+
+// SignIn checks email and password against the users file and issues a session
+func (l *Local) SignIn(ctx context.Context, email, password string) (*storage.Session, error) {
+	l.mu.RLock()
+	u, ok := l.users[strings.ToLower(strings.TrimSpace(email))]
+	l.mu.RUnlock()
+	if !ok || !checkPassword(u.Password, password) {
+		return nil, storage.ErrInvalidCredentials
+	}
+	return l.issue(u)
+}
+
+// Refresh exchanges a refresh token for a new session. The refresh token is rotated,
+// so it can only be used once.
+func (l *Local) Refresh(ctx context.Context, refreshToken string) (*storage.Session, error) {
+	h := hashRefreshToken(refreshToken)
+	l.mu.Lock()
+	rs, ok := l.refresh[h]
+	delete(l.refresh, h)
+	u, found := l.users[rs.email]
+	l.mu.Unlock()
+	if !ok || !found || u.UID != rs.uid || !l.now().Before(rs.expiresAt) {
+		return nil, storage.ErrInvalidRefreshToken
+	}
+	return l.issue(u)
+}
+
+// RevokeSessions drops the refresh tokens of uid and rejects its id tokens issued before now
+func (l *Local) RevokeSessions(ctx context.Context, uid string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for h, rs := range l.refresh {
+		if rs.uid == uid {
+			delete(l.refresh, h)
+		}
+	}
+	l.revoked[uid] = l.now().Unix()
+	return nil
+}
+
+func (l *Local) issue(u User) (*storage.Session, error) {
+	if len(l.secret) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	now := l.now()
+	claims := jwt.MapClaims{
+		"sub":             u.UID,
+		"iat":             now.Unix(),
+		"exp":             now.Add(l.tokenTTL).Unix(),
+		"email":           u.Email,
+		"is_admin":        u.IsAdmin,
+		"is_professional": u.IsProfessional,
+		"is_media":        u.IsMedia,
+	}
+	if l.issuer != "" {
+		claims["iss"] = l.issuer
+	}
+	if l.audience != "" {
+		claims["aud"] = l.audience
+	}
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(l.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	refreshToken := hex.EncodeToString(b)
+
+	l.mu.Lock()
+	for h, rs := range l.refresh {
+		if !now.Before(rs.expiresAt) {
+			delete(l.refresh, h)
+		}
+	}
+	l.refresh[hashRefreshToken(refreshToken)] = refreshSession{
+		uid:       u.UID,
+		email:     strings.ToLower(u.Email),
+		expiresAt: now.Add(l.refreshTTL),
+	}
+	l.roles[u.UID] = storage.RoleClaims{Admin: u.IsAdmin, Professional: u.IsProfessional, Media: u.IsMedia}
+	l.mu.Unlock()
+
+	return &storage.Session{
+		UID:          u.UID,
+		IDToken:      idToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    now.Add(l.tokenTTL),
+	}, nil
+}
+
+// hashRefreshToken keys the refresh tokens so a dump of the map does not leak usable tokens
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
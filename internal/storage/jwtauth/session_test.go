@@ -0,0 +1,165 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 vectors for P="password", S="salt"
+	tests := []struct {
+		iterations int
+		want       string
+	}{
+		{1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(passwordKey([]byte("password"), []byte("salt"), tt.iterations)); got != tt.want {
+			t.Errorf("%d iterations: expected %v got %v", tt.iterations, tt.want, got)
+		}
+	}
+
+	hash, err := HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkPassword(hash, "hunter2") || checkPassword(hash, "hunter3") {
+		t.Errorf("password check of %v is wrong", hash)
+	}
+}
+
+func newSessionLocal(t *testing.T) *Local {
+	t.Helper()
+	hash, err := HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "jwtauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	b, _ := json.Marshal([]User{{UID: "pro-1", Email: "pro@byrd.news", Password: hash, IsProfessional: true}})
+	path := filepath.Join(dir, "users.json")
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(Config{Secret: secret, Issuer: "byrd-staging", UsersFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestSignInAndRefresh(t *testing.T) {
+	l := newSessionLocal(t)
+	ctx := context.Background()
+
+	if _, err := l.SignIn(ctx, "pro@byrd.news", "wrong"); err != storage.ErrInvalidCredentials {
+		t.Errorf("expected %v got %v", storage.ErrInvalidCredentials, err)
+	}
+	if _, err := l.SignIn(ctx, "nobody@byrd.news", "hunter2"); err != storage.ErrInvalidCredentials {
+		t.Errorf("expected %v got %v", storage.ErrInvalidCredentials, err)
+	}
+
+	sess, err := l.SignIn(ctx, "Pro@Byrd.news", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := l.VerifyToken(ctx, sess.IDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.UID != "pro-1" || token.Issuer != "byrd-staging" {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if ok, err := l.IsProfessional(ctx, "pro-1"); !ok || err != nil {
+		t.Errorf("expected professional role, got %v %v", ok, err)
+	}
+
+	next, err := l.Refresh(ctx, sess.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.UID != "pro-1" || next.RefreshToken == sess.RefreshToken {
+		t.Errorf("unexpected refreshed session %+v", next)
+	}
+	if _, err := l.Refresh(ctx, sess.RefreshToken); err != storage.ErrInvalidRefreshToken {
+		t.Errorf("reused refresh token: expected %v got %v", storage.ErrInvalidRefreshToken, err)
+	}
+
+	l.now = func() time.Time { return time.Now().Add(DefaultRefreshTTL) }
+	if _, err := l.Refresh(ctx, next.RefreshToken); err != storage.ErrInvalidRefreshToken {
+		t.Errorf("expired refresh token: expected %v got %v", storage.ErrInvalidRefreshToken, err)
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	l := newSessionLocal(t)
+	ctx := context.Background()
+
+	// issue the token a second before the revocation, iat has second precision
+	l.now = func() time.Time { return time.Now().Add(-time.Second) }
+	sess, err := l.SignIn(ctx, "pro@byrd.news", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = time.Now
+	if err := l.RevokeSessions(ctx, "pro-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.VerifyToken(ctx, sess.IDToken); errors.Cause(err) != ErrInvalidToken {
+		t.Errorf("expected %v got %v", ErrInvalidToken, err)
+	}
+	if _, err := l.Refresh(ctx, sess.RefreshToken); err != storage.ErrInvalidRefreshToken {
+		t.Errorf("expected %v got %v", storage.ErrInvalidRefreshToken, err)
+	}
+
+	again, err := l.SignIn(ctx, "pro@byrd.news", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.VerifyToken(ctx, again.IDToken); err != nil {
+		t.Errorf("token issued after revocation was rejected: %v", err)
+	}
+}
+
+func TestSetRoleClaims(t *testing.T) {
+	l := newSessionLocal(t)
+	ctx := context.Background()
+
+	sess, err := l.SignIn(ctx, "pro@byrd.news", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetRoleClaims(ctx, "pro-1", storage.RoleClaims{Admin: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetRoleClaims(ctx, "unknown", storage.RoleClaims{Admin: true}); err != ErrUnknownUser {
+		t.Errorf("expected %v got %v", ErrUnknownUser, err)
+	}
+
+	next, err := l.Refresh(ctx, sess.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := l.VerifyToken(ctx, next.IDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, ok := storage.RoleClaimsFrom(token.Claims)
+	if !ok || rc != (storage.RoleClaims{Admin: true}) {
+		t.Errorf("expected only the admin claim, got %+v", rc)
+	}
+}
@@ -1,5 +1,12 @@
 package postgres
 
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
 func (q *Queries) Close() error {
 	return q.Close()
 }
@@ -7,3 +14,19 @@ func (q *Queries) Close() error {
 func (q *Queries) TimeStamp(val int64) {
 
 }
+
+// ExecTx runs fn with queries bound to a single transaction.
+// The transaction is rolled back if fn returns an error and committed otherwise.
+func ExecTx(ctx context.Context, db *sql.DB, fn func(*Queries) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(New(db).WithTx(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Wrapf(err, "rollback failed: %v", rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
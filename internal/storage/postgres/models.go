@@ -3,10 +3,156 @@
 package postgres
 
 import (
+	"database/sql"
+	"fmt"
+	"time"
+
 	"github.com/byrdapp/timestamp/timeparser"
 	"github.com/google/uuid"
 )
 
+type AvailabilityKind string
+
+const (
+	AvailabilityKindAvailable   AvailabilityKind = "available"
+	AvailabilityKindUnavailable AvailabilityKind = "unavailable"
+)
+
+func (e *AvailabilityKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = AvailabilityKind(s)
+	case string:
+		*e = AvailabilityKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for AvailabilityKind: %T", src)
+	}
+	return nil
+}
+
+type BookingStatus string
+
+const (
+	BookingStatusRequested  BookingStatus = "requested"
+	BookingStatusOffered    BookingStatus = "offered"
+	BookingStatusAccepted   BookingStatus = "accepted"
+	BookingStatusInProgress BookingStatus = "in_progress"
+	BookingStatusDelivered  BookingStatus = "delivered"
+	BookingStatusCompleted  BookingStatus = "completed"
+	BookingStatusCancelled  BookingStatus = "cancelled"
+	BookingStatusExpired    BookingStatus = "expired"
+	BookingStatusDisputed   BookingStatus = "disputed"
+)
+
+func (e *BookingStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = BookingStatus(s)
+	case string:
+		*e = BookingStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for BookingStatus: %T", src)
+	}
+	return nil
+}
+
+type OfferStatus string
+
+const (
+	OfferStatusPending   OfferStatus = "pending"
+	OfferStatusAccepted  OfferStatus = "accepted"
+	OfferStatusWithdrawn OfferStatus = "withdrawn"
+	OfferStatusExpired   OfferStatus = "expired"
+)
+
+func (e *OfferStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = OfferStatus(s)
+	case string:
+		*e = OfferStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for OfferStatus: %T", src)
+	}
+	return nil
+}
+
+type CreditAccountKind string
+
+const (
+	CreditAccountKindAvailable CreditAccountKind = "available"
+	CreditAccountKindReserved  CreditAccountKind = "reserved"
+	CreditAccountKindGrants    CreditAccountKind = "grants"
+	CreditAccountKindRevenue   CreditAccountKind = "revenue"
+)
+
+func (e *CreditAccountKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = CreditAccountKind(s)
+	case string:
+		*e = CreditAccountKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for CreditAccountKind: %T", src)
+	}
+	return nil
+}
+
+type CreditTransactionKind string
+
+const (
+	CreditTransactionKindGrant   CreditTransactionKind = "grant"
+	CreditTransactionKindReserve CreditTransactionKind = "reserve"
+	CreditTransactionKindCapture CreditTransactionKind = "capture"
+	CreditTransactionKindRelease CreditTransactionKind = "release"
+)
+
+func (e *CreditTransactionKind) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = CreditTransactionKind(s)
+	case string:
+		*e = CreditTransactionKind(s)
+	default:
+		return fmt.Errorf("unsupported scan type for CreditTransactionKind: %T", src)
+	}
+	return nil
+}
+
+type ApiKey struct {
+	ID         uuid.UUID    `json:"id"`
+	MediaID    string       `json:"media_id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	KeyHash    []byte       `json:"key_hash"`
+	Scopes     []string     `json:"scopes"`
+	CreatedBy  string       `json:"created_by"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
+type AvailabilityException struct {
+	ID             uuid.UUID        `json:"id"`
+	PhotographerID string           `json:"photographer_id"`
+	Kind           AvailabilityKind `json:"kind"`
+	DateStart      time.Time        `json:"date_start"`
+	DateEnd        time.Time        `json:"date_end"`
+	Source         string           `json:"source"`
+	ExternalUid    string           `json:"external_uid"`
+	Summary        string           `json:"summary"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+type AvailabilitySlot struct {
+	ID             uuid.UUID `json:"id"`
+	PhotographerID string    `json:"photographer_id"`
+	Weekday        int16     `json:"weekday"`
+	StartMinute    int32     `json:"start_minute"`
+	EndMinute      int32     `json:"end_minute"`
+	TimeZone       string    `json:"time_zone"`
+}
+
 type Booking struct {
 	ID             uuid.UUID            `json:"id"`
 	MediaID        string               `json:"media_id"`
@@ -14,17 +160,70 @@ type Booking struct {
 	Task           string               `json:"task"`
 	Price          int32                `json:"price"`
 	Credits        int32                `json:"credits"`
-	Accepted       bool                 `json:"accepted"`
-	Completed      bool                 `json:"completed"`
+	PricingVersion string               `json:"pricing_version"`
+	Status         BookingStatus        `json:"status"`
 	DateStart      timeparser.Timestamp `json:"date_start"`
 	DateEnd        timeparser.Timestamp `json:"date_end"`
 	CreatedAt      timeparser.Timestamp `json:"created_at"`
-	Lat            string               `json:"lat"`
-	Lng            string               `json:"lng"`
+	Lat            float64              `json:"lat"`
+	Lng            float64              `json:"lng"`
+	Version        int32                `json:"version"`
+}
+
+type BookingOffer struct {
+	ID             uuid.UUID    `json:"id"`
+	BookingID      uuid.UUID    `json:"booking_id"`
+	PhotographerID string       `json:"photographer_id"`
+	Status         OfferStatus  `json:"status"`
+	ExpiresAt      time.Time    `json:"expires_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+	RespondedAt    sql.NullTime `json:"responded_at"`
+}
+
+type BookingTransition struct {
+	ID         uuid.UUID     `json:"id"`
+	BookingID  uuid.UUID     `json:"booking_id"`
+	FromStatus BookingStatus `json:"from_status"`
+	ToStatus   BookingStatus `json:"to_status"`
+	ActorID    string        `json:"actor_id"`
+	Reason     string        `json:"reason"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type CalendarFeed struct {
+	PhotographerID string    `json:"photographer_id"`
+	TokenHash      []byte    `json:"token_hash"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type CreditAccount struct {
+	ID        uuid.UUID         `json:"id"`
+	OwnerID   string            `json:"owner_id"`
+	Kind      CreditAccountKind `json:"kind"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type CreditEntry struct {
+	ID            uuid.UUID `json:"id"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	AccountID     uuid.UUID `json:"account_id"`
+	Amount        int64     `json:"amount"`
+}
+
+type CreditTransaction struct {
+	ID        uuid.UUID             `json:"id"`
+	Kind      CreditTransactionKind `json:"kind"`
+	Amount    int64                 `json:"amount"`
+	BookingID uuid.NullUUID         `json:"booking_id"`
+	ActorID   string                `json:"actor_id"`
+	Memo      string                `json:"memo"`
+	CreatedAt time.Time             `json:"created_at"`
 }
 
 type Profile struct {
-	ID       uuid.UUID `json:"id"`
-	UserID   string    `json:"user_id"`
-	ProLevel int32     `json:"pro_level"`
+	ID       uuid.UUID       `json:"id"`
+	UserID   string          `json:"user_id"`
+	ProLevel int32           `json:"pro_level"`
+	Lat      sql.NullFloat64 `json:"lat"`
+	Lng      sql.NullFloat64 `json:"lng"`
 }
@@ -5,39 +5,173 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/byrdapp/timestamp/timeparser"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const acceptBooking = `-- name: AcceptBooking :exec
-UPDATE bookings SET accepted = $2 WHERE photographer_id = $1
+UPDATE bookings SET photographer_id = $2, status = 'accepted', price = $3, pricing_version = $4 WHERE id = $1
 `
 
 type AcceptBookingParams struct {
-	PhotographerID string `json:"photographer_id"`
-	Accepted       bool   `json:"accepted"`
+	ID             uuid.UUID `json:"id"`
+	PhotographerID string    `json:"photographer_id"`
+	Price          int32     `json:"price"`
+	PricingVersion string    `json:"pricing_version"`
 }
 
 func (q *Queries) AcceptBooking(ctx context.Context, arg AcceptBookingParams) error {
-	_, err := q.db.ExecContext(ctx, acceptBooking, arg.PhotographerID, arg.Accepted)
+	_, err := q.db.ExecContext(ctx, acceptBooking,
+		arg.ID,
+		arg.PhotographerID,
+		arg.Price,
+		arg.PricingVersion,
+	)
 	return err
 }
 
+const countPendingBookingOffers = `-- name: CountPendingBookingOffers :one
+SELECT COUNT(*) FROM booking_offers WHERE booking_id = $1 AND status = 'pending'
+`
+
+func (q *Queries) CountPendingBookingOffers(ctx context.Context, bookingID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPendingBookingOffers, bookingID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createApiKey = `-- name: CreateApiKey :one
+INSERT INTO api_keys (media_id, name, prefix, key_hash, scopes, created_by)
+    VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, media_id, name, prefix, key_hash, scopes, created_by, created_at, last_used_at, revoked_at
+`
+
+type CreateApiKeyParams struct {
+	MediaID   string   `json:"media_id"`
+	Name      string   `json:"name"`
+	Prefix    string   `json:"prefix"`
+	KeyHash   []byte   `json:"key_hash"`
+	Scopes    []string `json:"scopes"`
+	CreatedBy string   `json:"created_by"`
+}
+
+func (q *Queries) CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createApiKey,
+		arg.MediaID,
+		arg.Name,
+		arg.Prefix,
+		arg.KeyHash,
+		pq.Array(arg.Scopes),
+		arg.CreatedBy,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.MediaID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		pq.Array(&i.Scopes),
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const createAvailabilityException = `-- name: CreateAvailabilityException :one
+INSERT INTO availability_exceptions (photographer_id, kind, date_start, date_end, source, external_uid, summary)
+    VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, photographer_id, kind, date_start, date_end, source, external_uid, summary, created_at
+`
+
+type CreateAvailabilityExceptionParams struct {
+	PhotographerID string           `json:"photographer_id"`
+	Kind           AvailabilityKind `json:"kind"`
+	DateStart      time.Time        `json:"date_start"`
+	DateEnd        time.Time        `json:"date_end"`
+	Source         string           `json:"source"`
+	ExternalUid    string           `json:"external_uid"`
+	Summary        string           `json:"summary"`
+}
+
+func (q *Queries) CreateAvailabilityException(ctx context.Context, arg CreateAvailabilityExceptionParams) (AvailabilityException, error) {
+	row := q.db.QueryRowContext(ctx, createAvailabilityException,
+		arg.PhotographerID,
+		arg.Kind,
+		arg.DateStart,
+		arg.DateEnd,
+		arg.Source,
+		arg.ExternalUid,
+		arg.Summary,
+	)
+	var i AvailabilityException
+	err := row.Scan(
+		&i.ID,
+		&i.PhotographerID,
+		&i.Kind,
+		&i.DateStart,
+		&i.DateEnd,
+		&i.Source,
+		&i.ExternalUid,
+		&i.Summary,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createAvailabilitySlot = `-- name: CreateAvailabilitySlot :one
+INSERT INTO availability_slots (photographer_id, weekday, start_minute, end_minute, time_zone)
+    VALUES ($1, $2, $3, $4, $5) RETURNING id, photographer_id, weekday, start_minute, end_minute, time_zone
+`
+
+type CreateAvailabilitySlotParams struct {
+	PhotographerID string `json:"photographer_id"`
+	Weekday        int16  `json:"weekday"`
+	StartMinute    int32  `json:"start_minute"`
+	EndMinute      int32  `json:"end_minute"`
+	TimeZone       string `json:"time_zone"`
+}
+
+func (q *Queries) CreateAvailabilitySlot(ctx context.Context, arg CreateAvailabilitySlotParams) (AvailabilitySlot, error) {
+	row := q.db.QueryRowContext(ctx, createAvailabilitySlot,
+		arg.PhotographerID,
+		arg.Weekday,
+		arg.StartMinute,
+		arg.EndMinute,
+		arg.TimeZone,
+	)
+	var i AvailabilitySlot
+	err := row.Scan(
+		&i.ID,
+		&i.PhotographerID,
+		&i.Weekday,
+		&i.StartMinute,
+		&i.EndMinute,
+		&i.TimeZone,
+	)
+	return i, err
+}
+
 const createBooking = `-- name: CreateBooking :one
-INSERT INTO bookings (media_id, task, price, credits, date_start, date_end, lat, lng)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id
+INSERT INTO bookings (media_id, task, price, credits, pricing_version, date_start, date_end, lat, lng)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id
 `
 
 type CreateBookingParams struct {
-	MediaID   string               `json:"media_id"`
-	Task      string               `json:"task"`
-	Price     int32                `json:"price"`
-	Credits   int32                `json:"credits"`
-	DateStart timeparser.Timestamp `json:"date_start"`
-	DateEnd   timeparser.Timestamp `json:"date_end"`
-	Lat       string               `json:"lat"`
-	Lng       string               `json:"lng"`
+	MediaID        string               `json:"media_id"`
+	Task           string               `json:"task"`
+	Price          int32                `json:"price"`
+	Credits        int32                `json:"credits"`
+	PricingVersion string               `json:"pricing_version"`
+	DateStart      timeparser.Timestamp `json:"date_start"`
+	DateEnd        timeparser.Timestamp `json:"date_end"`
+	Lat            float64              `json:"lat"`
+	Lng            float64              `json:"lng"`
 }
 
 func (q *Queries) CreateBooking(ctx context.Context, arg CreateBookingParams) (uuid.UUID, error) {
@@ -46,6 +180,7 @@ func (q *Queries) CreateBooking(ctx context.Context, arg CreateBookingParams) (u
 		arg.Task,
 		arg.Price,
 		arg.Credits,
+		arg.PricingVersion,
 		arg.DateStart,
 		arg.DateEnd,
 		arg.Lat,
@@ -56,6 +191,120 @@ func (q *Queries) CreateBooking(ctx context.Context, arg CreateBookingParams) (u
 	return id, err
 }
 
+const createBookingOffer = `-- name: CreateBookingOffer :one
+INSERT INTO booking_offers (booking_id, photographer_id, expires_at)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (booking_id, photographer_id) DO UPDATE
+    SET status = 'pending', expires_at = EXCLUDED.expires_at, responded_at = NULL
+    WHERE booking_offers.status <> 'accepted'
+    RETURNING id, booking_id, photographer_id, status, expires_at, created_at, responded_at
+`
+
+type CreateBookingOfferParams struct {
+	BookingID      uuid.UUID `json:"booking_id"`
+	PhotographerID string    `json:"photographer_id"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateBookingOffer(ctx context.Context, arg CreateBookingOfferParams) (BookingOffer, error) {
+	row := q.db.QueryRowContext(ctx, createBookingOffer, arg.BookingID, arg.PhotographerID, arg.ExpiresAt)
+	var i BookingOffer
+	err := row.Scan(
+		&i.ID,
+		&i.BookingID,
+		&i.PhotographerID,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.RespondedAt,
+	)
+	return i, err
+}
+
+const createBookingTransition = `-- name: CreateBookingTransition :one
+INSERT INTO booking_transitions (booking_id, from_status, to_status, actor_id, reason)
+    VALUES ($1, $2, $3, $4, $5) RETURNING id, booking_id, from_status, to_status, actor_id, reason, created_at
+`
+
+type CreateBookingTransitionParams struct {
+	BookingID  uuid.UUID     `json:"booking_id"`
+	FromStatus BookingStatus `json:"from_status"`
+	ToStatus   BookingStatus `json:"to_status"`
+	ActorID    string        `json:"actor_id"`
+	Reason     string        `json:"reason"`
+}
+
+func (q *Queries) CreateBookingTransition(ctx context.Context, arg CreateBookingTransitionParams) (BookingTransition, error) {
+	row := q.db.QueryRowContext(ctx, createBookingTransition,
+		arg.BookingID,
+		arg.FromStatus,
+		arg.ToStatus,
+		arg.ActorID,
+		arg.Reason,
+	)
+	var i BookingTransition
+	err := row.Scan(
+		&i.ID,
+		&i.BookingID,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.ActorID,
+		&i.Reason,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createCreditEntry = `-- name: CreateCreditEntry :exec
+INSERT INTO credit_entries (transaction_id, account_id, amount)
+    VALUES ($1, $2, $3)
+`
+
+type CreateCreditEntryParams struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	AccountID     uuid.UUID `json:"account_id"`
+	Amount        int64     `json:"amount"`
+}
+
+func (q *Queries) CreateCreditEntry(ctx context.Context, arg CreateCreditEntryParams) error {
+	_, err := q.db.ExecContext(ctx, createCreditEntry, arg.TransactionID, arg.AccountID, arg.Amount)
+	return err
+}
+
+const createCreditTransaction = `-- name: CreateCreditTransaction :one
+INSERT INTO credit_transactions (kind, amount, booking_id, actor_id, memo)
+    VALUES ($1, $2, $3, $4, $5) RETURNING id, kind, amount, booking_id, actor_id, memo, created_at
+`
+
+type CreateCreditTransactionParams struct {
+	Kind      CreditTransactionKind `json:"kind"`
+	Amount    int64                 `json:"amount"`
+	BookingID uuid.NullUUID         `json:"booking_id"`
+	ActorID   string                `json:"actor_id"`
+	Memo      string                `json:"memo"`
+}
+
+func (q *Queries) CreateCreditTransaction(ctx context.Context, arg CreateCreditTransactionParams) (CreditTransaction, error) {
+	row := q.db.QueryRowContext(ctx, createCreditTransaction,
+		arg.Kind,
+		arg.Amount,
+		arg.BookingID,
+		arg.ActorID,
+		arg.Memo,
+	)
+	var i CreditTransaction
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Amount,
+		&i.BookingID,
+		&i.ActorID,
+		&i.Memo,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const createProfile = `-- name: CreateProfile :one
 INSERT INTO profiles (user_id, pro_level)
     VALUES ($1, $2) RETURNING id
@@ -73,6 +322,32 @@ func (q *Queries) CreateProfile(ctx context.Context, arg CreateProfileParams) (u
 	return id, err
 }
 
+const deleteAvailabilityException = `-- name: DeleteAvailabilityException :execrows
+DELETE FROM availability_exceptions WHERE id = $1 AND photographer_id = $2
+`
+
+type DeleteAvailabilityExceptionParams struct {
+	ID             uuid.UUID `json:"id"`
+	PhotographerID string    `json:"photographer_id"`
+}
+
+func (q *Queries) DeleteAvailabilityException(ctx context.Context, arg DeleteAvailabilityExceptionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAvailabilityException, arg.ID, arg.PhotographerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteAvailabilitySlots = `-- name: DeleteAvailabilitySlots :exec
+DELETE FROM availability_slots WHERE photographer_id = $1
+`
+
+func (q *Queries) DeleteAvailabilitySlots(ctx context.Context, photographerID string) error {
+	_, err := q.db.ExecContext(ctx, deleteAvailabilitySlots, photographerID)
+	return err
+}
+
 const deleteBooking = `-- name: DeleteBooking :exec
 DELETE FROM bookings WHERE id = $1
 `
@@ -82,8 +357,128 @@ func (q *Queries) DeleteBooking(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteImportedAvailabilityExceptions = `-- name: DeleteImportedAvailabilityExceptions :exec
+DELETE FROM availability_exceptions WHERE photographer_id = $1 AND source = 'ics'
+`
+
+func (q *Queries) DeleteImportedAvailabilityExceptions(ctx context.Context, photographerID string) error {
+	_, err := q.db.ExecContext(ctx, deleteImportedAvailabilityExceptions, photographerID)
+	return err
+}
+
+const expireBookingOffers = `-- name: ExpireBookingOffers :execrows
+UPDATE booking_offers SET status = 'expired', responded_at = NOW()
+    WHERE booking_id = $1 AND status = 'pending' AND expires_at <= NOW()
+`
+
+func (q *Queries) ExpireBookingOffers(ctx context.Context, bookingID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, expireBookingOffers, bookingID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getApiKeyByHash = `-- name: GetApiKeyByHash :one
+SELECT id, media_id, name, prefix, key_hash, scopes, created_by, created_at, last_used_at, revoked_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetApiKeyByHash(ctx context.Context, keyHash []byte) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, getApiKeyByHash, keyHash)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.MediaID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		pq.Array(&i.Scopes),
+		&i.CreatedBy,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getBooking = `-- name: GetBooking :one
+SELECT id, media_id, photographer_id, task, price, credits, pricing_version, status, date_start, date_end, created_at, lat, lng, version FROM bookings WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetBooking(ctx context.Context, id uuid.UUID) (Booking, error) {
+	row := q.db.QueryRowContext(ctx, getBooking, id)
+	var i Booking
+	err := row.Scan(
+		&i.ID,
+		&i.MediaID,
+		&i.PhotographerID,
+		&i.Task,
+		&i.Price,
+		&i.Credits,
+		&i.PricingVersion,
+		&i.Status,
+		&i.DateStart,
+		&i.DateEnd,
+		&i.CreatedAt,
+		&i.Lat,
+		&i.Lng,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getBookingForUpdate = `-- name: GetBookingForUpdate :one
+SELECT id, media_id, photographer_id, task, price, credits, pricing_version, status, date_start, date_end, created_at, lat, lng, version FROM bookings WHERE id = $1 LIMIT 1 FOR UPDATE
+`
+
+func (q *Queries) GetBookingForUpdate(ctx context.Context, id uuid.UUID) (Booking, error) {
+	row := q.db.QueryRowContext(ctx, getBookingForUpdate, id)
+	var i Booking
+	err := row.Scan(
+		&i.ID,
+		&i.MediaID,
+		&i.PhotographerID,
+		&i.Task,
+		&i.Price,
+		&i.Credits,
+		&i.PricingVersion,
+		&i.Status,
+		&i.DateStart,
+		&i.DateEnd,
+		&i.CreatedAt,
+		&i.Lat,
+		&i.Lng,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getBookingOfferForUpdate = `-- name: GetBookingOfferForUpdate :one
+SELECT id, booking_id, photographer_id, status, expires_at, created_at, responded_at FROM booking_offers WHERE booking_id = $1 AND photographer_id = $2 LIMIT 1 FOR UPDATE
+`
+
+type GetBookingOfferForUpdateParams struct {
+	BookingID      uuid.UUID `json:"booking_id"`
+	PhotographerID string    `json:"photographer_id"`
+}
+
+func (q *Queries) GetBookingOfferForUpdate(ctx context.Context, arg GetBookingOfferForUpdateParams) (BookingOffer, error) {
+	row := q.db.QueryRowContext(ctx, getBookingOfferForUpdate, arg.BookingID, arg.PhotographerID)
+	var i BookingOffer
+	err := row.Scan(
+		&i.ID,
+		&i.BookingID,
+		&i.PhotographerID,
+		&i.Status,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.RespondedAt,
+	)
+	return i, err
+}
+
 const getBookingsByMediaUID = `-- name: GetBookingsByMediaUID :many
-SELECT id, media_id, photographer_id, task, price, credits, accepted, completed, date_start, date_end, created_at, lat, lng FROM bookings WHERE media_id = $1 ORDER BY created_at DESC
+SELECT id, media_id, photographer_id, task, price, credits, pricing_version, status, date_start, date_end, created_at, lat, lng, version FROM bookings WHERE media_id = $1 ORDER BY created_at DESC
 `
 
 func (q *Queries) GetBookingsByMediaUID(ctx context.Context, mediaID string) ([]Booking, error) {
@@ -102,13 +497,14 @@ func (q *Queries) GetBookingsByMediaUID(ctx context.Context, mediaID string) ([]
 			&i.Task,
 			&i.Price,
 			&i.Credits,
-			&i.Accepted,
-			&i.Completed,
+			&i.PricingVersion,
+			&i.Status,
 			&i.DateStart,
 			&i.DateEnd,
 			&i.CreatedAt,
 			&i.Lat,
 			&i.Lng,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -123,65 +519,167 @@ func (q *Queries) GetBookingsByMediaUID(ctx context.Context, mediaID string) ([]
 	return items, nil
 }
 
+const getCalendarFeedTokenHash = `-- name: GetCalendarFeedTokenHash :one
+SELECT token_hash FROM calendar_feeds WHERE photographer_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetCalendarFeedTokenHash(ctx context.Context, photographerID string) ([]byte, error) {
+	row := q.db.QueryRowContext(ctx, getCalendarFeedTokenHash, photographerID)
+	var token_hash []byte
+	err := row.Scan(&token_hash)
+	return token_hash, err
+}
+
+const getCreditAccountBalance = `-- name: GetCreditAccountBalance :one
+SELECT CAST(COALESCE(SUM(amount), 0) AS BIGINT) AS balance FROM credit_entries WHERE account_id = $1
+`
+
+func (q *Queries) GetCreditAccountBalance(ctx context.Context, accountID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getCreditAccountBalance, accountID)
+	var balance int64
+	err := row.Scan(&balance)
+	return balance, err
+}
+
+const getCreditAccountForUpdate = `-- name: GetCreditAccountForUpdate :one
+SELECT id, owner_id, kind, created_at FROM credit_accounts WHERE id = $1 LIMIT 1 FOR UPDATE
+`
+
+func (q *Queries) GetCreditAccountForUpdate(ctx context.Context, id uuid.UUID) (CreditAccount, error) {
+	row := q.db.QueryRowContext(ctx, getCreditAccountForUpdate, id)
+	var i CreditAccount
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Kind,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getCreditTransactionByBooking = `-- name: GetCreditTransactionByBooking :one
+SELECT id, kind, amount, booking_id, actor_id, memo, created_at FROM credit_transactions WHERE booking_id = $1 AND kind = $2 LIMIT 1
+`
+
+type GetCreditTransactionByBookingParams struct {
+	BookingID uuid.NullUUID         `json:"booking_id"`
+	Kind      CreditTransactionKind `json:"kind"`
+}
+
+func (q *Queries) GetCreditTransactionByBooking(ctx context.Context, arg GetCreditTransactionByBookingParams) (CreditTransaction, error) {
+	row := q.db.QueryRowContext(ctx, getCreditTransactionByBooking, arg.BookingID, arg.Kind)
+	var i CreditTransaction
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Amount,
+		&i.BookingID,
+		&i.ActorID,
+		&i.Memo,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getProLevel = `-- name: GetProLevel :one
+SELECT pro_level FROM profiles WHERE user_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetProLevel(ctx context.Context, userID string) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getProLevel, userID)
+	var pro_level int32
+	err := row.Scan(&pro_level)
+	return pro_level, err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, user_id, pro_level FROM profiles WHERE id = $1 LIMIT 1
+SELECT id, user_id, pro_level, lat, lng FROM profiles WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (Profile, error) {
 	row := q.db.QueryRowContext(ctx, getUser, id)
 	var i Profile
-	err := row.Scan(&i.ID, &i.UserID, &i.ProLevel)
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ProLevel,
+		&i.Lat,
+		&i.Lng,
+	)
 	return i, err
 }
 
-const listBookingsByUser = `-- name: ListBookingsByUser :many
-SELECT
-    bookings.task,
-    bookings.credits,
-    bookings.price,
-    bookings.created_at,
-    bookings.accepted,
-    bookings.completed,
-    profiles.pro_level,
-    profiles.user_id
-FROM
-    bookings
-    LEFT JOIN profiles ON bookings.media_id = profiles.user_id
-ORDER BY
-    bookings.created_at DESC,
-    bookings.accepted DESC
-LIMIT 5
+const listApiKeys = `-- name: ListApiKeys :many
+SELECT id, media_id, name, prefix, key_hash, scopes, created_by, created_at, last_used_at, revoked_at FROM api_keys WHERE media_id = $1 ORDER BY created_at DESC
 `
 
-type ListBookingsByUserRow struct {
-	Task      string               `json:"task"`
-	Credits   int32                `json:"credits"`
-	Price     int32                `json:"price"`
-	CreatedAt timeparser.Timestamp `json:"created_at"`
-	Accepted  bool                 `json:"accepted"`
-	Completed bool                 `json:"completed"`
-	ProLevel  int32                `json:"pro_level"`
-	UserID    string               `json:"user_id"`
+func (q *Queries) ListApiKeys(ctx context.Context, mediaID string) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, listApiKeys, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiKey
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.MediaID,
+			&i.Name,
+			&i.Prefix,
+			&i.KeyHash,
+			pq.Array(&i.Scopes),
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-func (q *Queries) ListBookingsByUser(ctx context.Context) ([]ListBookingsByUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, listBookingsByUser)
+const listAvailabilityExceptions = `-- name: ListAvailabilityExceptions :many
+SELECT id, photographer_id, kind, date_start, date_end, source, external_uid, summary, created_at FROM availability_exceptions
+WHERE photographer_id = $1
+    AND date_start < $2::timestamptz
+    AND date_end > $3::timestamptz
+ORDER BY date_start ASC
+`
+
+type ListAvailabilityExceptionsParams struct {
+	PhotographerID string    `json:"photographer_id"`
+	RangeEnd       time.Time `json:"range_end"`
+	RangeStart     time.Time `json:"range_start"`
+}
+
+func (q *Queries) ListAvailabilityExceptions(ctx context.Context, arg ListAvailabilityExceptionsParams) ([]AvailabilityException, error) {
+	rows, err := q.db.QueryContext(ctx, listAvailabilityExceptions, arg.PhotographerID, arg.RangeEnd, arg.RangeStart)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListBookingsByUserRow
+	var items []AvailabilityException
 	for rows.Next() {
-		var i ListBookingsByUserRow
+		var i AvailabilityException
 		if err := rows.Scan(
-			&i.Task,
-			&i.Credits,
-			&i.Price,
+			&i.ID,
+			&i.PhotographerID,
+			&i.Kind,
+			&i.DateStart,
+			&i.DateEnd,
+			&i.Source,
+			&i.ExternalUid,
+			&i.Summary,
 			&i.CreatedAt,
-			&i.Accepted,
-			&i.Completed,
-			&i.ProLevel,
-			&i.UserID,
 		); err != nil {
 			return nil, err
 		}
@@ -196,23 +694,756 @@ func (q *Queries) ListBookingsByUser(ctx context.Context) ([]ListBookingsByUserR
 	return items, nil
 }
 
-const updateBookingStatus = `-- name: UpdateBookingStatus :exec
-UPDATE bookings SET accepted = $2, completed = $3, task = $4 WHERE id = $1
+const listAvailabilitySlots = `-- name: ListAvailabilitySlots :many
+SELECT id, photographer_id, weekday, start_minute, end_minute, time_zone FROM availability_slots WHERE photographer_id = $1 ORDER BY weekday ASC, start_minute ASC
 `
 
-type UpdateBookingStatusParams struct {
-	ID        uuid.UUID `json:"id"`
-	Accepted  bool      `json:"accepted"`
-	Completed bool      `json:"completed"`
-	Task      string    `json:"task"`
+func (q *Queries) ListAvailabilitySlots(ctx context.Context, photographerID string) ([]AvailabilitySlot, error) {
+	rows, err := q.db.QueryContext(ctx, listAvailabilitySlots, photographerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AvailabilitySlot
+	for rows.Next() {
+		var i AvailabilitySlot
+		if err := rows.Scan(
+			&i.ID,
+			&i.PhotographerID,
+			&i.Weekday,
+			&i.StartMinute,
+			&i.EndMinute,
+			&i.TimeZone,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-func (q *Queries) UpdateBookingStatus(ctx context.Context, arg UpdateBookingStatusParams) error {
-	_, err := q.db.ExecContext(ctx, updateBookingStatus,
-		arg.ID,
-		arg.Accepted,
-		arg.Completed,
-		arg.Task,
-	)
+const listBookingOffers = `-- name: ListBookingOffers :many
+SELECT id, booking_id, photographer_id, status, expires_at, created_at, responded_at FROM booking_offers WHERE booking_id = $1 ORDER BY created_at ASC
+`
+
+func (q *Queries) ListBookingOffers(ctx context.Context, bookingID uuid.UUID) ([]BookingOffer, error) {
+	rows, err := q.db.QueryContext(ctx, listBookingOffers, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BookingOffer
+	for rows.Next() {
+		var i BookingOffer
+		if err := rows.Scan(
+			&i.ID,
+			&i.BookingID,
+			&i.PhotographerID,
+			&i.Status,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.RespondedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBookingsByUser = `-- name: ListBookingsByUser :many
+SELECT
+    bookings.task,
+    bookings.credits,
+    bookings.price,
+    bookings.created_at,
+    bookings.status,
+    profiles.pro_level,
+    profiles.user_id
+FROM
+    bookings
+    LEFT JOIN profiles ON bookings.media_id = profiles.user_id
+ORDER BY
+    bookings.created_at DESC
+LIMIT 5
+`
+
+type ListBookingsByUserRow struct {
+	Task      string               `json:"task"`
+	Credits   int32                `json:"credits"`
+	Price     int32                `json:"price"`
+	CreatedAt timeparser.Timestamp `json:"created_at"`
+	Status    BookingStatus        `json:"status"`
+	ProLevel  int32                `json:"pro_level"`
+	UserID    string               `json:"user_id"`
+}
+
+func (q *Queries) ListBookingsByUser(ctx context.Context) ([]ListBookingsByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBookingsByUser)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBookingsByUserRow
+	for rows.Next() {
+		var i ListBookingsByUserRow
+		if err := rows.Scan(
+			&i.Task,
+			&i.Credits,
+			&i.Price,
+			&i.CreatedAt,
+			&i.Status,
+			&i.ProLevel,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBookingsNearby = `-- name: ListBookingsNearby :many
+SELECT id, status, date_start, date_end, lat, lng, distance_km FROM (
+    SELECT
+        id, status, date_start, date_end, lat, lng,
+        CAST(6371 * 2 * ASIN(LEAST(1, SQRT(
+            POWER(SIN(RADIANS(lat - $1::float8) / 2), 2) +
+            COS(RADIANS($1::float8)) * COS(RADIANS(lat)) *
+            POWER(SIN(RADIANS(lng - $2::float8) / 2), 2)
+        ))) AS float8) AS distance_km
+    FROM bookings
+    WHERE lat BETWEEN $3 AND $4
+        AND lng BETWEEN $5 AND $6
+        AND status = ANY($7::booking_status[])
+) AS nearby
+WHERE distance_km <= $8::float8
+ORDER BY distance_km ASC
+LIMIT $9
+`
+
+type ListBookingsNearbyParams struct {
+	Lat        float64         `json:"lat"`
+	Lng        float64         `json:"lng"`
+	MinLat     float64         `json:"min_lat"`
+	MaxLat     float64         `json:"max_lat"`
+	MinLng     float64         `json:"min_lng"`
+	MaxLng     float64         `json:"max_lng"`
+	Statuses   []BookingStatus `json:"statuses"`
+	RadiusKm   float64         `json:"radius_km"`
+	MaxResults int32           `json:"max_results"`
+}
+
+type ListBookingsNearbyRow struct {
+	ID         uuid.UUID            `json:"id"`
+	Status     BookingStatus        `json:"status"`
+	DateStart  timeparser.Timestamp `json:"date_start"`
+	DateEnd    timeparser.Timestamp `json:"date_end"`
+	Lat        float64              `json:"lat"`
+	Lng        float64              `json:"lng"`
+	DistanceKm float64              `json:"distance_km"`
+}
+
+func (q *Queries) ListBookingsNearby(ctx context.Context, arg ListBookingsNearbyParams) ([]ListBookingsNearbyRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBookingsNearby,
+		arg.Lat,
+		arg.Lng,
+		arg.MinLat,
+		arg.MaxLat,
+		arg.MinLng,
+		arg.MaxLng,
+		pq.Array(arg.Statuses),
+		arg.RadiusKm,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBookingsNearbyRow
+	for rows.Next() {
+		var i ListBookingsNearbyRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Status,
+			&i.DateStart,
+			&i.DateEnd,
+			&i.Lat,
+			&i.Lng,
+			&i.DistanceKm,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBookingTransitions = `-- name: ListBookingTransitions :many
+SELECT id, booking_id, from_status, to_status, actor_id, reason, created_at FROM booking_transitions WHERE booking_id = $1 ORDER BY created_at ASC
+`
+
+func (q *Queries) ListBookingTransitions(ctx context.Context, bookingID uuid.UUID) ([]BookingTransition, error) {
+	rows, err := q.db.QueryContext(ctx, listBookingTransitions, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BookingTransition
+	for rows.Next() {
+		var i BookingTransition
+		if err := rows.Scan(
+			&i.ID,
+			&i.BookingID,
+			&i.FromStatus,
+			&i.ToStatus,
+			&i.ActorID,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCreditHistory = `-- name: ListCreditHistory :many
+SELECT
+    credit_transactions.id,
+    credit_transactions.kind,
+    credit_transactions.booking_id,
+    credit_transactions.actor_id,
+    credit_transactions.memo,
+    credit_transactions.created_at,
+    credit_accounts.kind AS account_kind,
+    credit_entries.amount
+FROM
+    credit_entries
+    JOIN credit_accounts ON credit_entries.account_id = credit_accounts.id
+    JOIN credit_transactions ON credit_entries.transaction_id = credit_transactions.id
+WHERE
+    credit_accounts.owner_id = $1
+ORDER BY
+    credit_transactions.created_at DESC
+LIMIT $2
+`
+
+type ListCreditHistoryParams struct {
+	OwnerID string `json:"owner_id"`
+	Limit   int32  `json:"limit"`
+}
+
+type ListCreditHistoryRow struct {
+	ID          uuid.UUID             `json:"id"`
+	Kind        CreditTransactionKind `json:"kind"`
+	BookingID   uuid.NullUUID         `json:"booking_id"`
+	ActorID     string                `json:"actor_id"`
+	Memo        string                `json:"memo"`
+	CreatedAt   time.Time             `json:"created_at"`
+	AccountKind CreditAccountKind     `json:"account_kind"`
+	Amount      int64                 `json:"amount"`
+}
+
+func (q *Queries) ListCreditHistory(ctx context.Context, arg ListCreditHistoryParams) ([]ListCreditHistoryRow, error) {
+	rows, err := q.db.QueryContext(ctx, listCreditHistory, arg.OwnerID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCreditHistoryRow
+	for rows.Next() {
+		var i ListCreditHistoryRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.BookingID,
+			&i.ActorID,
+			&i.Memo,
+			&i.CreatedAt,
+			&i.AccountKind,
+			&i.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPhotographerBookings = `-- name: ListPhotographerBookings :many
+SELECT id, media_id, photographer_id, task, price, credits, pricing_version, status, date_start, date_end, created_at, lat, lng, version FROM bookings
+WHERE photographer_id = $1
+    AND status IN ('accepted', 'in_progress', 'delivered', 'completed', 'disputed')
+    AND date_start < $2::timestamptz
+    AND date_end > $3::timestamptz
+ORDER BY date_start ASC
+`
+
+type ListPhotographerBookingsParams struct {
+	PhotographerID string    `json:"photographer_id"`
+	RangeEnd       time.Time `json:"range_end"`
+	RangeStart     time.Time `json:"range_start"`
+}
+
+func (q *Queries) ListPhotographerBookings(ctx context.Context, arg ListPhotographerBookingsParams) ([]Booking, error) {
+	rows, err := q.db.QueryContext(ctx, listPhotographerBookings, arg.PhotographerID, arg.RangeEnd, arg.RangeStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Booking
+	for rows.Next() {
+		var i Booking
+		if err := rows.Scan(
+			&i.ID,
+			&i.MediaID,
+			&i.PhotographerID,
+			&i.Task,
+			&i.Price,
+			&i.Credits,
+			&i.PricingVersion,
+			&i.Status,
+			&i.DateStart,
+			&i.DateEnd,
+			&i.CreatedAt,
+			&i.Lat,
+			&i.Lng,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPhotographerBusyIntervals = `-- name: ListPhotographerBusyIntervals :many
+SELECT id, status, date_start, date_end FROM bookings
+WHERE photographer_id = $1
+    AND status IN ('accepted', 'in_progress', 'delivered', 'completed', 'disputed')
+    AND date_start < $2::timestamptz
+    AND date_end > $3::timestamptz
+ORDER BY date_start ASC
+`
+
+type ListPhotographerBusyIntervalsParams struct {
+	PhotographerID string    `json:"photographer_id"`
+	RangeEnd       time.Time `json:"range_end"`
+	RangeStart     time.Time `json:"range_start"`
+}
+
+type ListPhotographerBusyIntervalsRow struct {
+	ID        uuid.UUID            `json:"id"`
+	Status    BookingStatus        `json:"status"`
+	DateStart timeparser.Timestamp `json:"date_start"`
+	DateEnd   timeparser.Timestamp `json:"date_end"`
+}
+
+func (q *Queries) ListPhotographerBusyIntervals(ctx context.Context, arg ListPhotographerBusyIntervalsParams) ([]ListPhotographerBusyIntervalsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPhotographerBusyIntervals, arg.PhotographerID, arg.RangeEnd, arg.RangeStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPhotographerBusyIntervalsRow
+	for rows.Next() {
+		var i ListPhotographerBusyIntervalsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Status,
+			&i.DateStart,
+			&i.DateEnd,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPhotographerConflicts = `-- name: ListPhotographerConflicts :many
+SELECT id, media_id, photographer_id, task, price, credits, pricing_version, status, date_start, date_end, created_at, lat, lng, version FROM bookings
+WHERE photographer_id = $1
+    AND id <> $2
+    AND status IN ('accepted', 'in_progress', 'delivered', 'completed', 'disputed')
+    AND date_start < $3::timestamptz
+    AND date_end > $4::timestamptz
+ORDER BY date_start ASC
+`
+
+type ListPhotographerConflictsParams struct {
+	PhotographerID string    `json:"photographer_id"`
+	BookingID      uuid.UUID `json:"booking_id"`
+	DateEnd        time.Time `json:"date_end"`
+	DateStart      time.Time `json:"date_start"`
+}
+
+func (q *Queries) ListPhotographerConflicts(ctx context.Context, arg ListPhotographerConflictsParams) ([]Booking, error) {
+	rows, err := q.db.QueryContext(ctx, listPhotographerConflicts,
+		arg.PhotographerID,
+		arg.BookingID,
+		arg.DateEnd,
+		arg.DateStart,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Booking
+	for rows.Next() {
+		var i Booking
+		if err := rows.Scan(
+			&i.ID,
+			&i.MediaID,
+			&i.PhotographerID,
+			&i.Task,
+			&i.Price,
+			&i.Credits,
+			&i.PricingVersion,
+			&i.Status,
+			&i.DateStart,
+			&i.DateEnd,
+			&i.CreatedAt,
+			&i.Lat,
+			&i.Lng,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProfilesNearby = `-- name: ListProfilesNearby :many
+SELECT id, user_id, pro_level, lat, lng, distance_km FROM (
+    SELECT
+        profiles.id, profiles.user_id, profiles.pro_level, profiles.lat, profiles.lng,
+        CAST(6371 * 2 * ASIN(LEAST(1, SQRT(
+            POWER(SIN(RADIANS(lat - $1::float8) / 2), 2) +
+            COS(RADIANS($1::float8)) * COS(RADIANS(lat)) *
+            POWER(SIN(RADIANS(lng - $2::float8) / 2), 2)
+        ))) AS float8) AS distance_km
+    FROM profiles
+    WHERE lat BETWEEN $3 AND $4
+        AND lng BETWEEN $5 AND $6
+) AS nearby
+WHERE distance_km <= $7::float8
+ORDER BY distance_km ASC
+LIMIT $8
+`
+
+type ListProfilesNearbyParams struct {
+	Lat        float64 `json:"lat"`
+	Lng        float64 `json:"lng"`
+	MinLat     float64 `json:"min_lat"`
+	MaxLat     float64 `json:"max_lat"`
+	MinLng     float64 `json:"min_lng"`
+	MaxLng     float64 `json:"max_lng"`
+	RadiusKm   float64 `json:"radius_km"`
+	MaxResults int32   `json:"max_results"`
+}
+
+type ListProfilesNearbyRow struct {
+	ID         uuid.UUID       `json:"id"`
+	UserID     string          `json:"user_id"`
+	ProLevel   int32           `json:"pro_level"`
+	Lat        sql.NullFloat64 `json:"lat"`
+	Lng        sql.NullFloat64 `json:"lng"`
+	DistanceKm float64         `json:"distance_km"`
+}
+
+func (q *Queries) ListProfilesNearby(ctx context.Context, arg ListProfilesNearbyParams) ([]ListProfilesNearbyRow, error) {
+	rows, err := q.db.QueryContext(ctx, listProfilesNearby,
+		arg.Lat,
+		arg.Lng,
+		arg.MinLat,
+		arg.MaxLat,
+		arg.MinLng,
+		arg.MaxLng,
+		arg.RadiusKm,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListProfilesNearbyRow
+	for rows.Next() {
+		var i ListProfilesNearbyRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProLevel,
+			&i.Lat,
+			&i.Lng,
+			&i.DistanceKm,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockPhotographer = `-- name: LockPhotographer :exec
+SELECT pg_advisory_xact_lock(hashtext($1))
+`
+
+func (q *Queries) LockPhotographer(ctx context.Context, hashtext string) error {
+	_, err := q.db.ExecContext(ctx, lockPhotographer, hashtext)
+	return err
+}
+
+const revokeApiKey = `-- name: RevokeApiKey :execrows
+UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND media_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeApiKeyParams struct {
+	ID      uuid.UUID `json:"id"`
+	MediaID string    `json:"media_id"`
+}
+
+func (q *Queries) RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeApiKey, arg.ID, arg.MediaID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeMediaApiKeys = `-- name: RevokeMediaApiKeys :exec
+UPDATE api_keys SET revoked_at = NOW() WHERE media_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeMediaApiKeys(ctx context.Context, mediaID string) error {
+	_, err := q.db.ExecContext(ctx, revokeMediaApiKeys, mediaID)
+	return err
+}
+
+const touchApiKey = `-- name: TouchApiKey :exec
+UPDATE api_keys SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+`
+
+func (q *Queries) TouchApiKey(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchApiKey, id)
+	return err
+}
+
+const updateBooking = `-- name: UpdateBooking :one
+UPDATE bookings SET
+    task = $1,
+    date_start = $2::timestamptz,
+    date_end = $3::timestamptz,
+    lat = $4,
+    lng = $5,
+    price = $6,
+    pricing_version = $7,
+    version = version + 1
+WHERE id = $8 AND version = $9
+RETURNING id, media_id, photographer_id, task, price, credits, pricing_version, status, date_start, date_end, created_at, lat, lng, version
+`
+
+type UpdateBookingParams struct {
+	Task           string    `json:"task"`
+	DateStart      time.Time `json:"date_start"`
+	DateEnd        time.Time `json:"date_end"`
+	Lat            float64   `json:"lat"`
+	Lng            float64   `json:"lng"`
+	Price          int32     `json:"price"`
+	PricingVersion string    `json:"pricing_version"`
+	ID             uuid.UUID `json:"id"`
+	Version        int32     `json:"version"`
+}
+
+func (q *Queries) UpdateBooking(ctx context.Context, arg UpdateBookingParams) (Booking, error) {
+	row := q.db.QueryRowContext(ctx, updateBooking,
+		arg.Task,
+		arg.DateStart,
+		arg.DateEnd,
+		arg.Lat,
+		arg.Lng,
+		arg.Price,
+		arg.PricingVersion,
+		arg.ID,
+		arg.Version,
+	)
+	var i Booking
+	err := row.Scan(
+		&i.ID,
+		&i.MediaID,
+		&i.PhotographerID,
+		&i.Task,
+		&i.Price,
+		&i.Credits,
+		&i.PricingVersion,
+		&i.Status,
+		&i.DateStart,
+		&i.DateEnd,
+		&i.CreatedAt,
+		&i.Lat,
+		&i.Lng,
+		&i.Version,
+	)
+	return i, err
+}
+
+const updateBookingOfferStatus = `-- name: UpdateBookingOfferStatus :exec
+UPDATE booking_offers SET status = $2, responded_at = NOW() WHERE id = $1
+`
+
+type UpdateBookingOfferStatusParams struct {
+	ID     uuid.UUID   `json:"id"`
+	Status OfferStatus `json:"status"`
+}
+
+func (q *Queries) UpdateBookingOfferStatus(ctx context.Context, arg UpdateBookingOfferStatusParams) error {
+	_, err := q.db.ExecContext(ctx, updateBookingOfferStatus, arg.ID, arg.Status)
+	return err
+}
+
+const updateBookingStatus = `-- name: UpdateBookingStatus :exec
+UPDATE bookings SET status = $2 WHERE id = $1
+`
+
+type UpdateBookingStatusParams struct {
+	ID     uuid.UUID     `json:"id"`
+	Status BookingStatus `json:"status"`
+}
+
+func (q *Queries) UpdateBookingStatus(ctx context.Context, arg UpdateBookingStatusParams) error {
+	_, err := q.db.ExecContext(ctx, updateBookingStatus, arg.ID, arg.Status)
+	return err
+}
+
+const updateProfileLocation = `-- name: UpdateProfileLocation :exec
+UPDATE profiles SET lat = $2, lng = $3 WHERE user_id = $1
+`
+
+type UpdateProfileLocationParams struct {
+	UserID string          `json:"user_id"`
+	Lat    sql.NullFloat64 `json:"lat"`
+	Lng    sql.NullFloat64 `json:"lng"`
+}
+
+func (q *Queries) UpdateProfileLocation(ctx context.Context, arg UpdateProfileLocationParams) error {
+	_, err := q.db.ExecContext(ctx, updateProfileLocation, arg.UserID, arg.Lat, arg.Lng)
+	return err
+}
+
+const upsertCalendarFeed = `-- name: UpsertCalendarFeed :exec
+INSERT INTO calendar_feeds (photographer_id, token_hash)
+    VALUES ($1, $2)
+ON CONFLICT (photographer_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = NOW()
+`
+
+type UpsertCalendarFeedParams struct {
+	PhotographerID string `json:"photographer_id"`
+	TokenHash      []byte `json:"token_hash"`
+}
+
+func (q *Queries) UpsertCalendarFeed(ctx context.Context, arg UpsertCalendarFeedParams) error {
+	_, err := q.db.ExecContext(ctx, upsertCalendarFeed, arg.PhotographerID, arg.TokenHash)
+	return err
+}
+
+const upsertCreditAccount = `-- name: UpsertCreditAccount :one
+INSERT INTO credit_accounts (owner_id, kind)
+    VALUES ($1, $2)
+    ON CONFLICT (owner_id, kind) DO UPDATE SET owner_id = EXCLUDED.owner_id
+    RETURNING id, owner_id, kind, created_at
+`
+
+type UpsertCreditAccountParams struct {
+	OwnerID string            `json:"owner_id"`
+	Kind    CreditAccountKind `json:"kind"`
+}
+
+func (q *Queries) UpsertCreditAccount(ctx context.Context, arg UpsertCreditAccountParams) (CreditAccount, error) {
+	row := q.db.QueryRowContext(ctx, upsertCreditAccount, arg.OwnerID, arg.Kind)
+	var i CreditAccount
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Kind,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const withdrawOtherBookingOffers = `-- name: WithdrawOtherBookingOffers :exec
+UPDATE booking_offers SET status = 'withdrawn', responded_at = NOW()
+    WHERE booking_id = $1 AND id <> $2 AND status = 'pending'
+`
+
+type WithdrawOtherBookingOffersParams struct {
+	BookingID uuid.UUID `json:"booking_id"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func (q *Queries) WithdrawOtherBookingOffers(ctx context.Context, arg WithdrawOtherBookingOffersParams) error {
+	_, err := q.db.ExecContext(ctx, withdrawOtherBookingOffers, arg.BookingID, arg.ID)
 	return err
 }
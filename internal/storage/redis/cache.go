@@ -0,0 +1,85 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+
+	"github.com/byrdapp/byrd-pro-api/internal/cache"
+)
+
+// Cache is a cache.Cache shared by the instances, keys are stored under cache:<key>
+type Cache struct {
+	client *goredis.Client
+	prefix string
+}
+
+var _ cache.Cache = (*Cache)(nil)
+
+// NewCache creates a cache on the client
+func NewCache(c *goredis.Client) *Cache {
+	return &Cache{client: c, prefix: "cache:"}
+}
+
+func (c *Cache) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.prefix + key
+	}
+	return prefixed
+}
+
+// Get is GET key
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := c.client.WithContext(ctx).Get(c.prefix + key).Bytes()
+	if err == goredis.Nil {
+		return nil, cache.ErrMiss
+	}
+	return b, err
+}
+
+// Set is SET key value PX ttl
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.WithContext(ctx).Set(c.prefix+key, value, ttl).Err()
+}
+
+// Exists is EXISTS key
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := c.client.WithContext(ctx).Exists(c.prefix + key).Result()
+	return n > 0, err
+}
+
+// Incr is INCRBY key delta
+func (c *Cache) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	n, err := c.client.WithContext(ctx).IncrBy(c.prefix+key, delta).Result()
+	if err != nil && strings.Contains(err.Error(), "not an integer") {
+		return 0, cache.ErrNotInteger
+	}
+	return n, err
+}
+
+// MSet sets all keys in one transaction, MSET itself has no ttl
+func (c *Cache) MSet(ctx context.Context, values map[string][]byte, ttl time.Duration) error {
+	_, err := c.client.WithContext(ctx).TxPipelined(func(pipe goredis.Pipeliner) error {
+		for key, value := range values {
+			pipe.Set(c.prefix+key, value, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// Append is APPEND key value
+func (c *Cache) Append(ctx context.Context, key string, value []byte) (int64, error) {
+	return c.client.WithContext(ctx).Append(c.prefix+key, string(value)).Result()
+}
+
+// Del is DEL keys...
+func (c *Cache) Del(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return c.client.WithContext(ctx).Del(c.keys(keys)...).Result()
+}
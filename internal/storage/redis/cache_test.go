@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/cache"
+)
+
+// needs a redis server, e.g. REDIS_TEST_URL=redis://localhost:6379/15
+func TestCache(t *testing.T) {
+	url := os.Getenv("REDIS_TEST_URL")
+	if url == "" {
+		t.Skip("REDIS_TEST_URL is not set")
+	}
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	c := NewCache(client)
+	ctx := context.Background()
+	key := uuid.New().String()
+
+	if _, err := c.Get(ctx, key); err != cache.ErrMiss {
+		t.Errorf("expected %v got %v", cache.ErrMiss, err)
+	}
+	if err := c.Set(ctx, key, []byte("simon"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Incr(ctx, key, 1); err != cache.ErrNotInteger {
+		t.Errorf("expected %v got %v", cache.ErrNotInteger, err)
+	}
+	if n, _ := c.Append(ctx, key, []byte("!")); n != 6 {
+		t.Errorf("expected length 6 got %d", n)
+	}
+	if n, _ := c.Del(ctx, key, key+"-missing"); n != 1 {
+		t.Errorf("expected 1 deleted key got %d", n)
+	}
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/pkg/errors"
+
+	"github.com/byrdapp/byrd-pro-api/internal/ratelimit"
+)
+
+// takeScript is ratelimit.Take on a hash of tokens and ts (unix millis), atomic across instances.
+// ARGV: burst, millis to refill a token, now in unix millis, ttl in millis.
+// The tokens are returned as a string, redis truncates lua numbers to integers.
+var takeScript = goredis.NewScript(`
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local last = tonumber(bucket[2]) or now
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) / interval)
+	last = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', last)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {allowed, tostring(tokens)}
+`)
+
+// RateLimitStore keeps token buckets in redis so instances share the limits
+type RateLimitStore struct {
+	client *goredis.Client
+	prefix string
+	now    func() time.Time
+}
+
+var _ ratelimit.Store = (*RateLimitStore)(nil)
+
+// NewRateLimitStore stores the buckets under ratelimit:<key>
+func NewRateLimitStore(c *goredis.Client) *RateLimitStore {
+	return &RateLimitStore{client: c, prefix: "ratelimit:", now: time.Now}
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Take takes a token from the bucket of key. A bucket expires once it would be full again.
+func (s *RateLimitStore) Take(ctx context.Context, key string, l ratelimit.Limit) (ratelimit.Result, error) {
+	res, err := takeScript.Run(s.client.WithContext(ctx), []string{s.prefix + key},
+		l.Burst,
+		strconv.FormatFloat(millis(l.Per)/float64(l.Burst), 'f', -1, 64),
+		s.now().UnixNano()/int64(time.Millisecond),
+		int64(millis(l.Per))+1,
+	).Result()
+	if err != nil {
+		return ratelimit.Result{}, errors.Wrap(err, "redis: take token")
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 2 {
+		return ratelimit.Result{}, errors.Errorf("redis: unexpected take result %v", res)
+	}
+	allowed, _ := values[0].(int64)
+	str, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return ratelimit.Result{}, errors.Wrap(err, "redis: parse tokens")
+	}
+	return ratelimit.ResultOf(tokens, allowed == 1, l), nil
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/byrdapp/byrd-pro-api/internal/ratelimit"
+)
+
+// needs a redis server, e.g. REDIS_TEST_URL=redis://localhost:6379/15
+func TestRateLimitStore(t *testing.T) {
+	url := os.Getenv("REDIS_TEST_URL")
+	if url == "" {
+		t.Skip("REDIS_TEST_URL is not set")
+	}
+	c, err := NewClient(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	now := time.Now()
+	s := NewRateLimitStore(c)
+	s.now = func() time.Time { return now }
+	key := uuid.New().String()
+	l := ratelimit.Limit{Burst: 2, Per: 2 * time.Second}
+	ctx := context.Background()
+
+	for i := 1; i >= 0; i-- {
+		r, err := s.Take(ctx, key, l)
+		if err != nil || !r.Allowed || r.Remaining != i {
+			t.Errorf("unexpected %+v %v", r, err)
+		}
+	}
+	if r, _ := s.Take(ctx, key, l); r.Allowed || r.RetryAfter != time.Second {
+		t.Errorf("expected refusal with a retry after 1s, got %+v", r)
+	}
+	now = now.Add(time.Second)
+	if r, _ := s.Take(ctx, key, l); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("expected a refilled token, got %+v", r)
+	}
+}
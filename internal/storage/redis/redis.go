@@ -1,21 +1,20 @@
-package storage
+// Package redis holds the Redis backed stores shared by every instance of the API.
+package redis
 
-// func initRedis() {
+import (
+	goredis "github.com/go-redis/redis"
+)
 
-// }
-
-/**
- * SET key value expiration? -> void
- * GET key -> value
- * EXISTS key -> value
- * INCR / DECR key -> value ++ / --
- *
- * SET object:key value => void
- * GET object:key => value
- *
- * MSET ...key value[] => void
- *
- * APPEND existing_key value => void (add value to existing key)
- *
- * DEL key => 1
- */
+// NewClient connects to the Redis server of url, e.g. redis://:password@host:6379/0
+func NewClient(url string) (*goredis.Client, error) {
+	opt, err := goredis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	c := goredis.NewClient(opt)
+	if err := c.Ping().Err(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
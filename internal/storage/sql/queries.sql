@@ -1,27 +1,89 @@
 
 
 -- name: UpdateBookingStatus :exec
-UPDATE bookings SET accepted = $2, completed = $3, task = $4 WHERE id = $1;
+UPDATE bookings SET status = $2 WHERE id = $1;
+
+-- name: UpdateBooking :one
+UPDATE bookings SET
+    task = sqlc.arg(task),
+    date_start = sqlc.arg(date_start)::timestamptz,
+    date_end = sqlc.arg(date_end)::timestamptz,
+    lat = sqlc.arg(lat),
+    lng = sqlc.arg(lng),
+    price = sqlc.arg(price),
+    pricing_version = sqlc.arg(pricing_version),
+    version = version + 1
+WHERE id = sqlc.arg(id) AND version = sqlc.arg(version)
+RETURNING *;
 
 -- name: DeleteBooking :exec
 DELETE FROM bookings WHERE id = $1;
 
+-- name: GetBooking :one
+SELECT * FROM bookings WHERE id = $1 LIMIT 1;
+
+-- name: GetBookingForUpdate :one
+SELECT * FROM bookings WHERE id = $1 LIMIT 1 FOR UPDATE;
+
 -- name: GetBookingsByMediaUID :many
 SELECT * FROM bookings WHERE media_id = $1 ORDER BY created_at DESC;
 
 -- name: GetUser :one
 SELECT * FROM profiles WHERE id = $1 LIMIT 1;
 
+-- name: GetProLevel :one
+SELECT pro_level FROM profiles WHERE user_id = $1 LIMIT 1;
+
+-- name: UpdateProfileLocation :exec
+UPDATE profiles SET lat = $2, lng = $3 WHERE user_id = $1;
+
 -- name: CreateProfile :one
 INSERT INTO profiles (user_id, pro_level)
     VALUES ($1, $2) RETURNING id;
 
 -- name: CreateBooking :one
-INSERT INTO bookings (media_id, task, price, credits, date_start, date_end, lat, lng)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id;
+INSERT INTO bookings (media_id, task, price, credits, pricing_version, date_start, date_end, lat, lng)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;
 
 -- name: AcceptBooking :exec
-UPDATE bookings SET accepted = $2 WHERE photographer_id = $1;
+UPDATE bookings SET photographer_id = $2, status = 'accepted', price = $3, pricing_version = $4 WHERE id = $1;
+
+-- Offering a professional again re-arms their pending, expired or withdrawn offer with the new expiry.
+
+-- name: CreateBookingOffer :one
+INSERT INTO booking_offers (booking_id, photographer_id, expires_at)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (booking_id, photographer_id) DO UPDATE
+    SET status = 'pending', expires_at = EXCLUDED.expires_at, responded_at = NULL
+    WHERE booking_offers.status <> 'accepted'
+    RETURNING *;
+
+-- name: ExpireBookingOffers :execrows
+UPDATE booking_offers SET status = 'expired', responded_at = NOW()
+    WHERE booking_id = $1 AND status = 'pending' AND expires_at <= NOW();
+
+-- name: CountPendingBookingOffers :one
+SELECT COUNT(*) FROM booking_offers WHERE booking_id = $1 AND status = 'pending';
+
+-- name: GetBookingOfferForUpdate :one
+SELECT * FROM booking_offers WHERE booking_id = $1 AND photographer_id = $2 LIMIT 1 FOR UPDATE;
+
+-- name: ListBookingOffers :many
+SELECT * FROM booking_offers WHERE booking_id = $1 ORDER BY created_at ASC;
+
+-- name: UpdateBookingOfferStatus :exec
+UPDATE booking_offers SET status = $2, responded_at = NOW() WHERE id = $1;
+
+-- name: WithdrawOtherBookingOffers :exec
+UPDATE booking_offers SET status = 'withdrawn', responded_at = NOW()
+    WHERE booking_id = $1 AND id <> $2 AND status = 'pending';
+
+-- name: CreateBookingTransition :one
+INSERT INTO booking_transitions (booking_id, from_status, to_status, actor_id, reason)
+    VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: ListBookingTransitions :many
+SELECT * FROM booking_transitions WHERE booking_id = $1 ORDER BY created_at ASC;
 
 -- name: ListBookingsByUser :many
 SELECT
@@ -29,14 +91,178 @@ SELECT
     bookings.credits,
     bookings.price,
     bookings.created_at,
-    bookings.accepted,
-    bookings.completed,
+    bookings.status,
     profiles.pro_level,
     profiles.user_id
 FROM
     bookings
     LEFT JOIN profiles ON bookings.media_id = profiles.user_id
 ORDER BY
-    bookings.created_at DESC,
-    bookings.accepted DESC
+    bookings.created_at DESC
 LIMIT 5;
+
+-- Nearby queries prefilter on a bounding box so the (lat, lng) index can be used,
+-- then compute the great-circle (haversine) distance in km and keep rows within the radius.
+
+-- name: ListBookingsNearby :many
+SELECT * FROM (
+    SELECT
+        id, status, date_start, date_end, lat, lng,
+        CAST(6371 * 2 * ASIN(LEAST(1, SQRT(
+            POWER(SIN(RADIANS(lat - sqlc.arg(lat)::float8) / 2), 2) +
+            COS(RADIANS(sqlc.arg(lat)::float8)) * COS(RADIANS(lat)) *
+            POWER(SIN(RADIANS(lng - sqlc.arg(lng)::float8) / 2), 2)
+        ))) AS float8) AS distance_km
+    FROM bookings
+    WHERE lat BETWEEN sqlc.arg(min_lat) AND sqlc.arg(max_lat)
+        AND lng BETWEEN sqlc.arg(min_lng) AND sqlc.arg(max_lng)
+        AND status = ANY(sqlc.arg(statuses)::booking_status[])
+) AS nearby
+WHERE distance_km <= sqlc.arg(radius_km)::float8
+ORDER BY distance_km ASC
+LIMIT sqlc.arg(max_results);
+
+-- name: ListProfilesNearby :many
+SELECT * FROM (
+    SELECT
+        profiles.*,
+        CAST(6371 * 2 * ASIN(LEAST(1, SQRT(
+            POWER(SIN(RADIANS(lat - sqlc.arg(lat)::float8) / 2), 2) +
+            COS(RADIANS(sqlc.arg(lat)::float8)) * COS(RADIANS(lat)) *
+            POWER(SIN(RADIANS(lng - sqlc.arg(lng)::float8) / 2), 2)
+        ))) AS float8) AS distance_km
+    FROM profiles
+    WHERE lat BETWEEN sqlc.arg(min_lat) AND sqlc.arg(max_lat)
+        AND lng BETWEEN sqlc.arg(min_lng) AND sqlc.arg(max_lng)
+) AS nearby
+WHERE distance_km <= sqlc.arg(radius_km)::float8
+ORDER BY distance_km ASC
+LIMIT sqlc.arg(max_results);
+
+-- name: UpsertCreditAccount :one
+INSERT INTO credit_accounts (owner_id, kind)
+    VALUES ($1, $2)
+    ON CONFLICT (owner_id, kind) DO UPDATE SET owner_id = EXCLUDED.owner_id
+    RETURNING *;
+
+-- name: GetCreditAccountForUpdate :one
+SELECT * FROM credit_accounts WHERE id = $1 LIMIT 1 FOR UPDATE;
+
+-- name: GetCreditAccountBalance :one
+SELECT CAST(COALESCE(SUM(amount), 0) AS BIGINT) AS balance FROM credit_entries WHERE account_id = $1;
+
+-- name: CreateCreditTransaction :one
+INSERT INTO credit_transactions (kind, amount, booking_id, actor_id, memo)
+    VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: CreateCreditEntry :exec
+INSERT INTO credit_entries (transaction_id, account_id, amount)
+    VALUES ($1, $2, $3);
+
+-- name: GetCreditTransactionByBooking :one
+SELECT * FROM credit_transactions WHERE booking_id = $1 AND kind = $2 LIMIT 1;
+
+-- name: ListCreditHistory :many
+SELECT
+    credit_transactions.id,
+    credit_transactions.kind,
+    credit_transactions.booking_id,
+    credit_transactions.actor_id,
+    credit_transactions.memo,
+    credit_transactions.created_at,
+    credit_accounts.kind AS account_kind,
+    credit_entries.amount
+FROM
+    credit_entries
+    JOIN credit_accounts ON credit_entries.account_id = credit_accounts.id
+    JOIN credit_transactions ON credit_entries.transaction_id = credit_transactions.id
+WHERE
+    credit_accounts.owner_id = $1
+ORDER BY
+    credit_transactions.created_at DESC
+LIMIT $2;
+
+-- A photographer is busy during every booking they are assigned to that was not cancelled or expired.
+
+-- name: LockPhotographer :exec
+SELECT pg_advisory_xact_lock(hashtext($1));
+
+-- name: ListPhotographerConflicts :many
+SELECT * FROM bookings
+WHERE photographer_id = sqlc.arg(photographer_id)
+    AND id <> sqlc.arg(booking_id)
+    AND status IN ('accepted', 'in_progress', 'delivered', 'completed', 'disputed')
+    AND date_start < sqlc.arg(date_end)::timestamptz
+    AND date_end > sqlc.arg(date_start)::timestamptz
+ORDER BY date_start ASC;
+
+-- name: ListPhotographerBusyIntervals :many
+SELECT id, status, date_start, date_end FROM bookings
+WHERE photographer_id = sqlc.arg(photographer_id)
+    AND status IN ('accepted', 'in_progress', 'delivered', 'completed', 'disputed')
+    AND date_start < sqlc.arg(range_end)::timestamptz
+    AND date_end > sqlc.arg(range_start)::timestamptz
+ORDER BY date_start ASC;
+
+-- name: ListPhotographerBookings :many
+SELECT * FROM bookings
+WHERE photographer_id = sqlc.arg(photographer_id)
+    AND status IN ('accepted', 'in_progress', 'delivered', 'completed', 'disputed')
+    AND date_start < sqlc.arg(range_end)::timestamptz
+    AND date_end > sqlc.arg(range_start)::timestamptz
+ORDER BY date_start ASC;
+
+-- name: ListAvailabilitySlots :many
+SELECT * FROM availability_slots WHERE photographer_id = $1 ORDER BY weekday ASC, start_minute ASC;
+
+-- name: DeleteAvailabilitySlots :exec
+DELETE FROM availability_slots WHERE photographer_id = $1;
+
+-- name: CreateAvailabilitySlot :one
+INSERT INTO availability_slots (photographer_id, weekday, start_minute, end_minute, time_zone)
+    VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: ListAvailabilityExceptions :many
+SELECT * FROM availability_exceptions
+WHERE photographer_id = sqlc.arg(photographer_id)
+    AND date_start < sqlc.arg(range_end)::timestamptz
+    AND date_end > sqlc.arg(range_start)::timestamptz
+ORDER BY date_start ASC;
+
+-- name: CreateAvailabilityException :one
+INSERT INTO availability_exceptions (photographer_id, kind, date_start, date_end, source, external_uid, summary)
+    VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
+
+-- name: DeleteAvailabilityException :execrows
+DELETE FROM availability_exceptions WHERE id = $1 AND photographer_id = $2;
+
+-- name: DeleteImportedAvailabilityExceptions :exec
+DELETE FROM availability_exceptions WHERE photographer_id = $1 AND source = 'ics';
+
+-- name: UpsertCalendarFeed :exec
+INSERT INTO calendar_feeds (photographer_id, token_hash)
+    VALUES ($1, $2)
+ON CONFLICT (photographer_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = NOW();
+
+-- name: GetCalendarFeedTokenHash :one
+SELECT token_hash FROM calendar_feeds WHERE photographer_id = $1 LIMIT 1;
+
+-- name: CreateApiKey :one
+INSERT INTO api_keys (media_id, name, prefix, key_hash, scopes, created_by)
+    VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: GetApiKeyByHash :one
+SELECT * FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL LIMIT 1;
+
+-- name: ListApiKeys :many
+SELECT * FROM api_keys WHERE media_id = $1 ORDER BY created_at DESC;
+
+-- name: RevokeApiKey :execrows
+UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND media_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeMediaApiKeys :exec
+UPDATE api_keys SET revoked_at = NOW() WHERE media_id = $1 AND revoked_at IS NULL;
+
+-- name: TouchApiKey :exec
+UPDATE api_keys SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
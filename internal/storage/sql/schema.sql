@@ -1,21 +1,185 @@
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
 CREATE TABLE IF NOT EXISTS profiles (
     id uuid PRIMARY KEY NOT NULL,
     user_id VARCHAR(40) NOT NULL,
-    pro_level INTEGER NOT NULL
+    pro_level INTEGER NOT NULL,
+    lat DOUBLE PRECISION CHECK (lat BETWEEN -90 AND 90),
+    lng DOUBLE PRECISION CHECK (lng BETWEEN -180 AND 180)
+);
+
+CREATE INDEX IF NOT EXISTS profiles_lat_lng_idx ON profiles (lat, lng);
+
+CREATE TYPE booking_status AS ENUM (
+    'requested',
+    'offered',
+    'accepted',
+    'in_progress',
+    'delivered',
+    'completed',
+    'cancelled',
+    'expired',
+    'disputed'
 );
 
 CREATE TABLE IF NOT EXISTS bookings (
-    id uuid PRIMARY KEY NOT NULL,
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
     media_id VARCHAR(40) NOT NULL REFERENCES profiles(id),
-    photographer_id VARCHAR(40) NOT NULL,
+    photographer_id VARCHAR(40) NOT NULL DEFAULT '',
     task TEXT NOT NULL,
     price INTEGER NOT NULL CHECK (price >= 0),
     credits INTEGER NOT NULL,
-    accepted BOOLEAN NOT NULL DEFAULT FALSE,
-    completed BOOLEAN NOT NULL DEFAULT FALSE,
-    date_start DATE NOT NULL,
-    date_end DATE NOT NULL CHECK (date_end >= date_start),
+    pricing_version VARCHAR(40) NOT NULL DEFAULT '',
+    status booking_status NOT NULL DEFAULT 'requested',
+    date_start TIMESTAMPTZ NOT NULL,
+    date_end TIMESTAMPTZ NOT NULL CHECK (date_end >= date_start),
     created_at DATE NOT NULL DEFAULT CURRENT_DATE,
-    lat NUMERIC(6,9) NOT NULL,
-    lng NUMERIC(6,9) NOT NULL
+    lat DOUBLE PRECISION NOT NULL CHECK (lat BETWEEN -90 AND 90),
+    lng DOUBLE PRECISION NOT NULL CHECK (lng BETWEEN -180 AND 180),
+    -- version is bumped on every edit, see UpdateBooking
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS bookings_lat_lng_idx ON bookings (lat, lng);
+CREATE INDEX IF NOT EXISTS bookings_photographer_dates_idx ON bookings (photographer_id, date_start, date_end);
+
+-- booking_transitions is an append-only history of every status change,
+-- a booking with a history can not be deleted
+CREATE TABLE IF NOT EXISTS booking_transitions (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    booking_id uuid NOT NULL REFERENCES bookings(id) ON DELETE RESTRICT,
+    from_status booking_status NOT NULL,
+    to_status booking_status NOT NULL,
+    actor_id VARCHAR(40) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS booking_transitions_booking_id_idx ON booking_transitions (booking_id, created_at);
+
+CREATE TYPE offer_status AS ENUM (
+    'pending',
+    'accepted',
+    'withdrawn',
+    'expired'
+);
+
+-- booking_offers holds a booking offered to one or more professionals.
+-- The first pending, unexpired offer to be accepted wins the booking.
+CREATE TABLE IF NOT EXISTS booking_offers (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    booking_id uuid NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    photographer_id VARCHAR(40) NOT NULL,
+    status offer_status NOT NULL DEFAULT 'pending',
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    responded_at TIMESTAMPTZ,
+    UNIQUE (booking_id, photographer_id)
+);
+
+-- Credits are kept in a double-entry ledger: every credit_transaction has entries
+-- that sum to zero, and an account balance is the sum of its entries.
+-- A media has an 'available' and a 'reserved' account. The 'system' owner has
+-- a 'grants' account (source of issued credits) and a 'revenue' account (captured bookings).
+CREATE TYPE credit_account_kind AS ENUM (
+    'available',
+    'reserved',
+    'grants',
+    'revenue'
+);
+
+CREATE TYPE credit_transaction_kind AS ENUM (
+    'grant',
+    'reserve',
+    'capture',
+    'release'
+);
+
+CREATE TABLE IF NOT EXISTS credit_accounts (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    owner_id VARCHAR(40) NOT NULL,
+    kind credit_account_kind NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (owner_id, kind)
 );
+
+CREATE TABLE IF NOT EXISTS credit_transactions (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    kind credit_transaction_kind NOT NULL,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    booking_id uuid REFERENCES bookings(id) ON DELETE RESTRICT,
+    actor_id VARCHAR(40) NOT NULL,
+    memo TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- a booking is reserved, captured or released at most once
+CREATE UNIQUE INDEX IF NOT EXISTS credit_transactions_booking_kind_idx ON credit_transactions (booking_id, kind)
+    WHERE booking_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS credit_entries (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    transaction_id uuid NOT NULL REFERENCES credit_transactions(id) ON DELETE RESTRICT,
+    account_id uuid NOT NULL REFERENCES credit_accounts(id) ON DELETE RESTRICT,
+    amount BIGINT NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX IF NOT EXISTS credit_entries_account_id_idx ON credit_entries (account_id);
+
+-- availability_slots are the recurring weekly hours a professional is available.
+-- Minutes are counted from midnight in time_zone; a slot with end_minute <= start_minute passes midnight.
+CREATE TABLE IF NOT EXISTS availability_slots (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    photographer_id VARCHAR(40) NOT NULL,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_minute INTEGER NOT NULL CHECK (start_minute BETWEEN 0 AND 1439),
+    end_minute INTEGER NOT NULL CHECK (end_minute BETWEEN 0 AND 1440),
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC'
+);
+
+CREATE INDEX IF NOT EXISTS availability_slots_photographer_id_idx ON availability_slots (photographer_id);
+
+CREATE TYPE availability_kind AS ENUM (
+    'available',
+    'unavailable'
+);
+
+-- availability_exceptions override the weekly slots for a period of time.
+-- Exceptions imported from an ICS file have source 'ics' and are replaced on every import.
+CREATE TABLE IF NOT EXISTS availability_exceptions (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    photographer_id VARCHAR(40) NOT NULL,
+    kind availability_kind NOT NULL DEFAULT 'unavailable',
+    date_start TIMESTAMPTZ NOT NULL,
+    date_end TIMESTAMPTZ NOT NULL CHECK (date_end > date_start),
+    source VARCHAR(10) NOT NULL DEFAULT 'manual',
+    external_uid TEXT NOT NULL DEFAULT '',
+    summary TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS availability_exceptions_photographer_dates_idx ON availability_exceptions (photographer_id, date_start, date_end);
+
+-- calendar_feeds holds the sha256 of the secret token a professional's calendar app uses to fetch the ICS feed
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    photographer_id VARCHAR(40) PRIMARY KEY NOT NULL,
+    token_hash BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- api_keys let the systems of a media account, e.g. a newsroom CMS, call the API without a user login.
+-- Only the sha256 of a key is stored; prefix is the start of the key, shown to tell keys apart.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id uuid PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    media_id VARCHAR(40) NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    prefix VARCHAR(16) NOT NULL,
+    key_hash BYTEA NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_by VARCHAR(40) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_media_id_idx ON api_keys (media_id);
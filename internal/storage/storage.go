@@ -5,6 +5,14 @@ import (
 	"time"
 
 	"firebase.google.com/go/auth"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidCredentials is returned by SignIn when the email or password is wrong
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidRefreshToken is returned by Refresh when the token is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
 )
 
 // FBService contains firebase methods
@@ -22,9 +30,93 @@ type FBService interface {
 	DeleteAuthUserByUID(uid string) error
 	CreateCustomTokenWithClaims(ctx context.Context, uid string, claims map[string]interface{}) (string, error)
 	IsAdminClaims(claims map[string]interface{}) bool
+	IdentityProvider
+}
+
+// IdentityProvider signs users in, verifies their tokens and manages their roles
+type IdentityProvider interface {
+	Authenticator
+	SessionProvider
+	RoleManager
+}
+
+// Custom claims holding the roles of a user
+const (
+	ClaimAdmin        = "is_admin"
+	ClaimProfessional = "is_professional"
+	ClaimMedia        = "is_media"
+)
+
+// RoleClaims are the roles of a user as stored in its custom claims
+type RoleClaims struct {
+	Admin        bool `json:"isAdmin"`
+	Professional bool `json:"isProfessional"`
+	Media        bool `json:"isMedia"`
+}
+
+// RoleClaimsFrom reads the role claims of a verified token. ok is false when the token
+// carries none of them, i.e. the user has not been given role claims yet.
+func RoleClaimsFrom(claims map[string]interface{}) (rc RoleClaims, ok bool) {
+	for key, role := range map[string]*bool{
+		ClaimAdmin:        &rc.Admin,
+		ClaimProfessional: &rc.Professional,
+		ClaimMedia:        &rc.Media,
+	} {
+		if v, found := claims[key]; found {
+			ok = true
+			*role, _ = v.(bool)
+		}
+	}
+	return rc, ok
+}
+
+// Merge writes the role claims into claims, keeping any other custom claims
+func (rc RoleClaims) Merge(claims map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(claims)+3)
+	for k, v := range claims {
+		merged[k] = v
+	}
+	merged[ClaimAdmin] = rc.Admin
+	merged[ClaimProfessional] = rc.Professional
+	merged[ClaimMedia] = rc.Media
+	return merged
+}
+
+// RoleManager reads and writes the role claims of users.
+// New claims are in the id tokens issued after the change.
+type RoleManager interface {
+	// GetRoleClaims returns the role claims of uid, ok is false when it has not been given role claims yet
+	GetRoleClaims(ctx context.Context, uid string) (rc RoleClaims, ok bool, err error)
+	SetRoleClaims(ctx context.Context, uid string, rc RoleClaims) error
+}
+
+// Authenticator verifies user tokens and tells what a verified user may act as.
+// Firebase implements it, and so does the local JWT authenticator in storage/jwtauth
+// used to run the API without Google credentials.
+type Authenticator interface {
+	VerifyToken(ctx context.Context, idToken string) (*auth.Token, error)
 	IsAdminUID(ctx context.Context, uid string) (bool, error)
 	IsProfessional(ctx context.Context, uid string) (bool, error)
-	VerifyToken(ctx context.Context, idToken string) (*auth.Token, error)
+	IsMedia(ctx context.Context, uid string) (bool, error)
+}
+
+// SessionProvider exchanges credentials for a session and keeps it alive
+type SessionProvider interface {
+	// SignIn checks the email and password and starts a session
+	SignIn(ctx context.Context, email, password string) (*Session, error)
+	// Refresh exchanges a refresh token for a new id token
+	Refresh(ctx context.Context, refreshToken string) (*Session, error)
+	// RevokeSessions invalidates every refresh token of uid and the id tokens issued before now
+	RevokeSessions(ctx context.Context, uid string) error
+}
+
+// Session is a signed in user. IDToken is sent in the user_token header,
+// RefreshToken is exchanged for a new IDToken before ExpiresAt.
+type Session struct {
+	UID          string    `json:"uid"`
+	IDToken      string    `json:"idToken"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
 }
 
 // type Service interface {
@@ -98,6 +190,41 @@ type FirebaseProfile struct {
 
 // CreateDate         *time.Time `json:"createDate"`
 
+// PublicProfile is the part of a profile other users may see.
+// It never holds contact, device or financial fields.
+type PublicProfile struct {
+	UserID              string `json:"userId,omitempty"`
+	DisplayName         string `json:"displayName"`
+	FirstName           string `json:"firstName,omitempty"`
+	LastName            string `json:"lastName,omitempty"`
+	Country             string `json:"country,omitempty"`
+	IsMedia             bool   `json:"isMedia,omitempty"`
+	IsProfessional      bool   `json:"isProfessional,omitempty"`
+	IsPress             bool   `json:"isPress,omitempty"`
+	AcceptedAssignments int    `json:"acceptedAssignments,omitempty"`
+	UserPicture         string `json:"userPicture,omitempty"`
+	SoldStories         int    `json:"soldStories,omitempty"`
+	UploadedStories     int    `json:"uploadedStories,omitempty"`
+}
+
+// Public returns the public view of the profile
+func (p *FirebaseProfile) Public() *PublicProfile {
+	return &PublicProfile{
+		UserID:              p.UserID,
+		DisplayName:         p.DisplayName,
+		FirstName:           p.FirstName,
+		LastName:            p.LastName,
+		Country:             p.Country,
+		IsMedia:             p.IsMedia,
+		IsProfessional:      p.IsProfessional,
+		IsPress:             p.IsPress,
+		AcceptedAssignments: p.AcceptedAssignments,
+		UserPicture:         p.UserPicture,
+		SoldStories:         p.SoldStories,
+		UploadedStories:     p.UploadedStories,
+	}
+}
+
 // Media struct
 type Media struct {
 	ID                  string `sql:"id"`
@@ -8,6 +8,7 @@ import (
 	"os"
 
 	"github.com/byrdapp/byrd-pro-api/public/conversion"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/encode"
 )
 
 type File struct {
@@ -101,9 +102,33 @@ func (f *File) FileSize() (size float64, err error) {
 	return size, err
 }
 
+// EncodeExif writes value into the exif and XMP of the JPEG file, metaTag is an encode.Field like "copyright".
+// The image data of the file is kept byte for byte.
 func (f *File) EncodeExif(metaTag, value string) error {
-	// Handle file types
-	return nil
+	b, err := ioutil.ReadFile(f.file.Name())
+	if err != nil {
+		return err
+	}
+	b, err = encode.JPEG(b, map[encode.Field]string{encode.Field(metaTag): value})
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteFile(b)
+	return err
+}
+
+// StripExif removes the location and device serials from the JPEG file before it is shared with third parties
+func (f *File) StripExif() error {
+	b, err := ioutil.ReadFile(f.file.Name())
+	if err != nil {
+		return err
+	}
+	b, err = encode.Strip(b)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteFile(b)
+	return err
 }
 
 type Reader interface {
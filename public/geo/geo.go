@@ -0,0 +1,91 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+// EarthRadiusKm is the mean earth radius used for haversine distances
+const EarthRadiusKm = 6371.0
+
+var ErrInvalidPoint = errors.New("latitude must be within [-90, 90] and longitude within [-180, 180]")
+
+// Point is a WGS84 coordinate in decimal degrees
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Box is a lat/lng bounding box. Boxes crossing the antimeridian are widened to all longitudes.
+type Box struct {
+	MinLat float64 `json:"minLat"`
+	MaxLat float64 `json:"maxLat"`
+	MinLng float64 `json:"minLng"`
+	MaxLng float64 `json:"maxLng"`
+}
+
+// Validate checks the point is within the coordinate ranges
+func (p Point) Validate() error {
+	if math.IsNaN(p.Lat) || math.IsNaN(p.Lng) || p.Lat < -90 || p.Lat > 90 || p.Lng < -180 || p.Lng > 180 {
+		return ErrInvalidPoint
+	}
+	return nil
+}
+
+// DistanceKm returns the great-circle distance between p and q using the haversine formula
+func (p Point) DistanceKm(q Point) float64 {
+	dLat := radians(q.Lat - p.Lat)
+	dLng := radians(q.Lng - p.Lng)
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(radians(p.Lat))*math.Cos(radians(q.Lat))*math.Pow(math.Sin(dLng/2), 2)
+	return EarthRadiusKm * 2 * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BoundingBox returns the smallest box containing every point within radiusKm of p
+func (p Point) BoundingBox(radiusKm float64) Box {
+	dLat := degrees(radiusKm / EarthRadiusKm)
+	b := Box{
+		MinLat: math.Max(-90, p.Lat-dLat),
+		MaxLat: math.Min(90, p.Lat+dLat),
+		MinLng: -180,
+		MaxLng: 180,
+	}
+	// near the poles every longitude is within reach
+	if b.MinLat == -90 || b.MaxLat == 90 {
+		return b
+	}
+	dLng := degrees(math.Asin(math.Sin(radiusKm/EarthRadiusKm) / math.Cos(radians(p.Lat))))
+	if p.Lng-dLng < -180 || p.Lng+dLng > 180 {
+		return b
+	}
+	b.MinLng = p.Lng - dLng
+	b.MaxLng = p.Lng + dLng
+	return b
+}
+
+// Validate checks the corners of the box and that min is less than max
+func (b Box) Validate() error {
+	min, max := Point{b.MinLat, b.MinLng}, Point{b.MaxLat, b.MaxLng}
+	if min.Validate() != nil || max.Validate() != nil || b.MinLat > b.MaxLat || b.MinLng > b.MaxLng {
+		return ErrInvalidPoint
+	}
+	return nil
+}
+
+// Center returns the midpoint of the box
+func (b Box) Center() Point {
+	return Point{Lat: (b.MinLat + b.MaxLat) / 2, Lng: (b.MinLng + b.MaxLng) / 2}
+}
+
+// Contains reports whether p lies inside the box
+func (b Box) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,63 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	copenhagen = Point{55.6761, 12.5683}
+	aarhus     = Point{56.1629, 10.2039}
+)
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		a, b     Point
+		expected float64
+	}{
+		{copenhagen, copenhagen, 0},
+		{copenhagen, aarhus, 156.9},
+		{Point{0, 0}, Point{0, 180}, math.Pi * EarthRadiusKm},
+	}
+	for _, test := range tests {
+		d := test.a.DistanceKm(test.b)
+		if math.Abs(d-test.expected) > 0.5 {
+			t.Errorf("distance %v -> %v: expected %v got %v", test.a, test.b, test.expected, d)
+		}
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	t.Run("contains radius", func(t *testing.T) {
+		box := copenhagen.BoundingBox(200)
+		if !box.Contains(aarhus) {
+			t.Errorf("expected %+v to contain %v", box, aarhus)
+		}
+		if box := copenhagen.BoundingBox(100); box.Contains(aarhus) {
+			t.Errorf("expected %+v not to contain %v", box, aarhus)
+		}
+	})
+	t.Run("antimeridian", func(t *testing.T) {
+		box := Point{0, 179.9}.BoundingBox(50)
+		if box.MinLng != -180 || box.MaxLng != 180 {
+			t.Errorf("expected all longitudes got %+v", box)
+		}
+	})
+	t.Run("pole", func(t *testing.T) {
+		box := Point{89.9, 0}.BoundingBox(50)
+		if box.MaxLat != 90 || box.MinLng != -180 || box.MaxLng != 180 {
+			t.Errorf("expected polar cap got %+v", box)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	if err := copenhagen.Validate(); err != nil {
+		t.Error(err)
+	}
+	for _, p := range []Point{{91, 0}, {0, -181}, {math.NaN(), 0}} {
+		if err := p.Validate(); err != ErrInvalidPoint {
+			t.Errorf("%v: expected %v got %v", p, ErrInvalidPoint, err)
+		}
+	}
+}
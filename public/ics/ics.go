@@ -0,0 +1,351 @@
+// Package ics reads and writes the subset of iCalendar (RFC 5545) needed to exchange
+// busy time with external calendars: VEVENTs with a start, an end and a summary.
+package ics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateTimeFormat    = "20060102T150405"
+	dateTimeUTCFormat = "20060102T150405Z"
+	dateFormat        = "20060102"
+	// lines longer than this are folded, see RFC 5545 3.1
+	maxLineOctets = 75
+)
+
+var (
+	ErrNoCalendar  = errors.New("ics: missing BEGIN:VCALENDAR")
+	ErrBadDate     = errors.New("ics: invalid date or date-time value")
+	ErrBadDuration = errors.New("ics: invalid duration value")
+)
+
+// Calendar is a VCALENDAR with its events
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Event is a VEVENT. Start and End are always set; all-day events span whole days.
+// Recurrence rules are not expanded, only the first occurrence is kept.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+}
+
+// Write encodes cal as an iCalendar stream with times in UTC
+func Write(w io.Writer, cal Calendar) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format(dateTimeUTCFormat)
+	prodID := cal.ProdID
+	if prodID == "" {
+		prodID = "-//Byrd//Byrd Pro API//EN"
+	}
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:"+prodID)
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	if cal.Name != "" {
+		writeLine(bw, "X-WR-CALNAME:"+escape(cal.Name))
+	}
+	for _, e := range cal.Events {
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+escape(e.UID))
+		writeLine(bw, "DTSTAMP:"+stamp)
+		if e.AllDay {
+			writeLine(bw, "DTSTART;VALUE=DATE:"+e.Start.Format(dateFormat))
+			writeLine(bw, "DTEND;VALUE=DATE:"+e.End.Format(dateFormat))
+		} else {
+			writeLine(bw, "DTSTART:"+e.Start.UTC().Format(dateTimeUTCFormat))
+			writeLine(bw, "DTEND:"+e.End.UTC().Format(dateTimeUTCFormat))
+		}
+		if e.Summary != "" {
+			writeLine(bw, "SUMMARY:"+escape(e.Summary))
+		}
+		if e.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escape(e.Description))
+		}
+		writeLine(bw, "END:VEVENT")
+	}
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeLine folds content lines at 75 octets without splitting utf-8 sequences
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines lose one octet to the leading space
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// property is a single unfolded content line
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func parseProperty(line string) (property, bool) {
+	colon := -1
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+		if colon >= 0 {
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, false
+	}
+	parts := strings.Split(line[:colon], ";")
+	p := property{
+		name:   strings.ToUpper(parts[0]),
+		params: make(map[string]string),
+		value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+			p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return p, true
+}
+
+// Parse reads the events of an iCalendar stream. Cancelled and transparent (free) events are skipped.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events   []Event
+		inCal    bool
+		current  *Event
+		skip     bool
+		duration time.Duration
+		hasEnd   bool
+		depth    int // nested components such as VALARM
+	)
+	for _, line := range lines {
+		p, ok := parseProperty(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCALENDAR"):
+			inCal = true
+			continue
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			current, skip, duration, hasEnd, depth = &Event{}, false, 0, false, 0
+			continue
+		case p.name == "BEGIN" && current != nil:
+			depth++
+			continue
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT") && current != nil:
+			if current.Start.IsZero() {
+				return nil, fmt.Errorf("ics: event %q has no DTSTART", current.UID)
+			}
+			if !hasEnd {
+				switch {
+				case duration > 0:
+					current.End = current.Start.Add(duration)
+				case current.AllDay:
+					current.End = current.Start.AddDate(0, 0, 1)
+				default:
+					current.End = current.Start
+				}
+			}
+			if !skip && current.End.After(current.Start) {
+				events = append(events, *current)
+			}
+			current = nil
+			continue
+		case p.name == "END" && current != nil && depth > 0:
+			depth--
+			continue
+		}
+		if current == nil || depth > 0 {
+			continue
+		}
+
+		switch p.name {
+		case "UID":
+			current.UID = p.value
+		case "SUMMARY":
+			current.Summary = unescape(p.value)
+		case "DESCRIPTION":
+			current.Description = unescape(p.value)
+		case "DTSTART":
+			current.Start, current.AllDay, err = parseTime(p)
+		case "DTEND":
+			current.End, _, err = parseTime(p)
+			hasEnd = true
+		case "DURATION":
+			duration, err = parseDuration(p.value)
+		case "STATUS":
+			skip = skip || strings.EqualFold(p.value, "CANCELLED")
+		case "TRANSP":
+			skip = skip || strings.EqualFold(p.value, "TRANSPARENT")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !inCal {
+		return nil, ErrNoCalendar
+	}
+	return events, nil
+}
+
+// unfold joins continuation lines (starting with a space or tab) onto the previous line
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseTime handles UTC, floating, TZID qualified and VALUE=DATE values.
+// Floating times are read as UTC.
+func parseTime(p property) (time.Time, bool, error) {
+	if strings.EqualFold(p.params["VALUE"], "DATE") || len(p.value) == len(dateFormat) {
+		t, err := time.ParseInLocation(dateFormat, p.value, time.UTC)
+		if err != nil {
+			return time.Time{}, false, ErrBadDate
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse(dateTimeUTCFormat, p.value)
+		if err != nil {
+			return time.Time{}, false, ErrBadDate
+		}
+		return t, false, nil
+	}
+	loc := time.UTC
+	if tzid := p.params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("ics: unknown TZID %q", tzid)
+		}
+		loc = l
+	}
+	t, err := time.ParseInLocation(dateTimeFormat, p.value, loc)
+	if err != nil {
+		return time.Time{}, false, ErrBadDate
+	}
+	return t.UTC(), false, nil
+}
+
+// parseDuration parses RFC 5545 durations such as P1D, PT1H30M or P2W
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimPrefix(s, "+")
+	if strings.HasPrefix(s, "-") || !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, ErrBadDuration
+	}
+	var (
+		d      time.Duration
+		inTime bool
+		num    string
+	)
+	for _, c := range s[1:] {
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			num += string(c)
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, ErrBadDuration
+			}
+			num = ""
+			switch {
+			case c == 'W' && !inTime:
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case c == 'D' && !inTime:
+				d += time.Duration(n) * 24 * time.Hour
+			case c == 'H' && inTime:
+				d += time.Duration(n) * time.Hour
+			case c == 'M' && inTime:
+				d += time.Duration(n) * time.Minute
+			case c == 'S' && inTime:
+				d += time.Duration(n) * time.Second
+			default:
+				return 0, ErrBadDuration
+			}
+		}
+	}
+	if num != "" {
+		return 0, ErrBadDuration
+	}
+	return d, nil
+}
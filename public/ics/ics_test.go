@@ -0,0 +1,112 @@
+package ics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	start := time.Date(2020, time.April, 15, 10, 0, 0, 0, time.UTC)
+	cal := Calendar{
+		Name: "Bookings",
+		Events: []Event{{
+			UID:     "booking-1@byrd.news",
+			Summary: "Portrait, studio; " + strings.Repeat("long summary ", 10),
+			Start:   start,
+			End:     start.Add(2 * time.Hour),
+		}},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, cal); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line exceeds %d octets: %q", maxLineOctets, line)
+		}
+	}
+
+	events, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event got %d", len(events))
+	}
+	e := events[0]
+	if e.UID != cal.Events[0].UID || e.Summary != cal.Events[0].Summary {
+		t.Errorf("expected %+v got %+v", cal.Events[0], e)
+	}
+	if !e.Start.Equal(start) || !e.End.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("unexpected interval %v - %v", e.Start, e.End)
+	}
+}
+
+func TestParse(t *testing.T) {
+	src := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:tz",
+		"DTSTART;TZID=Europe/Copenhagen:20200415T100000",
+		"DURATION:PT1H30M",
+		"BEGIN:VALARM",
+		"DESCRIPTION:reminder",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:allday",
+		"DTSTART;VALUE=DATE:20200416",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:free",
+		"DTSTART:20200417T100000Z",
+		"DTEND:20200417T110000Z",
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:cancelled",
+		"DTSTART:20200417T100000Z",
+		"DTEND:20200417T110000Z",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events got %d", len(events))
+	}
+	if want := time.Date(2020, time.April, 15, 8, 0, 0, 0, time.UTC); !events[0].Start.Equal(want) || events[0].End.Sub(want) != 90*time.Minute {
+		t.Errorf("unexpected interval %v - %v", events[0].Start, events[0].End)
+	}
+	if events[0].Description != "" {
+		t.Errorf("alarm description leaked into event: %q", events[0].Description)
+	}
+	if !events[1].AllDay || events[1].End.Sub(events[1].Start) != 24*time.Hour {
+		t.Errorf("unexpected all-day event %+v", events[1])
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"P1W":      7 * 24 * time.Hour,
+		"P1DT2H":   26 * time.Hour,
+		"PT15M":    15 * time.Minute,
+		"PT1H0M5S": time.Hour + 5*time.Second,
+	}
+	for in, expected := range tests {
+		if got, err := parseDuration(in); err != nil || got != expected {
+			t.Errorf("%s: expected %v got %v (%v)", in, expected, got, err)
+		}
+	}
+	for _, in := range []string{"", "P", "1H", "PT1D", "-PT1H"} {
+		if _, err := parseDuration(in); err != ErrBadDuration {
+			t.Errorf("%s: expected %v got %v", in, ErrBadDuration, err)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package logger
 
 import (
+	"os"
+	"time"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,3 +18,13 @@ func NewLogger() *logrus.Logger {
 	})
 	return logger
 }
+
+// NewAccessLogger logs json lines to stdout, apart from the text logs of NewLogger
+func NewAccessLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(os.Stdout)
+	logger.SetFormatter(&logrus.JSONFormatter{
+		TimestampFormat: time.RFC3339Nano,
+	})
+	return logger
+}
@@ -0,0 +1,263 @@
+// Package encode writes metadata into delivered JPEGs: the copyright, byline, caption and booking of an
+// image, or strips the location and device serials from it. Only the exif, XMP and IPTC segments are
+// rewritten, the image data is copied byte for byte.
+package encode
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/iptc"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/segments"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/xmp"
+)
+
+// Field is a field JPEG writes into an image
+type Field string
+
+const (
+	Copyright Field = "copyright"
+	Byline    Field = "byline"
+	Caption   Field = "caption"
+	// BookingID is the booking the image was delivered for, written as the job identifier
+	// of XMP (photoshop:TransmissionReference) and IPTC
+	BookingID Field = "bookingId"
+)
+
+var (
+	ErrUnknownField = errors.New("encode: unknown metadata field")
+	ErrNotJPEG      = errors.New("encode: metadata can only be written to jpeg images")
+)
+
+// exif tags written and stripped
+const (
+	tagImageDescription = 0x010E
+	tagArtist           = 0x013B
+	tagCopyright        = 0x8298
+	tagCameraOwnerName  = 0xA430
+	tagBodySerialNumber = 0xA431
+	tagLensSerialNumber = 0xA435
+	tagCameraSerial     = 0xC62F
+)
+
+// fields says where each field is written, fields without an exif tag are only written to XMP and IPTC
+var fields = map[Field]struct {
+	tag     uint16
+	prop    xmp.Property
+	dataset iptc.Dataset
+}{
+	Copyright: {tagCopyright, xmp.Property{NS: xmp.NamespaceDC, Name: "rights", Kind: xmp.LangAlt}, iptc.CopyrightNotice},
+	Byline:    {tagArtist, xmp.Property{NS: xmp.NamespaceDC, Name: "creator", Kind: xmp.Seq}, iptc.Byline},
+	Caption:   {tagImageDescription, xmp.Property{NS: xmp.NamespaceDC, Name: "description", Kind: xmp.LangAlt}, iptc.Caption},
+	BookingID: {0, xmp.Property{NS: xmp.NamespacePhotoshop, Name: "TransmissionReference"}, iptc.JobID},
+}
+
+// Namespaces of the XMP properties Strip removes
+const (
+	namespaceAux    = "http://ns.adobe.com/exif/1.0/aux/"
+	namespaceExifEX = "http://cipa.jp/exif/1.0/"
+)
+
+// JPEG writes the fields into the exif and the XMP of the JPEG in b, an empty value removes the field.
+// Both are written as readers like metadata.DecodeImage prefer the XMP. The IPTC record of images
+// that have one is updated too, so a removed field is not read back from it.
+func JPEG(b []byte, values map[Field]string) ([]byte, error) {
+	edit := exifEdit{Set: make(map[uint16]string)}
+	removed := make(map[uint16]bool)
+	datasets := make(map[iptc.Dataset]string, len(values))
+	names := make([]string, 0, len(values))
+	for field := range values {
+		names = append(names, string(field))
+	}
+	// the XMP properties are written in the same order whatever the map order
+	sort.Strings(names)
+	var props []xmp.Property
+	for _, name := range names {
+		f, ok := fields[Field(name)]
+		if !ok {
+			return nil, ErrUnknownField
+		}
+		v := values[Field(name)]
+		f.prop.Value = v
+		props = append(props, f.prop)
+		datasets[f.dataset] = v
+		switch {
+		case f.tag == 0:
+		case v == "":
+			removed[f.tag] = true
+		default:
+			edit.Set[f.tag] = v
+		}
+	}
+	edit.Drop = func(ifd string, id uint16) bool {
+		return ifd == "ifd0" && removed[id]
+	}
+	return editJPEG(b, edit, props, nil, datasets)
+}
+
+// Strip removes the location and the serial numbers of the camera, lens and owner from the exif and
+// the XMP of the JPEG in b, before it is shared with third parties. The maker note, where cameras keep
+// their serials too, is removed as well.
+func Strip(b []byte) ([]byte, error) {
+	edit := exifEdit{Drop: func(dir string, id uint16) bool {
+		switch dir {
+		case "gps":
+			return true
+		case "ifd0":
+			return id == tagCameraSerial
+		case "exif":
+			switch id {
+			case ifd.MakerNote, tagCameraOwnerName, tagBodySerialNumber, tagLensSerialNumber:
+				return true
+			}
+		}
+		return false
+	}}
+	drop := func(ns, name string) bool {
+		switch ns {
+		case xmp.NamespaceExif:
+			return strings.HasPrefix(name, "GPS")
+		case namespaceAux:
+			return name == "SerialNumber" || name == "LensSerialNumber" || name == "OwnerName"
+		case namespaceExifEX:
+			return name == "BodySerialNumber" || name == "LensSerialNumber" || name == "CameraOwnerName"
+		}
+		return false
+	}
+	return editJPEG(b, edit, nil, drop, nil)
+}
+
+// editJPEG applies the edits to the exif, the XMP and the IPTC segments of the JPEG in b.
+// Missing exif and XMP segments are only added when there is a value to write, IPTC is not added.
+func editJPEG(b []byte, edit exifEdit, props []xmp.Property, drop func(ns, name string) bool, datasets map[iptc.Dataset]string) ([]byte, error) {
+	if !segments.IsJPEG(b) {
+		return nil, ErrNotJPEG
+	}
+	segs, scan, err := segments.Split(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(datasets) > 0 {
+		if segs, err = editIPTC(segs, datasets); err != nil {
+			return nil, err
+		}
+	}
+	// new segments go after a JFIF APP0, the exif first
+	at := 0
+	for at < len(segs) && segs[at].Marker == 0xE0 {
+		at++
+	}
+	exifAt, xmpAt := -1, -1
+	for i, s := range segs {
+		if s.Marker != segments.APP1 {
+			continue
+		}
+		if exifAt < 0 && bytes.HasPrefix(s.Data, segments.ExifHeader) {
+			exifAt = i
+		}
+		if xmpAt < 0 && bytes.HasPrefix(s.Data, segments.XMPHeader) {
+			xmpAt = i
+		}
+	}
+
+	if exifAt >= 0 || len(edit.Set) > 0 {
+		var old []byte
+		if exifAt >= 0 {
+			old = segs[exifAt].Data[len(segments.ExifHeader):]
+		}
+		tiff, err := editExif(old, edit)
+		if err != nil {
+			return nil, err
+		}
+		data := append(append([]byte(nil), segments.ExifHeader...), tiff...)
+		if exifAt < 0 {
+			exifAt = at
+			segs = insert(segs, exifAt, segments.Segment{Marker: segments.APP1})
+			if xmpAt >= exifAt {
+				xmpAt++
+			}
+		}
+		segs[exifAt].Data = data
+		at = exifAt + 1
+	}
+
+	if xmpAt >= 0 || hasValue(props) {
+		var old []byte
+		if xmpAt >= 0 {
+			old = segs[xmpAt].Data[len(segments.XMPHeader):]
+		}
+		packet, err := xmp.Edit(old, props, drop)
+		if err != nil {
+			return nil, err
+		}
+		data := append(append([]byte(nil), segments.XMPHeader...), packet...)
+		if xmpAt < 0 {
+			xmpAt = at
+			segs = insert(segs, xmpAt, segments.Segment{Marker: segments.APP1})
+		}
+		segs[xmpAt].Data = data
+	}
+	return segments.Join(segs, scan)
+}
+
+// editIPTC sets the datasets in the IIM record of the Photoshop resources in segs. The resources may be
+// split over several APP13 segments, they are written back from the first one.
+func editIPTC(segs []segments.Segment, datasets map[iptc.Dataset]string) ([]segments.Segment, error) {
+	at := -1
+	var irb []byte
+	kept := segs[:0:0]
+	for _, s := range segs {
+		if s.Marker != segments.APP13 || !bytes.HasPrefix(s.Data, segments.PhotoshopHeader) {
+			kept = append(kept, s)
+			continue
+		}
+		if at < 0 {
+			at = len(kept)
+		}
+		irb = append(irb, s.Data[len(segments.PhotoshopHeader):]...)
+	}
+	if at < 0 {
+		return segs, nil
+	}
+	record, err := iptc.FromPhotoshop(irb)
+	if err == iptc.ErrNoIPTC {
+		return segs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record, err = iptc.Edit(record, datasets); err != nil {
+		return nil, err
+	}
+	if irb, err = iptc.ReplacePhotoshop(irb, record); err != nil {
+		return nil, err
+	}
+	for n := segments.MaxData - len(segments.PhotoshopHeader); len(irb) > 0; at++ {
+		if n > len(irb) {
+			n = len(irb)
+		}
+		data := append(append([]byte(nil), segments.PhotoshopHeader...), irb[:n]...)
+		kept = insert(kept, at, segments.Segment{Marker: segments.APP13, Data: data})
+		irb = irb[n:]
+	}
+	return kept, nil
+}
+
+func insert(segs []segments.Segment, i int, s segments.Segment) []segments.Segment {
+	segs = append(segs, segments.Segment{})
+	copy(segs[i+1:], segs[i:])
+	segs[i] = s
+	return segs
+}
+
+func hasValue(props []xmp.Property) bool {
+	for _, p := range props {
+		if p.Value != "" {
+			return true
+		}
+	}
+	return false
+}
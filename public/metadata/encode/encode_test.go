@@ -0,0 +1,172 @@
+package encode
+
+import (
+	"bytes"
+	goimage "image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd/ifdtest"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/iptc"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/segments"
+)
+
+func testJPEG(t *testing.T, segs ...segments.Segment) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, goimage.NewGray(goimage.Rect(0, 0, 16, 16)), nil); err != nil {
+		t.Fatal(err)
+	}
+	rest, scan, err := segments.Split(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := segments.Join(append(segs, rest...), scan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func app1(header, data []byte) segments.Segment {
+	return segments.Segment{Marker: segments.APP1, Data: append(append([]byte(nil), header...), data...)}
+}
+
+// sameScan fails the test when the image data of a and b differ
+func sameScan(t *testing.T, a, b []byte) {
+	_, scanA, err := segments.Split(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, scanB, err := segments.Split(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(scanA, scanB) {
+		t.Error("expected the image data to be kept byte for byte")
+	}
+}
+
+func TestJPEG(t *testing.T) {
+	for _, in := range [][]byte{testJPEG(t), testJPEG(t, app1(segments.ExifHeader, ifdtest.Exif()))} {
+		out, err := JPEG(in, map[Field]string{
+			Copyright: "© Byrd",
+			Byline:    "Jane Doe",
+			Caption:   "Fire at the harbour",
+			BookingID: "booking-42",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sameScan(t, in, out)
+		m, err := metadata.DecodeImage(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := metadata.News{Copyright: "© Byrd", Byline: "Jane Doe", Caption: "Fire at the harbour"}
+		if m.Copyright != want.Copyright || m.Byline != want.Byline || m.Caption != want.Caption {
+			t.Errorf("expected %+v got %+v", want, m.News)
+		}
+		if !bytes.Contains(out, []byte("<photoshop:TransmissionReference")) {
+			t.Error("expected the booking id in the XMP")
+		}
+	}
+}
+
+func TestJPEGClear(t *testing.T) {
+	in := testJPEG(t, app1(segments.ExifHeader, ifdtest.Exif()))
+	out, err := JPEG(in, map[Field]string{Copyright: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := metadata.DecodeImage(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Copyright != "" {
+		t.Errorf("expected no copyright got %q", m.Copyright)
+	}
+	if _, err := JPEG(in, map[Field]string{"camera": "x"}); err != ErrUnknownField {
+		t.Errorf("expected %v got %v", ErrUnknownField, err)
+	}
+	if _, err := JPEG([]byte("\x89PNG"), nil); err != ErrNotJPEG {
+		t.Errorf("expected %v got %v", ErrNotJPEG, err)
+	}
+}
+
+func TestJPEGIPTC(t *testing.T) {
+	record, err := iptc.Edit(nil, map[iptc.Dataset]string{
+		iptc.CopyrightNotice: "old", iptc.Byline: "Old Byline", iptc.City: "Aarhus",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	irb, err := iptc.ReplacePhotoshop(nil, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := testJPEG(t, segments.Segment{Marker: segments.APP13, Data: append(append([]byte(nil), segments.PhotoshopHeader...), irb...)})
+	out, err := JPEG(in, map[Field]string{Copyright: "", Byline: "Jane Doe", BookingID: "booking-42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameScan(t, in, out)
+	m, err := metadata.DecodeImage(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the cleared copyright is not read back from the IPTC
+	if m.Copyright != "" || m.Byline != "Jane Doe" {
+		t.Errorf("expected no copyright and the new byline got %+v", m.News)
+	}
+	segs, _, err := segments.Split(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := iptc.FromPhotoshop(bytes.Join(segments.Find(segs, segments.APP13, segments.PhotoshopHeader), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := iptc.Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ds, want := range map[iptc.Dataset]string{iptc.CopyrightNotice: "", iptc.Byline: "Jane Doe", iptc.JobID: "booking-42", iptc.City: "Aarhus"} {
+		if v := rec.Get(ds); v != want {
+			t.Errorf("%d: expected %q got %q", ds, want, v)
+		}
+	}
+}
+
+func TestStrip(t *testing.T) {
+	packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:exif="http://ns.adobe.com/exif/1.0/" xmlns:aux="http://ns.adobe.com/exif/1.0/aux/"
+ exif:GPSLatitude="56,9.0N" aux:SerialNumber="0123456789" aux:Lens="EF24-70mm"/>
+</rdf:RDF></x:xmpmeta>`)
+	in := testJPEG(t, app1(segments.ExifHeader, ifdtest.Exif()), app1(segments.XMPHeader, packet))
+	out, err := Strip(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameScan(t, in, out)
+	for _, private := range []string{"56,9.0N", "0123456789", string(ifdtest.MakerNote), "123\x00"} {
+		if bytes.Contains(out, []byte(private)) {
+			t.Errorf("expected %q to be stripped", private)
+		}
+	}
+	if !bytes.Contains(out, []byte("EF24-70mm")) {
+		t.Error("expected the lens to be kept")
+	}
+	m, err := metadata.DecodeImage(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Lat != 0 || m.Copyright != "old" {
+		t.Errorf("expected no location and the copyright old got %v %q", m.Lat, m.Copyright)
+	}
+	// images without metadata are left as they are
+	plain := testJPEG(t)
+	if out, err := Strip(plain); err != nil || !bytes.Equal(out, plain) {
+		t.Errorf("expected the image unchanged got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package encode
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+)
+
+var ErrMalformedExif = errors.New("encode: malformed exif")
+
+// exifEdit is a change to a TIFF encoded exif
+type exifEdit struct {
+	// Set replaces or adds ascii tags of IFD0, e.g. the Copyright 0x8298
+	Set map[uint16]string
+	// Drop removes the tags of the IFDs ifd0, ifd1, exif, gps and interop it returns true for.
+	// The IFD pointers are kept up to date by editExif: a sub IFD is removed by dropping all of its tags.
+	Drop func(dir string, id uint16) bool
+}
+
+// tiff types by their size in bytes
+var typeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+const typeASCII = 2
+
+// editExif applies edit to the TIFF encoded exif in b, an empty b starts a new exif.
+// The data of the exif keeps its place: IFDs are rewritten where they were when they do not grow, new values
+// are appended and dropped values are zeroed. The offsets maker notes hold into the exif stay valid that way.
+func editExif(b []byte, edit exifEdit) ([]byte, error) {
+	if len(b) == 0 {
+		// little endian header and an IFD0 without tags
+		b = []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	}
+	if len(b) < 8 || !ifd.IsTIFF(b) {
+		return nil, ErrMalformedExif
+	}
+	e := &exifEditor{b: append([]byte(nil), b...), order: binary.LittleEndian}
+	if b[0] == 'M' {
+		e.order = binary.BigEndian
+	}
+	ifd0, err := e.readDir("ifd0", e.order.Uint32(e.b[4:]))
+	if err != nil {
+		return nil, err
+	}
+	// a broken thumbnail IFD is left as it is
+	var ifd1 *dir
+	if ifd0.next != 0 {
+		ifd1, _ = e.readDir("ifd1", ifd0.next)
+	}
+	exif := e.subDir("exif", ifd0, ifd.ExifIFD)
+	var interop *dir
+	if exif != nil {
+		interop = e.subDir("interop", exif, ifd.InteropIFD)
+	}
+	gps := e.subDir("gps", ifd0, ifd.GPSIFD)
+
+	if edit.Drop != nil {
+		for _, d := range []*dir{ifd0, ifd1, exif, interop, gps} {
+			if d != nil {
+				e.drop(d, edit.Drop)
+			}
+		}
+	}
+	ids := make([]int, 0, len(edit.Set))
+	for id := range edit.Set {
+		ids = append(ids, int(id))
+	}
+	// appended values follow the order of the tags, the output does not depend on map order
+	sort.Ints(ids)
+	for _, id := range ids {
+		e.set(ifd0, uint16(id), edit.Set[uint16(id)])
+	}
+
+	// sub IFDs are written first, their parents point to where they end up
+	if exif != nil {
+		e.link(exif, interop, ifd.InteropIFD)
+	}
+	e.link(ifd0, exif, ifd.ExifIFD)
+	e.link(ifd0, gps, ifd.GPSIFD)
+	if ifd1 != nil {
+		ifd0.next = e.writeDir(ifd1)
+	}
+	offset := e.writeDir(ifd0)
+	e.order.PutUint32(e.b[4:], offset)
+	return e.b, nil
+}
+
+type entry struct {
+	id, typ uint16
+	count   uint32
+	// value holds the value when it fits in four bytes, its offset otherwise
+	value [4]byte
+}
+
+type dir struct {
+	name   string
+	offset uint32
+	// slot is the number of entries the IFD had at offset, it is rewritten there when it does not grow
+	slot    int
+	entries []entry
+	next    uint32
+}
+
+type exifEditor struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func (e *exifEditor) readDir(name string, offset uint32) (*dir, error) {
+	if uint64(offset)+2 > uint64(len(e.b)) {
+		return nil, ErrMalformedExif
+	}
+	n := int(e.order.Uint16(e.b[offset:]))
+	end := uint64(offset) + 2 + 12*uint64(n) + 4
+	if end > uint64(len(e.b)) {
+		return nil, ErrMalformedExif
+	}
+	d := &dir{name: name, offset: offset, slot: n, entries: make([]entry, n)}
+	for i := range d.entries {
+		p := e.b[offset+2+12*uint32(i):]
+		en := &d.entries[i]
+		en.id, en.typ, en.count = e.order.Uint16(p), e.order.Uint16(p[2:]), e.order.Uint32(p[4:])
+		copy(en.value[:], p[8:12])
+	}
+	d.next = e.order.Uint32(e.b[end-4:])
+	return d, nil
+}
+
+// subDir reads the IFD the pointer tag id of d points to, nil when it is missing or broken
+func (e *exifEditor) subDir(name string, d *dir, id uint16) *dir {
+	for _, en := range d.entries {
+		if en.id != id {
+			continue
+		}
+		sub, err := e.readDir(name, e.order.Uint32(en.value[:]))
+		if err != nil {
+			return nil
+		}
+		return sub
+	}
+	return nil
+}
+
+// span returns where the value of en lives when it does not fit in the entry
+func (e *exifEditor) span(en entry) (start, size uint32, ok bool) {
+	n := uint64(typeSizes[en.typ]) * uint64(en.count)
+	if n <= 4 {
+		return 0, 0, false
+	}
+	start = e.order.Uint32(en.value[:])
+	// broken offsets are left alone
+	if uint64(start)+n > uint64(len(e.b)) {
+		return 0, 0, false
+	}
+	return start, uint32(n), true
+}
+
+func (e *exifEditor) zero(start, size uint32) {
+	for i := start; i < start+size; i++ {
+		e.b[i] = 0
+	}
+}
+
+func (e *exifEditor) zeroValue(en entry) {
+	if start, size, ok := e.span(en); ok {
+		e.zero(start, size)
+	}
+}
+
+// appendData appends v at a word boundary and returns its offset
+func (e *exifEditor) appendData(v []byte) uint32 {
+	if len(e.b)%2 == 1 {
+		e.b = append(e.b, 0)
+	}
+	offset := uint32(len(e.b))
+	e.b = append(e.b, v...)
+	return offset
+}
+
+// drop removes the entries of d f returns true for, except the pointers to sub IFDs
+func (e *exifEditor) drop(d *dir, f func(ifd string, id uint16) bool) {
+	kept := d.entries[:0]
+	for _, en := range d.entries {
+		pointer := en.id == ifd.ExifIFD || en.id == ifd.GPSIFD || en.id == ifd.InteropIFD
+		if !pointer && f(d.name, en.id) {
+			e.zeroValue(en)
+			continue
+		}
+		kept = append(kept, en)
+	}
+	d.entries = kept
+}
+
+// set replaces or adds the ascii tag id of d, keeping the entries sorted by id as tiff requires
+func (e *exifEditor) set(d *dir, id uint16, v string) {
+	value := append([]byte(v), 0)
+	en := entry{id: id, typ: typeASCII, count: uint32(len(value))}
+	if len(value) <= 4 {
+		copy(en.value[:], value)
+	} else {
+		e.order.PutUint32(en.value[:], e.appendData(value))
+	}
+	i := sort.Search(len(d.entries), func(i int) bool { return d.entries[i].id >= id })
+	if i < len(d.entries) && d.entries[i].id == id {
+		e.zeroValue(d.entries[i])
+		d.entries[i] = en
+		return
+	}
+	d.entries = append(d.entries, entry{})
+	copy(d.entries[i+1:], d.entries[i:])
+	d.entries[i] = en
+}
+
+// link writes the sub IFD and points the tag id of the parent to it, a sub IFD left without tags is removed
+func (e *exifEditor) link(parent, sub *dir, id uint16) {
+	if sub == nil {
+		return
+	}
+	for i, en := range parent.entries {
+		if en.id != id {
+			continue
+		}
+		if len(sub.entries) == 0 {
+			e.zero(sub.offset, uint32(2+12*sub.slot+4))
+			parent.entries = append(parent.entries[:i], parent.entries[i+1:]...)
+			return
+		}
+		e.order.PutUint32(parent.entries[i].value[:], e.writeDir(sub))
+		return
+	}
+}
+
+// writeDir writes d back in its slot when it fits and appends it otherwise, it returns the offset of d
+func (e *exifEditor) writeDir(d *dir) uint32 {
+	b := make([]byte, 2+12*len(d.entries)+4)
+	e.order.PutUint16(b, uint16(len(d.entries)))
+	for i, en := range d.entries {
+		p := b[2+12*i:]
+		e.order.PutUint16(p, en.id)
+		e.order.PutUint16(p[2:], en.typ)
+		e.order.PutUint32(p[4:], en.count)
+		copy(p[8:12], en.value[:])
+	}
+	e.order.PutUint32(b[len(b)-4:], d.next)
+	e.zero(d.offset, uint32(2+12*d.slot+4))
+	if len(d.entries) <= d.slot {
+		copy(e.b[d.offset:], b)
+		return d.offset
+	}
+	return e.appendData(b)
+}
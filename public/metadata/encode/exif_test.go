@@ -0,0 +1,85 @@
+package encode
+
+import (
+	"bytes"
+	"testing"
+
+	goexif "github.com/rwcarlsen/goexif/exif"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd/ifdtest"
+)
+
+func TestEditExif(t *testing.T) {
+	b := ifdtest.Exif()
+	got, err := editExif(b, exifEdit{
+		Set: map[uint16]string{tagCopyright: "© Byrd 2020", tagArtist: "Jane"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the values that were not edited keep their place
+	if !bytes.Equal(got[:len(b)][ifdtest.ValuesOffset:ifdtest.GPSOffset], b[ifdtest.ValuesOffset:ifdtest.GPSOffset]) {
+		t.Error("expected the exposure and maker note to keep their offsets")
+	}
+	x, err := goexif.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[goexif.FieldName]string{goexif.Copyright: "© Byrd 2020", goexif.Artist: "Jane"} {
+		tag, err := x.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := tag.StringVal(); v != want {
+			t.Errorf("expected %q got %q", want, v)
+		}
+	}
+	if tag, err := x.Get(goexif.GPSLatitude); err != nil || tag.String() != `["56/1","9/1","0/1"]` {
+		t.Errorf("expected the latitude got %v %v", tag, err)
+	}
+	if tag, err := x.Get(goexif.ExposureTime); err != nil || tag.String() != `"1/250"` {
+		t.Errorf("expected the exposure 1/250 got %v %v", tag, err)
+	}
+}
+
+func TestEditExifDrop(t *testing.T) {
+	got, err := editExif(ifdtest.Exif(), exifEdit{Drop: func(dir string, id uint16) bool {
+		return dir == "gps" || dir == "exif" && (id == ifd.MakerNote || id == tagBodySerialNumber)
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(got, ifdtest.MakerNote) || bytes.Contains(got, []byte("123\x00")) {
+		t.Error("expected the maker note and serial to be zeroed")
+	}
+	x, err := goexif.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []goexif.FieldName{goexif.GPSInfoIFDPointer, goexif.GPSLatitude, goexif.MakerNote} {
+		if _, err := x.Get(name); err == nil {
+			t.Errorf("expected %s to be removed", name)
+		}
+	}
+	if _, err := x.Get(goexif.ExposureTime); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEditExifNew(t *testing.T) {
+	got, err := editExif(nil, exifEdit{Set: map[uint16]string{tagImageDescription: "Fire at the harbour"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := goexif.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag, err := x.Get(goexif.ImageDescription); err != nil || tag.String() != `"Fire at the harbour"` {
+		t.Errorf("expected the caption got %v %v", tag, err)
+	}
+	if _, err := editExif([]byte("II*\x00\xff\x00\x00\x00"), exifEdit{}); err != ErrMalformedExif {
+		t.Errorf("expected %v got %v", ErrMalformedExif, err)
+	}
+}
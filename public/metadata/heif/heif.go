@@ -0,0 +1,466 @@
+// Package heif reads the items of HEIF images (ISO/IEC 23008-12), the HEIC files of iPhones.
+// HEIF is an ISOBMFF container: the meta box lists the items of the file, e.g. the coded
+// image, its thumbnail and the Exif block, and its iloc box says where their bytes are.
+package heif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+)
+
+// SniffLen is the number of leading bytes IsHEIF needs to recognize most files
+const SniffLen = 64
+
+// maxItemExtents bounds the extents of an item, encoders write one or a few
+const maxItemExtents = 256
+
+var (
+	ErrNotHEIF     = errors.New("heif: not a heif file")
+	ErrMalformed   = errors.New("heif: malformed box")
+	ErrNoExif      = errors.New("heif: no exif item")
+	ErrNoXMP       = errors.New("heif: no xmp item")
+	ErrNoItem      = errors.New("heif: item not found")
+	ErrUnsupported = errors.New("heif: unsupported item construction")
+)
+
+// brands of heif images and image sequences
+var brands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true,
+	"heim": true, "heis": true, "mif1": true, "msf1": true,
+}
+
+// IsHEIF reports whether header, the first bytes of a file, starts with the ftyp box of a heif file
+func IsHEIF(header []byte) bool {
+	if len(header) < 12 || string(header[4:8]) != "ftyp" {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(header))
+	if size < 16 || size > len(header) {
+		size = len(header)
+	}
+	// major brand, minor version, then the compatible brands
+	if brands[string(header[8:12])] {
+		return true
+	}
+	for i := 16; i+4 <= size; i += 4 {
+		if brands[string(header[i:i+4])] {
+			return true
+		}
+	}
+	return false
+}
+
+// item is an entry of the iinf box with its location from iloc
+type item struct {
+	id          uint32
+	typ         string
+	contentType string
+	method      uint16
+	baseOffset  uint64
+	extents     []extent
+}
+
+type extent struct {
+	offset, length uint64
+}
+
+// File is a parsed heif file
+type File struct {
+	data    []byte
+	idat    []byte
+	primary uint32
+	items   map[uint32]*item
+	// order keeps the items in the order of iinf
+	order []uint32
+	// props are the boxes of ipco, assoc the 1-based indexes of the properties of each item
+	props []box
+	assoc map[uint32][]int
+}
+
+// Parse reads the meta box of the heif file in b
+func Parse(b []byte) (*File, error) {
+	if !IsHEIF(b) {
+		return nil, ErrNotHEIF
+	}
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := find(boxes, "meta")
+	if !ok {
+		return nil, ErrMalformed
+	}
+	f := &File{data: b, items: make(map[uint32]*item), assoc: make(map[uint32][]int)}
+	// meta is a full box, its children follow version and flags
+	if len(meta.body) < 4 {
+		return nil, ErrMalformed
+	}
+	children, err := readBoxes(meta.body[4:])
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range children {
+		switch c.typ {
+		case "pitm":
+			err = f.readPitm(c.body)
+		case "iinf":
+			err = f.readIinf(c.body)
+		case "iloc":
+			err = f.readIloc(c.body)
+		case "iprp":
+			err = f.readIprp(c.body)
+		case "idat":
+			f.idat = c.body
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *File) readPitm(b []byte) error {
+	r := reader{b: b}
+	version, _ := r.fullBox()
+	if version == 0 {
+		f.primary = uint32(r.u16())
+	} else {
+		f.primary = r.u32()
+	}
+	return r.err
+}
+
+func (f *File) readIinf(b []byte) error {
+	r := reader{b: b}
+	version, _ := r.fullBox()
+	if version == 0 {
+		r.u16()
+	} else {
+		r.u32()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	// the entry count is not trusted, infe boxes run to the end of iinf
+	infes, err := readBoxes(r.rest())
+	if err != nil {
+		return err
+	}
+	for _, infe := range infes {
+		if infe.typ != "infe" {
+			continue
+		}
+		it, err := readInfe(infe.body)
+		if err != nil {
+			return err
+		}
+		if it == nil {
+			continue
+		}
+		if prev, ok := f.items[it.id]; ok {
+			prev.typ, prev.contentType = it.typ, it.contentType
+			continue
+		}
+		f.items[it.id] = it
+		f.order = append(f.order, it.id)
+	}
+	return nil
+}
+
+// readInfe reads an item info entry, entries before version 2 have no item type and are skipped
+func readInfe(b []byte) (*item, error) {
+	r := reader{b: b}
+	version, _ := r.fullBox()
+	if version < 2 {
+		return nil, r.err
+	}
+	it := &item{}
+	if version == 2 {
+		it.id = uint32(r.u16())
+	} else {
+		it.id = r.u32()
+	}
+	r.u16() // protection index
+	it.typ = string(r.bytes(4))
+	r.cstring() // name
+	if it.typ == "mime" {
+		it.contentType = r.cstring()
+	}
+	return it, r.err
+}
+
+func (f *File) readIloc(b []byte) error {
+	r := reader{b: b}
+	version, _ := r.fullBox()
+	sizes := r.u16()
+	offsetSize, lengthSize := int(sizes>>12), int(sizes>>8&0xf)
+	baseOffsetSize, indexSize := int(sizes>>4&0xf), int(sizes&0xf)
+	if version == 0 {
+		indexSize = 0
+	}
+	var count uint32
+	if version < 2 {
+		count = uint32(r.u16())
+	} else {
+		count = r.u32()
+	}
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		it := &item{}
+		if version < 2 {
+			it.id = uint32(r.u16())
+		} else {
+			it.id = r.u32()
+		}
+		if version > 0 {
+			it.method = r.u16() & 0xf
+		}
+		r.u16() // data reference index
+		it.baseOffset = r.uint(baseOffsetSize)
+		extents := int(r.u16())
+		if extents > maxItemExtents {
+			return ErrMalformed
+		}
+		for e := 0; e < extents && r.err == nil; e++ {
+			r.uint(indexSize)
+			it.extents = append(it.extents, extent{offset: r.uint(offsetSize), length: r.uint(lengthSize)})
+		}
+		if prev, ok := f.items[it.id]; ok {
+			prev.method, prev.baseOffset, prev.extents = it.method, it.baseOffset, it.extents
+			continue
+		}
+		f.items[it.id] = it
+		f.order = append(f.order, it.id)
+	}
+	return r.err
+}
+
+func (f *File) readIprp(b []byte) error {
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return err
+	}
+	for _, c := range boxes {
+		switch c.typ {
+		case "ipco":
+			if f.props, err = readBoxes(c.body); err != nil {
+				return err
+			}
+		case "ipma":
+			if err := f.readIpma(c.body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *File) readIpma(b []byte) error {
+	r := reader{b: b}
+	version, flags := r.fullBox()
+	count := r.u32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		var id uint32
+		if version < 1 {
+			id = uint32(r.u16())
+		} else {
+			id = r.u32()
+		}
+		n := int(r.u8())
+		for a := 0; a < n && r.err == nil; a++ {
+			// the high bit marks essential properties
+			var index int
+			if flags&1 == 1 {
+				index = int(r.u16() & 0x7fff)
+			} else {
+				index = int(r.u8() & 0x7f)
+			}
+			f.assoc[id] = append(f.assoc[id], index)
+		}
+	}
+	return r.err
+}
+
+// itemData returns the bytes of the item, joining its extents. Extents may overlap, so the joined
+// bytes are bounded by the size of the data they are read from.
+func (f *File) itemData(it *item) ([]byte, error) {
+	var src []byte
+	switch it.method {
+	case 0:
+		src = f.data
+	case 1:
+		src = f.idat
+	default:
+		return nil, ErrUnsupported
+	}
+	var out []byte
+	for _, e := range it.extents {
+		start := it.baseOffset + e.offset
+		length := e.length
+		// a zero length extent runs to the end of the data
+		if length == 0 && start <= uint64(len(src)) {
+			length = uint64(len(src)) - start
+		}
+		if start > uint64(len(src)) || length > uint64(len(src))-start || length > uint64(len(src)-len(out)) {
+			return nil, ErrMalformed
+		}
+		out = append(out, src[start:start+length]...)
+	}
+	return out, nil
+}
+
+// Exif returns the TIFF encoded Exif of the file
+func (f *File) Exif() ([]byte, error) {
+	for _, id := range f.order {
+		it := f.items[id]
+		if it.typ != "Exif" {
+			continue
+		}
+		b, err := f.itemData(it)
+		if err != nil {
+			return nil, err
+		}
+		// the block starts with the offset of the TIFF header, most writers put "Exif\0\0" in between
+		if len(b) < 4 {
+			return nil, ErrMalformed
+		}
+		offset := uint64(binary.BigEndian.Uint32(b)) + 4
+		if offset > uint64(len(b)) {
+			return nil, ErrMalformed
+		}
+		b = bytes.TrimPrefix(b[offset:], []byte("Exif\x00\x00"))
+		if !ifd.IsTIFF(b) {
+			return nil, ErrMalformed
+		}
+		return b, nil
+	}
+	return nil, ErrNoExif
+}
+
+// XMP returns the XMP packet of the file, stored as a mime item of RDF/XML
+func (f *File) XMP() ([]byte, error) {
+	for _, id := range f.order {
+		if it := f.items[id]; it.typ == "mime" && it.contentType == "application/rdf+xml" {
+			return f.itemData(it)
+		}
+	}
+	return nil, ErrNoXMP
+}
+
+// Dimensions returns the size of the primary image from its ispe property
+func (f *File) Dimensions() (width, height int, err error) {
+	for _, index := range f.assoc[f.primary] {
+		if index < 1 || index > len(f.props) || f.props[index-1].typ != "ispe" {
+			continue
+		}
+		r := reader{b: f.props[index-1].body}
+		r.fullBox()
+		width, height = int(r.u32()), int(r.u32())
+		return width, height, r.err
+	}
+	return 0, 0, ErrNoItem
+}
+
+type box struct {
+	typ  string
+	body []byte
+}
+
+func find(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// readBoxes splits b into its boxes
+func readBoxes(b []byte) ([]box, error) {
+	var boxes []box
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, ErrMalformed
+		}
+		size := uint64(binary.BigEndian.Uint32(b))
+		typ := string(b[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return nil, ErrMalformed
+			}
+			size, header = binary.BigEndian.Uint64(b[8:]), 16
+		}
+		if size < header || size > uint64(len(b)) {
+			return nil, ErrMalformed
+		}
+		boxes = append(boxes, box{typ: typ, body: b[header:size]})
+		b = b[size:]
+	}
+	return boxes, nil
+}
+
+// reader reads big endian fields, after the first out of bounds read err is set and reads return zero
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.b) {
+		r.err = ErrMalformed
+		return make([]byte, n)
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *reader) rest() []byte {
+	b := r.b
+	r.b = nil
+	return b
+}
+
+func (r *reader) u8() uint8   { return r.bytes(1)[0] }
+func (r *reader) u16() uint16 { return binary.BigEndian.Uint16(r.bytes(2)) }
+func (r *reader) u32() uint32 { return binary.BigEndian.Uint32(r.bytes(4)) }
+
+// uint reads an unsigned integer of size 0, 4 or 8 bytes as used by iloc
+func (r *reader) uint(size int) uint64 {
+	switch size {
+	case 0:
+		return 0
+	case 4:
+		return uint64(r.u32())
+	case 8:
+		return binary.BigEndian.Uint64(r.bytes(8))
+	}
+	r.err = ErrMalformed
+	return 0
+}
+
+// fullBox reads the version and flags of a full box
+func (r *reader) fullBox() (version uint8, flags uint32) {
+	v := r.u32()
+	return uint8(v >> 24), v & 0xffffff
+}
+
+func (r *reader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.b, 0)
+	if i < 0 {
+		r.err = ErrMalformed
+		return ""
+	}
+	s := string(r.b[:i])
+	r.b = r.b[i+1:]
+	return s
+}
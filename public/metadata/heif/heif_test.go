@@ -0,0 +1,109 @@
+package heif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func be16(v uint16) []byte { b := make([]byte, 2); binary.BigEndian.PutUint16(b, v); return b }
+func be32(v uint32) []byte { b := make([]byte, 4); binary.BigEndian.PutUint32(b, v); return b }
+
+func mkbox(typ string, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	return append(append(be32(uint32(len(body)+8)), typ...), body...)
+}
+
+func fullbox(typ string, version uint8, flags uint32, parts ...[]byte) []byte {
+	return mkbox(typ, append([][]byte{be32(uint32(version)<<24 | flags)}, parts...)...)
+}
+
+var tiff = []byte("MM\x00*\x00\x00\x00\x08 model tags")
+
+// testFile builds a heif file with an image item 1 and an Exif item 2 stored in mdat,
+// split over two extents
+func testFile(exifPrefix []byte) []byte {
+	exif := append(append(be32(uint32(len(exifPrefix))), exifPrefix...), tiff...)
+	ftyp := mkbox("ftyp", []byte("heic"), be32(0), []byte("mif1heic"))
+	meta := func(mdatOffset uint32) []byte {
+		return fullbox("meta", 0, 0,
+			fullbox("hdlr", 0, 0, be32(0), []byte("pict"), make([]byte, 13)),
+			fullbox("pitm", 0, 0, be16(1)),
+			fullbox("iinf", 0, 0, be16(2),
+				fullbox("infe", 2, 0, be16(1), be16(0), []byte("hvc1"), []byte("\x00")),
+				fullbox("infe", 2, 0, be16(2), be16(0), []byte("Exif"), []byte("\x00"))),
+			fullbox("iloc", 1, 0, []byte{0x44, 0x00}, be16(2),
+				be16(1), be16(0), be16(0), be16(1), be32(mdatOffset), be32(4),
+				be16(2), be16(0), be16(0), be16(2),
+				be32(mdatOffset+4), be32(6), be32(mdatOffset+10), be32(uint32(len(exif)-6))),
+			mkbox("iprp",
+				mkbox("ipco", fullbox("ispe", 0, 0, be32(4032), be32(3024))),
+				fullbox("ipma", 0, 0, be32(1), be16(1), []byte{1, 0x81})))
+	}
+	// mdat data starts after ftyp, meta and the mdat header
+	offset := uint32(len(ftyp) + len(meta(0)) + 8)
+	return bytes.Join([][]byte{ftyp, meta(offset), mkbox("mdat", []byte("hevc"), exif)}, nil)
+}
+
+func TestIsHEIF(t *testing.T) {
+	tests := []struct {
+		header []byte
+		want   bool
+	}{
+		{testFile(nil), true},
+		{mkbox("ftyp", []byte("avif"), be32(0), []byte("mif1")), true},
+		{mkbox("ftyp", []byte("isom"), be32(0), []byte("mp41")), false},
+		{[]byte("\xff\xd8\xff\xe1\x00\x10Exif\x00\x00"), false},
+		{nil, false},
+	}
+	for i, test := range tests {
+		if got := IsHEIF(test.header); got != test.want {
+			t.Errorf("%d: expected %v got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestExif(t *testing.T) {
+	for _, prefix := range [][]byte{nil, []byte("Exif\x00\x00")} {
+		f, err := Parse(testFile(prefix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, err := f.Exif()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(x, tiff) {
+			t.Errorf("expected %q got %q", tiff, x)
+		}
+		if w, h, err := f.Dimensions(); w != 4032 || h != 3024 || err != nil {
+			t.Errorf("expected 4032x3024 got %dx%d %v", w, h, err)
+		}
+	}
+}
+
+func TestItemDataBounded(t *testing.T) {
+	f := &File{data: make([]byte, 10)}
+	// two extents each running over all of the data
+	whole := &item{extents: []extent{{offset: 0, length: 0}, {offset: 0, length: 10}}}
+	if _, err := f.itemData(whole); err != ErrMalformed {
+		t.Errorf("expected %v got %v", ErrMalformed, err)
+	}
+	halves := &item{extents: []extent{{offset: 0, length: 5}, {offset: 5, length: 0}}}
+	if b, err := f.itemData(halves); err != nil || len(b) != 10 {
+		t.Errorf("expected the 10 bytes got %d %v", len(b), err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	b := testFile(nil)
+	for _, n := range []int{12, 40, 100, len(b) - 10} {
+		f, err := Parse(b[:n])
+		if err == nil {
+			_, err = f.Exif()
+		}
+		if err == nil {
+			t.Errorf("%d bytes: expected an error", n)
+		}
+	}
+}
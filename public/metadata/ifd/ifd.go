@@ -0,0 +1,19 @@
+// Package ifd holds what the exif readers and writers share about the TIFF structure of exif
+package ifd
+
+import "bytes"
+
+// Pointers from IFD0 and the Exif IFD to their sub IFDs
+const (
+	ExifIFD    = 0x8769
+	GPSIFD     = 0x8825
+	InteropIFD = 0xA005
+)
+
+// MakerNote holds the camera's proprietary maker note in the Exif IFD
+const MakerNote = 0x927C
+
+// IsTIFF reports whether b starts with a little or big endian TIFF header, as exif and the camera RAW formats do
+func IsTIFF(b []byte) bool {
+	return bytes.HasPrefix(b, []byte("II*\x00")) || bytes.HasPrefix(b, []byte("MM\x00*"))
+}
@@ -0,0 +1,107 @@
+// Package ifdtest builds little endian TIFF files for the tests of the exif readers and writers
+package ifdtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+)
+
+// TIFF types of entries
+const (
+	Byte      = 1
+	ASCII     = 2
+	Short     = 3
+	Long      = 4
+	Rational  = 5
+	Undefined = 7
+)
+
+var le = binary.LittleEndian
+
+// Entry is an IFD entry of a tag, type, count and value. The value holds values of up to 4 bytes and
+// the offset of longer ones.
+type Entry [4]uint32
+
+// Inline returns the value of an entry holding s, which must be at most 4 bytes
+func Inline(s string) uint32 {
+	var b [4]byte
+	copy(b[:], s)
+	return le.Uint32(b[:])
+}
+
+// WriteHeader writes a little endian TIFF header of which IFD0 is at ifd0
+func WriteHeader(buf *bytes.Buffer, ifd0 uint32) {
+	buf.WriteString("II*\x00")
+	_ = binary.Write(buf, le, ifd0)
+}
+
+// WriteIFD writes an IFD of entries without a next IFD
+func WriteIFD(buf *bytes.Buffer, entries []Entry) {
+	_ = binary.Write(buf, le, uint16(len(entries)))
+	for _, e := range entries {
+		_ = binary.Write(buf, le, []uint16{uint16(e[0]), uint16(e[1])})
+		_ = binary.Write(buf, le, e[2:])
+	}
+	_ = binary.Write(buf, le, uint32(0))
+}
+
+// Write writes values, e.g. the numerators and denominators of rationals
+func Write(buf *bytes.Buffer, values ...uint32) {
+	_ = binary.Write(buf, le, values)
+}
+
+// Offsets of the parts of Exif
+const (
+	ExifOffset      = 74
+	ValuesOffset    = 140
+	MakerNoteOffset = 148
+	GPSOffset       = 160
+	LatitudeOffset  = 214
+	TimestampOffset = 238
+	Size            = 262
+)
+
+// MakerNote is the maker note of Exif, in real files it holds offsets into the exif so it must not move
+var MakerNote = []byte("SERIAL-1234")
+
+// Exif returns an exif of
+//
+//	ifd0: Make "Cam", Orientation 6, Copyright "old" and the pointers to the Exif and GPS IFDs
+//	exif: ExposureTime 1/250, ISOSpeedRatings 200, the maker note, BodySerialNumber "123" and an unknown tag 0xBEEF
+//	gps:  latitude N 56/1 9/1 0/1, AltitudeRef 0 and TimeStamp 13/1 37/1 5/2
+func Exif() []byte {
+	var buf bytes.Buffer
+	WriteHeader(&buf, 8)
+	WriteIFD(&buf, []Entry{
+		{0x010F, ASCII, 4, Inline("Cam")},
+		{0x0112, Short, 1, 6},
+		{0x8298, ASCII, 4, Inline("old")},
+		{ifd.ExifIFD, Long, 1, ExifOffset},
+		{ifd.GPSIFD, Long, 1, GPSOffset},
+	})
+	WriteIFD(&buf, []Entry{
+		{0x829A, Rational, 1, ValuesOffset},
+		{0x8827, Short, 1, 200},
+		{ifd.MakerNote, Undefined, uint32(len(MakerNote)), MakerNoteOffset},
+		{0xA431, ASCII, 4, Inline("123")},
+		{0xBEEF, Long, 1, 0xCAFE},
+	})
+	Write(&buf, 1, 250)
+	buf.Write(MakerNote)
+	buf.Write(make([]byte, GPSOffset-buf.Len()))
+	WriteIFD(&buf, []Entry{
+		{0x0001, ASCII, 2, Inline("N")},
+		{0x0002, Rational, 3, LatitudeOffset},
+		{0x0005, Byte, 1, 0},
+		{0x0007, Rational, 3, TimestampOffset},
+	})
+	Write(&buf, 56, 1, 9, 1, 0, 1)
+	Write(&buf, 13, 1, 37, 1, 5, 2)
+	if buf.Len() != Size {
+		panic(fmt.Sprintf("ifdtest: expected %d bytes got %d", Size, buf.Len()))
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,342 @@
+package image
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/rwcarlsen/goexif/tiff"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+)
+
+// maxValueBytes bounds the bytes of binary values in a dump, longer values are truncated
+const maxValueBytes = 512
+
+// Dump is every tag of the exif of an image, by IFD
+type Dump struct {
+	IFDs []IFD `json:"ifds"`
+	// MakerNote reports whether the camera wrote its proprietary maker note, its value is not dumped
+	MakerNote bool `json:"makerNote"`
+}
+
+// IFD is a directory of tags: ifd0, ifd1 (the thumbnail) and further IFDs of the chain, exif, gps or interop
+type IFD struct {
+	Name string `json:"name"`
+	Tags []Tag  `json:"tags"`
+}
+
+// Tag is a tag and its value typed by the tiff type: a string for ascii, an int64 or float64
+// for numbers and a Rational for rationals, or a slice of them when the tag holds more than one.
+// Binary values and the values of unknown tags are hex encoded.
+type Tag struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name,omitempty"`
+	Type      string      `json:"type"`
+	Count     uint32      `json:"count"`
+	Value     interface{} `json:"value,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// Rational is an exact tiff rational, e.g. 1/250 for an exposure time
+type Rational struct {
+	Num int64 `json:"num"`
+	Den int64 `json:"den"`
+}
+
+var typeNames = map[tiff.DataType]string{
+	tiff.DTByte:      "byte",
+	tiff.DTAscii:     "ascii",
+	tiff.DTShort:     "short",
+	tiff.DTLong:      "long",
+	tiff.DTRational:  "rational",
+	tiff.DTSByte:     "sbyte",
+	tiff.DTUndefined: "undefined",
+	tiff.DTSShort:    "sshort",
+	tiff.DTSLong:     "slong",
+	tiff.DTSRational: "srational",
+	tiff.DTFloat:     "float",
+	tiff.DTDouble:    "double",
+}
+
+// Dump returns every tag of the exif, including the GPS and interoperability IFDs goexif merges into one map
+func (e *imgExifData) Dump() *Dump {
+	d := &Dump{}
+	if e.x.Tiff == nil {
+		return d
+	}
+	for i, dir := range e.x.Tiff.Dirs {
+		d.add(fmt.Sprintf("ifd%d", i), dir, tiffTags)
+	}
+	if len(e.x.Tiff.Dirs) == 0 {
+		return d
+	}
+	r := bytes.NewReader(e.x.Raw)
+	ifd0 := e.x.Tiff.Dirs[0]
+	if exif := subDir(r, e.x.Tiff, ifd0, ifd.ExifIFD); exif != nil {
+		d.add("exif", exif, tiffTags)
+		if interop := subDir(r, e.x.Tiff, exif, ifd.InteropIFD); interop != nil {
+			d.add("interop", interop, interopTags)
+		}
+	}
+	if gps := subDir(r, e.x.Tiff, ifd0, ifd.GPSIFD); gps != nil {
+		d.add("gps", gps, gpsTags)
+	}
+	return d
+}
+
+// subDir reads the IFD the pointer tag id of d points to, nil when it is missing or broken
+func subDir(r *bytes.Reader, t *tiff.Tiff, d *tiff.Dir, id uint16) *tiff.Dir {
+	offset, ok := intOf(d, id)
+	if !ok || offset <= 0 || offset >= r.Size() {
+		return nil
+	}
+	if _, err := r.Seek(offset, 0); err != nil {
+		return nil
+	}
+	sub, _, err := tiff.DecodeDir(r, t.Order)
+	if err != nil {
+		return nil
+	}
+	return sub
+}
+
+func (d *Dump) add(name string, dir *tiff.Dir, names map[uint16]string) {
+	out := IFD{Name: name, Tags: make([]Tag, 0, len(dir.Tags))}
+	for _, t := range dir.Tags {
+		tag := Tag{
+			ID:    fmt.Sprintf("0x%04X", t.Id),
+			Name:  names[t.Id],
+			Type:  typeNames[t.Type],
+			Count: t.Count,
+		}
+		if tag.Type == "" {
+			tag.Type = fmt.Sprintf("0x%04X", uint16(t.Type))
+		}
+		switch {
+		case t.Id == ifd.MakerNote:
+			d.MakerNote = true
+		case tag.Name == "":
+			tag.Value, tag.Truncated = hexValue(t.Val)
+		default:
+			tag.Value, tag.Truncated = value(t)
+		}
+		out.Tags = append(out.Tags, tag)
+	}
+	d.IFDs = append(d.IFDs, out)
+}
+
+func hexValue(b []byte) (string, bool) {
+	if len(b) > maxValueBytes {
+		return hex.EncodeToString(b[:maxValueBytes]), true
+	}
+	return hex.EncodeToString(b), false
+}
+
+// value returns the typed value of t
+func value(t *tiff.Tag) (interface{}, bool) {
+	switch t.Format() {
+	case tiff.StringVal:
+		s, _ := t.StringVal()
+		return s, false
+	case tiff.UndefVal:
+		// undefined values are often ascii, like the ExifVersion 0231
+		if s := bytes.TrimRight(t.Val, "\x00"); isPrintable(s) {
+			return string(s), false
+		}
+		return hexValue(t.Val)
+	case tiff.OtherVal:
+		return hexValue(t.Val)
+	}
+	if len(t.Val) > maxValueBytes {
+		return hexValue(t.Val)
+	}
+	values := make([]interface{}, 0, t.Count)
+	for i := 0; i < int(t.Count); i++ {
+		switch t.Format() {
+		case tiff.IntVal:
+			v, _ := t.Int64(i)
+			values = append(values, v)
+		case tiff.RatVal:
+			num, den, _ := t.Rat2(i)
+			values = append(values, Rational{Num: num, Den: den})
+		case tiff.FloatVal:
+			v, _ := t.Float(i)
+			// json has no NaN or infinity
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return hexValue(t.Val)
+			}
+			values = append(values, v)
+		}
+	}
+	if len(values) == 1 {
+		return values[0], false
+	}
+	return values, false
+}
+
+func isPrintable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// tiffTags are the names of the tags of IFD0, IFD1 and the Exif IFD
+var tiffTags = map[uint16]string{
+	0x00FE: "NewSubfileType",
+	0x0100: "ImageWidth",
+	0x0101: "ImageLength",
+	0x0102: "BitsPerSample",
+	0x0103: "Compression",
+	0x0106: "PhotometricInterpretation",
+	0x010E: "ImageDescription",
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0111: "StripOffsets",
+	0x0112: "Orientation",
+	0x0115: "SamplesPerPixel",
+	0x0116: "RowsPerStrip",
+	0x0117: "StripByteCounts",
+	0x011A: "XResolution",
+	0x011B: "YResolution",
+	0x011C: "PlanarConfiguration",
+	0x0128: "ResolutionUnit",
+	0x012D: "TransferFunction",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013B: "Artist",
+	0x013C: "HostComputer",
+	0x013E: "WhitePoint",
+	0x013F: "PrimaryChromaticities",
+	0x014A: "SubIFDs",
+	0x0201: "JPEGInterchangeFormat",
+	0x0202: "JPEGInterchangeFormatLength",
+	0x0211: "YCbCrCoefficients",
+	0x0212: "YCbCrSubSampling",
+	0x0213: "YCbCrPositioning",
+	0x0214: "ReferenceBlackWhite",
+	0x02BC: "XMLPacket",
+	0x4746: "Rating",
+	0x8298: "Copyright",
+	0x829A: "ExposureTime",
+	0x829D: "FNumber",
+	0x83BB: "IPTCNAA",
+	0x8769: "ExifIFDPointer",
+	0x8773: "InterColorProfile",
+	0x8822: "ExposureProgram",
+	0x8824: "SpectralSensitivity",
+	0x8825: "GPSInfoIFDPointer",
+	0x8827: "ISOSpeedRatings",
+	0x8828: "OECF",
+	0x8830: "SensitivityType",
+	0x8832: "RecommendedExposureIndex",
+	0x9000: "ExifVersion",
+	0x9003: "DateTimeOriginal",
+	0x9004: "DateTimeDigitized",
+	0x9010: "OffsetTime",
+	0x9011: "OffsetTimeOriginal",
+	0x9012: "OffsetTimeDigitized",
+	0x9101: "ComponentsConfiguration",
+	0x9102: "CompressedBitsPerPixel",
+	0x9201: "ShutterSpeedValue",
+	0x9202: "ApertureValue",
+	0x9203: "BrightnessValue",
+	0x9204: "ExposureBiasValue",
+	0x9205: "MaxApertureValue",
+	0x9206: "SubjectDistance",
+	0x9207: "MeteringMode",
+	0x9208: "LightSource",
+	0x9209: "Flash",
+	0x920A: "FocalLength",
+	0x9214: "SubjectArea",
+	0x927C: "MakerNote",
+	0x9286: "UserComment",
+	0x9290: "SubSecTime",
+	0x9291: "SubSecTimeOriginal",
+	0x9292: "SubSecTimeDigitized",
+	0xA000: "FlashpixVersion",
+	0xA001: "ColorSpace",
+	0xA002: "PixelXDimension",
+	0xA003: "PixelYDimension",
+	0xA004: "RelatedSoundFile",
+	0xA005: "InteroperabilityIFDPointer",
+	0xA20B: "FlashEnergy",
+	0xA20C: "SpatialFrequencyResponse",
+	0xA20E: "FocalPlaneXResolution",
+	0xA20F: "FocalPlaneYResolution",
+	0xA210: "FocalPlaneResolutionUnit",
+	0xA214: "SubjectLocation",
+	0xA215: "ExposureIndex",
+	0xA217: "SensingMethod",
+	0xA300: "FileSource",
+	0xA301: "SceneType",
+	0xA302: "CFAPattern",
+	0xA401: "CustomRendered",
+	0xA402: "ExposureMode",
+	0xA403: "WhiteBalance",
+	0xA404: "DigitalZoomRatio",
+	0xA405: "FocalLengthIn35mmFilm",
+	0xA406: "SceneCaptureType",
+	0xA407: "GainControl",
+	0xA408: "Contrast",
+	0xA409: "Saturation",
+	0xA40A: "Sharpness",
+	0xA40B: "DeviceSettingDescription",
+	0xA40C: "SubjectDistanceRange",
+	0xA420: "ImageUniqueID",
+	0xA430: "CameraOwnerName",
+	0xA431: "BodySerialNumber",
+	0xA432: "LensSpecification",
+	0xA433: "LensMake",
+	0xA434: "LensModel",
+	0xA435: "LensSerialNumber",
+	0xC612: "DNGVersion",
+	0xC614: "UniqueCameraModel",
+	0xC62F: "CameraSerialNumber",
+}
+
+// gpsTags are the names of the tags of the GPS IFD
+var gpsTags = map[uint16]string{
+	0x0000: "GPSVersionID",
+	0x0001: "GPSLatitudeRef",
+	0x0002: "GPSLatitude",
+	0x0003: "GPSLongitudeRef",
+	0x0004: "GPSLongitude",
+	0x0005: "GPSAltitudeRef",
+	0x0006: "GPSAltitude",
+	0x0007: "GPSTimeStamp",
+	0x0008: "GPSSatelites",
+	0x0009: "GPSStatus",
+	0x000A: "GPSMeasureMode",
+	0x000B: "GPSDOP",
+	0x000C: "GPSSpeedRef",
+	0x000D: "GPSSpeed",
+	0x000E: "GPSTrackRef",
+	0x000F: "GPSTrack",
+	0x0010: "GPSImgDirectionRef",
+	0x0011: "GPSImgDirection",
+	0x0012: "GPSMapDatum",
+	0x0013: "GPSDestLatitudeRef",
+	0x0014: "GPSDestLatitude",
+	0x0015: "GPSDestLongitudeRef",
+	0x0016: "GPSDestLongitude",
+	0x0017: "GPSDestBearingRef",
+	0x0018: "GPSDestBearing",
+	0x0019: "GPSDestDistanceRef",
+	0x001A: "GPSDestDistance",
+	0x001B: "GPSProcessingMethod",
+	0x001C: "GPSAreaInformation",
+	0x001D: "GPSDateStamp",
+	0x001E: "GPSDifferential",
+	0x001F: "GPSHPositioningError",
+}
+
+// interopTags are the names of the tags of the interoperability IFD
+var interopTags = map[uint16]string{
+	0x0001: "InteroperabilityIndex",
+	0x0002: "InteroperabilityVersion",
+}
@@ -0,0 +1,59 @@
+package image
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd/ifdtest"
+)
+
+func TestDump(t *testing.T) {
+	m, err := ImageMetadata(bytes.NewReader(ifdtest.Exif()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := m.Dump()
+	if !d.MakerNote {
+		t.Error("expected a maker note")
+	}
+	want := []IFD{
+		{Name: "ifd0", Tags: []Tag{
+			{ID: "0x010F", Name: "Make", Type: "ascii", Count: 4, Value: "Cam"},
+			{ID: "0x0112", Name: "Orientation", Type: "short", Count: 1, Value: int64(6)},
+			{ID: "0x8298", Name: "Copyright", Type: "ascii", Count: 4, Value: "old"},
+			{ID: "0x8769", Name: "ExifIFDPointer", Type: "long", Count: 1, Value: int64(ifdtest.ExifOffset)},
+			{ID: "0x8825", Name: "GPSInfoIFDPointer", Type: "long", Count: 1, Value: int64(ifdtest.GPSOffset)},
+		}},
+		{Name: "exif", Tags: []Tag{
+			{ID: "0x829A", Name: "ExposureTime", Type: "rational", Count: 1, Value: Rational{1, 250}},
+			{ID: "0x8827", Name: "ISOSpeedRatings", Type: "short", Count: 1, Value: int64(200)},
+			{ID: "0x927C", Name: "MakerNote", Type: "undefined", Count: uint32(len(ifdtest.MakerNote))},
+			{ID: "0xA431", Name: "BodySerialNumber", Type: "ascii", Count: 4, Value: "123"},
+			{ID: "0xBEEF", Type: "long", Count: 1, Value: "feca0000"},
+		}},
+		{Name: "gps", Tags: []Tag{
+			{ID: "0x0001", Name: "GPSLatitudeRef", Type: "ascii", Count: 2, Value: "N"},
+			{ID: "0x0002", Name: "GPSLatitude", Type: "rational", Count: 3,
+				Value: []interface{}{Rational{56, 1}, Rational{9, 1}, Rational{0, 1}}},
+			{ID: "0x0005", Name: "GPSAltitudeRef", Type: "byte", Count: 1, Value: int64(0)},
+			{ID: "0x0007", Name: "GPSTimeStamp", Type: "rational", Count: 3,
+				Value: []interface{}{Rational{13, 1}, Rational{37, 1}, Rational{5, 2}}},
+		}},
+	}
+	if !reflect.DeepEqual(d.IFDs, want) {
+		t.Errorf("expected %+v got %+v", want, d.IFDs)
+	}
+	if _, err := json.Marshal(d); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValueTruncated(t *testing.T) {
+	b := bytes.Repeat([]byte{0xAB}, maxValueBytes+1)
+	v, truncated := hexValue(b)
+	if !truncated || len(v) != 2*maxValueBytes {
+		t.Errorf("expected %d hex digits truncated got %d %v", 2*maxValueBytes, len(v), truncated)
+	}
+}
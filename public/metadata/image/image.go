@@ -120,6 +120,33 @@ func (e *imgExifData) Model() (model string, err error) {
 	return tag.StringVal()
 }
 
+// Artist returns the photographer
+func (e *imgExifData) Artist() (artist string, err error) {
+	tag, err := e.x.Get(goexif.Artist)
+	if err != nil {
+		return artist, err
+	}
+	return tag.StringVal()
+}
+
+// Description returns the title or caption of the image
+func (e *imgExifData) Description() (desc string, err error) {
+	tag, err := e.x.Get(goexif.ImageDescription)
+	if err != nil {
+		return desc, err
+	}
+	return tag.StringVal()
+}
+
+// Lens returns the lens model, which cameras write next to the body model
+func (e *imgExifData) Lens() (lens string, err error) {
+	tag, err := e.x.Get(goexif.LensModel)
+	if err != nil {
+		return lens, err
+	}
+	return tag.StringVal()
+}
+
 func (e *imgExifData) Dimensions() (width int, height int, err error) {
 	var fNames = []goexif.FieldName{goexif.PixelXDimension, goexif.PixelYDimension}
 	var dim []int
@@ -0,0 +1,199 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"image/jpeg"
+
+	"github.com/rwcarlsen/goexif/tiff"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+)
+
+// Camera RAW files (DNG, CR2, NEF, ARW) are TIFF files: IFD0 and its chain, and the SubIFDs
+// of IFD0, hold the raw sensor data next to JPEG previews of it. The exif of the camera
+// is read from them as from any TIFF; the previews are found through the tags below.
+const (
+	tagImageWidth                  = 0x0100
+	tagImageLength                 = 0x0101
+	tagCompression                 = 0x0103
+	tagStripOffsets                = 0x0111
+	tagOrientation                 = 0x0112
+	tagStripByteCounts             = 0x0117
+	tagSubIFDs                     = 0x014A
+	tagJPEGInterchangeFormat       = 0x0201
+	tagJPEGInterchangeFormatLength = 0x0202
+	tagXMP                         = 0x02BC
+	tagIPTC                        = 0x83BB
+)
+
+const (
+	compressionOldJPEG = 6
+	compressionJPEG    = 7
+)
+
+// maxRawDirs bounds the IFDs read from a file, cameras write fewer than ten
+const maxRawDirs = 32
+
+var (
+	ErrNotRaw    = errors.New("raw: not a TIFF based raw file")
+	ErrNoPreview = errors.New("raw: no embedded JPEG preview")
+	ErrNoTag     = errors.New("raw: tag not present")
+)
+
+// Raw is the IFD structure of a camera RAW file
+type Raw struct {
+	data []byte
+	dirs []*tiff.Dir
+}
+
+// DecodeRaw reads the IFDs of the RAW file in b, including the SubIFDs of IFD0
+func DecodeRaw(b []byte) (*Raw, error) {
+	if !ifd.IsTIFF(b) {
+		return nil, ErrNotRaw
+	}
+	t, err := tiff.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	raw := &Raw{data: b, dirs: t.Dirs}
+	if len(t.Dirs) == 0 {
+		return raw, nil
+	}
+	sub := tagOf(t.Dirs[0], tagSubIFDs)
+	if sub == nil {
+		return raw, nil
+	}
+	r := bytes.NewReader(b)
+	for i := 0; i < int(sub.Count) && len(raw.dirs) < maxRawDirs; i++ {
+		offset, err := sub.Int64(i)
+		if err != nil || offset <= 0 || offset >= int64(len(b)) {
+			continue
+		}
+		if _, err := r.Seek(offset, 0); err != nil {
+			continue
+		}
+		// a broken SubIFD does not hide the exif of the others
+		if d, _, err := tiff.DecodeDir(r, t.Order); err == nil {
+			raw.dirs = append(raw.dirs, d)
+		}
+	}
+	return raw, nil
+}
+
+func tagOf(d *tiff.Dir, id uint16) *tiff.Tag {
+	for _, t := range d.Tags {
+		if t.Id == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// intOf returns the first value of the tag id in d
+func intOf(d *tiff.Dir, id uint16) (int64, bool) {
+	t := tagOf(d, id)
+	if t == nil {
+		return 0, false
+	}
+	v, err := t.Int64(0)
+	return v, err == nil
+}
+
+// Dimensions returns the size of the largest image of the file, which is the raw sensor image
+func (r *Raw) Dimensions() (width int, height int, err error) {
+	for _, d := range r.dirs {
+		w, okw := intOf(d, tagImageWidth)
+		h, okh := intOf(d, tagImageLength)
+		if okw && okh && w*h > int64(width*height) {
+			width, height = int(w), int(h)
+		}
+	}
+	if width == 0 {
+		return 0, 0, errors.New("raw: no image dimensions")
+	}
+	return width, height, nil
+}
+
+// Orientation returns the EXIF orientation of IFD0, 1 when it is not set.
+// Embedded previews are stored unrotated and follow this orientation.
+func (r *Raw) Orientation() int {
+	if len(r.dirs) > 0 {
+		if o, ok := intOf(r.dirs[0], tagOrientation); ok && o >= 1 && o <= 8 {
+			return int(o)
+		}
+	}
+	return 1
+}
+
+// XMP returns the XMP packet of IFD0
+func (r *Raw) XMP() ([]byte, error) {
+	return r.ifd0Bytes(tagXMP)
+}
+
+// IPTC returns the IPTC-IIM record of IFD0
+func (r *Raw) IPTC() ([]byte, error) {
+	return r.ifd0Bytes(tagIPTC)
+}
+
+func (r *Raw) ifd0Bytes(id uint16) ([]byte, error) {
+	if len(r.dirs) == 0 {
+		return nil, ErrNoTag
+	}
+	t := tagOf(r.dirs[0], id)
+	if t == nil {
+		return nil, ErrNoTag
+	}
+	return t.Val, nil
+}
+
+// Preview returns the largest embedded JPEG preview that image/jpeg decodes.
+// Previews are referenced by JPEGInterchangeFormat, or by the single strip of a JPEG compressed IFD;
+// the lossless JPEG raw data of DNG files is skipped.
+func (r *Raw) Preview() ([]byte, error) {
+	var best []byte
+	var bestArea int
+	for _, d := range r.dirs {
+		start, size, ok := previewOf(d)
+		if !ok || start < 0 || size <= 0 || start >= int64(len(r.data)) || size > int64(len(r.data))-start {
+			continue
+		}
+		b := r.data[start : start+size]
+		if !bytes.HasPrefix(b, []byte{0xFF, 0xD8}) {
+			continue
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(b))
+		if err != nil {
+			continue
+		}
+		if area := cfg.Width * cfg.Height; area > bestArea {
+			best, bestArea = b, area
+		}
+	}
+	if best == nil {
+		return nil, ErrNoPreview
+	}
+	return best, nil
+}
+
+// previewOf returns the location of the JPEG data of d
+func previewOf(d *tiff.Dir) (start, size int64, ok bool) {
+	if start, ok := intOf(d, tagJPEGInterchangeFormat); ok {
+		size, ok := intOf(d, tagJPEGInterchangeFormatLength)
+		return start, size, ok
+	}
+	compression, _ := intOf(d, tagCompression)
+	if compression != compressionOldJPEG && compression != compressionJPEG {
+		return 0, 0, false
+	}
+	offsets, counts := tagOf(d, tagStripOffsets), tagOf(d, tagStripByteCounts)
+	if offsets == nil || counts == nil || offsets.Count != 1 || counts.Count != 1 {
+		return 0, 0, false
+	}
+	start, err := offsets.Int64(0)
+	if err != nil {
+		return 0, 0, false
+	}
+	size, err = counts.Int64(0)
+	return start, size, err == nil
+}
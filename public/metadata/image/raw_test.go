@@ -0,0 +1,84 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	goimage "image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd/ifdtest"
+)
+
+// testRaw builds a little endian raw file: a 160x120 IFD0 turned by orientation 6,
+// and two SubIFDs with a 64x48 JPEG preview and the 6000x4000 sensor image
+func testRaw(t *testing.T) []byte {
+	var preview bytes.Buffer
+	if err := jpeg.Encode(&preview, goimage.NewGray(goimage.Rect(0, 0, 64, 48)), nil); err != nil {
+		t.Fatal(err)
+	}
+	const ifd0, subOffsets, sub1, sub2, jpegAt = 8, 62, 70, 100, 142
+
+	var buf bytes.Buffer
+	ifdtest.WriteHeader(&buf, ifd0)
+	ifdtest.WriteIFD(&buf, []ifdtest.Entry{
+		{tagImageWidth, ifdtest.Long, 1, 160},
+		{tagImageLength, ifdtest.Long, 1, 120},
+		{tagOrientation, ifdtest.Short, 1, 6},
+		{tagSubIFDs, ifdtest.Long, 2, subOffsets},
+	})
+	ifdtest.Write(&buf, sub1, sub2)
+	ifdtest.WriteIFD(&buf, []ifdtest.Entry{
+		{tagJPEGInterchangeFormat, ifdtest.Long, 1, jpegAt},
+		{tagJPEGInterchangeFormatLength, ifdtest.Long, 1, uint32(preview.Len())},
+	})
+	ifdtest.WriteIFD(&buf, []ifdtest.Entry{
+		{tagImageWidth, ifdtest.Long, 1, 6000},
+		{tagImageLength, ifdtest.Long, 1, 4000},
+		{tagCompression, ifdtest.Short, 1, 1},
+	})
+	if buf.Len() != jpegAt {
+		t.Fatalf("expected the preview at %d got %d", jpegAt, buf.Len())
+	}
+	buf.Write(preview.Bytes())
+	return buf.Bytes()
+}
+
+func TestRaw(t *testing.T) {
+	b := testRaw(t)
+	if !ifd.IsTIFF(b) || ifd.IsTIFF([]byte("\xff\xd8\xff\xe1")) {
+		t.Error("expected only the raw file to be TIFF")
+	}
+	raw, err := DecodeRaw(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, h, err := raw.Dimensions(); w != 6000 || h != 4000 || err != nil {
+		t.Errorf("expected the sensor size 6000x4000 got %dx%d %v", w, h, err)
+	}
+	if o := raw.Orientation(); o != 6 {
+		t.Errorf("expected orientation 6 got %d", o)
+	}
+	preview, err := raw.Preview()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(preview))
+	if err != nil || cfg.Width != 64 || cfg.Height != 48 {
+		t.Errorf("expected a 64x48 preview got %+v %v", cfg, err)
+	}
+}
+
+func TestRawWithoutPreview(t *testing.T) {
+	b := testRaw(t)
+	// point the preview past the end of the file
+	binary.LittleEndian.PutUint32(b[70+2+8:], uint32(len(b)))
+	raw, err := DecodeRaw(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Preview(); err != ErrNoPreview {
+		t.Errorf("expected %v got %v", ErrNoPreview, err)
+	}
+}
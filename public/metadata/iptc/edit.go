@@ -0,0 +1,88 @@
+package iptc
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// Edit replaces the datasets of the IIM record in b with the values of set, an empty value removes the
+// dataset. The other datasets are kept as they are. New values are written as UTF-8, which Record reads
+// as such whatever character set the record declares.
+func Edit(b []byte, set map[Dataset]string) ([]byte, error) {
+	var out []byte
+	for len(b) > 0 && b[0] != 0 {
+		ds, _, rest, err := nextDataset(b)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := set[ds]; !ok {
+			out = append(out, b[:len(b)-len(rest)]...)
+		}
+		b = rest
+	}
+	// the new datasets are written in the same order whatever the map order
+	added := make([]Dataset, 0, len(set))
+	for ds, v := range set {
+		if v != "" {
+			added = append(added, ds)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i] < added[j] })
+	for _, ds := range added {
+		out = appendDataset(out, ds, set[ds])
+	}
+	return out, nil
+}
+
+func appendDataset(b []byte, ds Dataset, v string) []byte {
+	b = append(b, 0x1C, byte(ds>>8), byte(ds))
+	if len(v) <= 0x7fff {
+		b = append(b, byte(len(v)>>8), byte(len(v)))
+	} else {
+		// an extended dataset with a 4 byte length
+		b = append(b, 0x80, 4, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(v)))
+	}
+	return append(b, v...)
+}
+
+// ReplacePhotoshop replaces the IIM record in the Photoshop image resources b of an APP13 segment with iim.
+// The record is added when b has none and removed when iim is empty, the other resources are kept.
+func ReplacePhotoshop(b, iim []byte) ([]byte, error) {
+	var out []byte
+	written := len(iim) == 0
+	for len(b) >= 12 {
+		id, _, rest, err := nextResource(b)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case id != resourceIPTC:
+			out = append(out, b[:len(b)-len(rest)]...)
+			// restore the padding a last resource was written without
+			if len(out)%2 == 1 {
+				out = append(out, 0)
+			}
+		case !written:
+			out = appendResource(out, resourceIPTC, iim)
+			written = true
+		}
+		b = rest
+	}
+	if !written {
+		out = appendResource(out, resourceIPTC, iim)
+	}
+	return out, nil
+}
+
+// appendResource appends a Photoshop image resource without a name
+func appendResource(b []byte, id uint16, data []byte) []byte {
+	b = append(b, "8BIM"...)
+	b = append(b, byte(id>>8), byte(id), 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
@@ -0,0 +1,167 @@
+// Package iptc reads and edits IPTC-IIM records, the captions, bylines and keywords newsrooms file
+// photos with. JPEGs carry the record as resource 0x0404 of the Photoshop image resources
+// in APP13; TIFF and RAW files in tag 33723.
+package iptc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"unicode/utf8"
+)
+
+// Dataset is the record and dataset number of an IIM field, e.g. 2:80 for the byline
+type Dataset uint16
+
+// datasets of the application record
+const (
+	Keywords        Dataset = 2<<8 | 25
+	DateCreated     Dataset = 2<<8 | 55
+	TimeCreated     Dataset = 2<<8 | 60
+	Byline          Dataset = 2<<8 | 80
+	City            Dataset = 2<<8 | 90
+	Country         Dataset = 2<<8 | 101
+	JobID           Dataset = 2<<8 | 103
+	Credit          Dataset = 2<<8 | 110
+	CopyrightNotice Dataset = 2<<8 | 116
+	Caption         Dataset = 2<<8 | 120
+)
+
+// codedCharacterSet 1:90 declares the charset, ESC % G is UTF-8
+const codedCharacterSet Dataset = 1<<8 | 90
+
+var utf8Escape = []byte("\x1b%G")
+
+// resourceIPTC is the id of the IIM record in Photoshop image resources
+const resourceIPTC = 0x0404
+
+var (
+	ErrMalformed = errors.New("iptc: malformed record")
+	ErrNoIPTC    = errors.New("iptc: no iptc resource")
+)
+
+// Record holds the values of each dataset in the order they were written
+type Record struct {
+	values map[Dataset][][]byte
+	utf8   bool
+}
+
+// nextDataset splits the first dataset off the IIM record in b
+func nextDataset(b []byte) (ds Dataset, value, rest []byte, err error) {
+	if b[0] != 0x1C || len(b) < 5 {
+		return 0, nil, nil, ErrMalformed
+	}
+	ds = Dataset(b[1])<<8 | Dataset(b[2])
+	size := int(binary.BigEndian.Uint16(b[3:]))
+	b = b[5:]
+	// extended datasets hold the size of their length field in the lower 15 bits
+	if size&0x8000 != 0 {
+		n := size & 0x7fff
+		if n > 4 || n > len(b) {
+			return 0, nil, nil, ErrMalformed
+		}
+		size = 0
+		for _, c := range b[:n] {
+			size = size<<8 | int(c)
+		}
+		b = b[n:]
+	}
+	if size < 0 || size > len(b) {
+		return 0, nil, nil, ErrMalformed
+	}
+	return ds, b[:size], b[size:], nil
+}
+
+// Parse reads the datasets of an IIM record
+func Parse(b []byte) (*Record, error) {
+	rec := &Record{values: make(map[Dataset][][]byte)}
+	// a zero byte is trailing padding after the last dataset
+	for len(b) > 0 && b[0] != 0 {
+		ds, value, rest, err := nextDataset(b)
+		if err != nil {
+			return nil, err
+		}
+		rec.values[ds] = append(rec.values[ds], value)
+		b = rest
+	}
+	if cs := rec.values[codedCharacterSet]; len(cs) > 0 && bytes.Equal(cs[0], utf8Escape) {
+		rec.utf8 = true
+	}
+	return rec, nil
+}
+
+// nextResource splits the first resource off the Photoshop image resources in b, which must hold 12 bytes
+func nextResource(b []byte) (id uint16, data, rest []byte, err error) {
+	if string(b[:4]) != "8BIM" {
+		return 0, nil, nil, ErrMalformed
+	}
+	id = binary.BigEndian.Uint16(b[4:])
+	// the name is a pascal string padded to an even length
+	nameLen := int(b[6]) + 1
+	nameLen += nameLen % 2
+	if 6+nameLen+4 > len(b) {
+		return 0, nil, nil, ErrMalformed
+	}
+	b = b[6+nameLen:]
+	size := int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	if size < 0 || size > len(b) {
+		return 0, nil, nil, ErrMalformed
+	}
+	data = b[:size]
+	// the data is padded to an even length, writers leave the padding off the last resource
+	size += size % 2
+	if size > len(b) {
+		size = len(b)
+	}
+	return id, data, b[size:], nil
+}
+
+// FromPhotoshop returns the IIM record in the Photoshop image resources of an APP13 segment
+func FromPhotoshop(b []byte) ([]byte, error) {
+	for len(b) >= 12 {
+		id, data, rest, err := nextResource(b)
+		if err != nil {
+			return nil, err
+		}
+		if id == resourceIPTC {
+			return data, nil
+		}
+		b = rest
+	}
+	return nil, ErrNoIPTC
+}
+
+// decode reads a value as UTF-8 when the record says so or the value is valid UTF-8, as Latin-1 otherwise
+func (r *Record) decode(v []byte) string {
+	if r.utf8 || utf8.Valid(v) {
+		return string(bytes.TrimRight(v, "\x00 "))
+	}
+	runes := make([]rune, 0, len(v))
+	for _, c := range v {
+		runes = append(runes, rune(c))
+	}
+	return string(bytes.TrimRight([]byte(string(runes)), "\x00 "))
+}
+
+// Get returns the first value of ds
+func (r *Record) Get(ds Dataset) string {
+	if r == nil || len(r.values[ds]) == 0 {
+		return ""
+	}
+	return r.decode(r.values[ds][0])
+}
+
+// All returns every value of a repeatable dataset like Keywords
+func (r *Record) All(ds Dataset) []string {
+	if r == nil {
+		return nil
+	}
+	var out []string
+	for _, v := range r.values[ds] {
+		if s := r.decode(v); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,170 @@
+package iptc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func dataset(ds Dataset, v string) []byte {
+	b := []byte{0x1C, byte(ds >> 8), byte(ds), 0, 0}
+	binary.BigEndian.PutUint16(b[3:], uint16(len(v)))
+	return append(b, v...)
+}
+
+func TestParse(t *testing.T) {
+	rec, err := Parse(bytes.Join([][]byte{
+		dataset(codedCharacterSet, "\x1b%G"),
+		dataset(Byline, "Jane Doe"),
+		dataset(Keywords, "fire"),
+		dataset(Keywords, "harbour"),
+		dataset(City, "Århus"),
+		{0, 0},
+	}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Get(Byline); got != "Jane Doe" {
+		t.Errorf("expected %q got %q", "Jane Doe", got)
+	}
+	if got := rec.Get(City); got != "Århus" {
+		t.Errorf("expected %q got %q", "Århus", got)
+	}
+	if got, want := rec.All(Keywords), []string{"fire", "harbour"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q got %q", want, got)
+	}
+	if got := rec.Get(Caption); got != "" {
+		t.Errorf("expected empty got %q", got)
+	}
+}
+
+func TestParseLatin1(t *testing.T) {
+	rec, err := Parse(dataset(City, "\xc5rhus"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Get(City); got != "Århus" {
+		t.Errorf("expected %q got %q", "Århus", got)
+	}
+}
+
+func TestParseExtended(t *testing.T) {
+	caption := string(bytes.Repeat([]byte("a"), 40000))
+	b := append([]byte{0x1C, 2, 120, 0x80, 4, 0, 0, 0x9C, 0x40}, caption...)
+	rec, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Get(Caption); got != caption {
+		t.Errorf("expected %d bytes got %d", len(caption), len(got))
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		{0x1C, 2, 80},
+		{0x1C, 2, 80, 0, 10, 'a'},
+		{0x1D, 2, 80, 0, 0},
+	} {
+		if _, err := Parse(b); err != ErrMalformed {
+			t.Errorf("%x: expected %v got %v", b, ErrMalformed, err)
+		}
+	}
+}
+
+func resource(id uint16, name string, data []byte) []byte {
+	b := append([]byte("8BIM"), byte(id>>8), byte(id), byte(len(name)))
+	b = append(b, name...)
+	if len(b)%2 == 1 {
+		b = append(b, 0)
+	}
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestFromPhotoshop(t *testing.T) {
+	record := dataset(Byline, "Jane Doe")
+	irb := append(resource(0x040C, "thumb", []byte("jpeg")), resource(resourceIPTC, "", record)...)
+	got, err := FromPhotoshop(irb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, record) {
+		t.Errorf("expected %x got %x", record, got)
+	}
+	if _, err := FromPhotoshop(resource(0x040C, "", []byte("jpeg"))); err != ErrNoIPTC {
+		t.Errorf("expected %v got %v", ErrNoIPTC, err)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	b := bytes.Join([][]byte{
+		dataset(codedCharacterSet, "\x1b%G"),
+		dataset(Byline, "Jane Doe"),
+		dataset(Byline, "John Doe"),
+		dataset(CopyrightNotice, "old"),
+		dataset(City, "Århus"),
+		{0, 0},
+	}, nil)
+	got, err := Edit(b, map[Dataset]string{Byline: "Byrd", CopyrightNotice: "", JobID: "booking-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all := rec.All(Byline); !reflect.DeepEqual(all, []string{"Byrd"}) {
+		t.Errorf("expected the bylines replaced got %q", all)
+	}
+	for ds, want := range map[Dataset]string{CopyrightNotice: "", JobID: "booking-1", City: "Århus"} {
+		if v := rec.Get(ds); v != want {
+			t.Errorf("%d: expected %q got %q", ds, want, v)
+		}
+	}
+	long := string(bytes.Repeat([]byte("a"), 40000))
+	if got, err := Edit(nil, map[Dataset]string{Caption: long}); err != nil {
+		t.Error(err)
+	} else if rec, err := Parse(got); err != nil || rec.Get(Caption) != long {
+		t.Errorf("expected the extended caption back got %v", err)
+	}
+}
+
+func TestReplacePhotoshop(t *testing.T) {
+	thumb := resource(0x040C, "thumb", []byte("jpg"))
+	record := dataset(Byline, "Jane Doe")
+	tests := []struct {
+		name    string
+		irb     []byte
+		iim     []byte
+		want    []byte
+		present bool
+	}{
+		{"replaced", append(resource(resourceIPTC, "", dataset(Byline, "old")), thumb...), record, record, true},
+		// the last resource may miss its padding
+		{"added", thumb[:len(thumb)-1], record, record, true},
+		{"removed", append(append([]byte(nil), thumb...), resource(resourceIPTC, "", record)...), nil, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := ReplacePhotoshop(tt.irb, tt.iim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iim, err := FromPhotoshop(got)
+		if tt.present && (err != nil || !bytes.Equal(iim, tt.want)) {
+			t.Errorf("%s: expected %x got %x %v", tt.name, tt.want, iim, err)
+		}
+		if !tt.present && err != ErrNoIPTC {
+			t.Errorf("%s: expected %v got %v", tt.name, ErrNoIPTC, err)
+		}
+		if !bytes.Contains(got, thumb) {
+			t.Errorf("%s: expected the other resources kept", tt.name)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package metadata
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/byrdapp/byrd-pro-api/public/conversion"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/heif"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/image"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/iptc"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/segments"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/xmp"
+)
+
+// News are the fields newsrooms file photos with. Following the guidance of the Metadata
+// Working Group, each field is taken from the first source that has it, in the order:
+//
+//  1. an XMP sidecar, see MergeSidecar
+//  2. the XMP packet embedded in the image
+//  3. the IPTC-IIM record embedded in the image
+//  4. EXIF, which only has a copyright, artist (byline) and image description (caption)
+//
+// Keywords are the union of the XMP and IPTC keywords, in that order.
+// The capture date keeps coming from EXIF first, which the camera writes; the XMP and
+// IPTC creation dates are only used for images without it.
+type News struct {
+	Copyright string   `json:"copyright,omitempty"`
+	Byline    string   `json:"byline,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+	Credit    string   `json:"credit,omitempty"`
+	City      string   `json:"city,omitempty"`
+	Country   string   `json:"country,omitempty"`
+	Keywords  []string `json:"keywords,omitempty"`
+}
+
+// fill sets the empty fields of n from o and appends the keywords of o that n lacks
+func (n *News) fill(o News) {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&n.Copyright, o.Copyright},
+		{&n.Byline, o.Byline},
+		{&n.Caption, o.Caption},
+		{&n.Credit, o.Credit},
+		{&n.City, o.City},
+		{&n.Country, o.Country},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+	seen := make(map[string]bool, len(n.Keywords))
+	for _, k := range n.Keywords {
+		seen[strings.ToLower(k)] = true
+	}
+	for _, k := range o.Keywords {
+		if !seen[strings.ToLower(k)] {
+			seen[strings.ToLower(k)] = true
+			n.Keywords = append(n.Keywords, k)
+		}
+	}
+}
+
+func newsFromXMP(x *xmp.Packet) News {
+	return News{
+		Copyright: x.Get(xmp.NamespaceDC, "rights"),
+		Byline:    strings.Join(x.All(xmp.NamespaceDC, "creator"), ", "),
+		Caption:   x.Get(xmp.NamespaceDC, "description"),
+		Credit:    x.Get(xmp.NamespacePhotoshop, "Credit"),
+		City:      x.Get(xmp.NamespacePhotoshop, "City"),
+		Country:   x.Get(xmp.NamespacePhotoshop, "Country"),
+		Keywords:  x.All(xmp.NamespaceDC, "subject"),
+	}
+}
+
+func newsFromIPTC(r *iptc.Record) News {
+	return News{
+		Copyright: r.Get(iptc.CopyrightNotice),
+		Byline:    strings.Join(r.All(iptc.Byline), ", "),
+		Caption:   r.Get(iptc.Caption),
+		Credit:    r.Get(iptc.Credit),
+		City:      r.Get(iptc.City),
+		Country:   r.Get(iptc.Country),
+		Keywords:  r.All(iptc.Keywords),
+	}
+}
+
+// exifNews are the exif tags that fill News
+type exifNews struct {
+	copyright, artist, description string
+}
+
+// sources are the metadata blocks found in an image
+type sources struct {
+	// exif is read by goexif, which finds the exif of JPEG and TIFF files itself
+	exif []byte
+	xmp  *xmp.Packet
+	iptc *iptc.Record
+	heif *heif.File
+	raw  *image.Raw
+}
+
+// readSources finds the exif, XMP and IPTC of a JPEG, HEIF or TIFF based RAW image.
+// Broken XMP and IPTC blocks are skipped, they must not hide the exif of an image.
+func readSources(b []byte) (*sources, error) {
+	src := &sources{exif: b}
+	var xmpPacket, iptcRecord []byte
+	switch {
+	case heif.IsHEIF(b):
+		hf, err := heif.Parse(b)
+		if err != nil {
+			return nil, errHEIF
+		}
+		src.heif = hf
+		src.exif, _ = hf.Exif()
+		xmpPacket, _ = hf.XMP()
+	case ifd.IsTIFF(b):
+		raw, err := image.DecodeRaw(b)
+		if err != nil {
+			return nil, errRaw
+		}
+		src.raw = raw
+		xmpPacket, _ = raw.XMP()
+		iptcRecord, _ = raw.IPTC()
+	case segments.IsJPEG(b):
+		segs, err := segments.Read(bytes.NewReader(b))
+		if err != nil {
+			break
+		}
+		if packets := segments.Find(segs, segments.APP1, segments.XMPHeader); len(packets) > 0 {
+			xmpPacket = packets[0]
+		}
+		// Photoshop resources may be split over several APP13 segments
+		if irb := segments.Find(segs, segments.APP13, segments.PhotoshopHeader); len(irb) > 0 {
+			iptcRecord, _ = iptc.FromPhotoshop(bytes.Join(irb, nil))
+		}
+	}
+	if len(xmpPacket) > 0 {
+		src.xmp, _ = xmp.Parse(xmpPacket)
+	}
+	if len(iptcRecord) > 0 {
+		src.iptc, _ = iptc.Parse(iptcRecord)
+	}
+	return src, nil
+}
+
+// merge fills News from the embedded XMP, then IPTC, then exif
+func merge(src *sources, ex exifNews) News {
+	var n News
+	if src.xmp != nil {
+		n.fill(newsFromXMP(src.xmp))
+	}
+	if src.iptc != nil {
+		n.fill(newsFromIPTC(src.iptc))
+	}
+	n.fill(News{Copyright: ex.copyright, Byline: ex.artist, Caption: ex.description})
+	return n
+}
+
+// newsDate returns the creation date of the XMP or IPTC as unix millis, for images without an exif date
+func newsDate(src *sources) int64 {
+	if t, ok := xmpDate(src.xmp); ok {
+		return conversion.UnixNanoToMillis(t)
+	}
+	if src.iptc != nil {
+		// CCYYMMDD and HHMMSS±HHMM
+		date, clock := src.iptc.Get(iptc.DateCreated), src.iptc.Get(iptc.TimeCreated)
+		if t, err := time.Parse("20060102150405-0700", date+clock); err == nil {
+			return conversion.UnixNanoToMillis(t)
+		}
+		if t, err := time.Parse("20060102", date); err == nil {
+			return conversion.UnixNanoToMillis(t)
+		}
+	}
+	return 0
+}
+
+func xmpDate(x *xmp.Packet) (time.Time, bool) {
+	for _, v := range []string{x.Get(xmp.NamespaceExif, "DateTimeOriginal"), x.Get(xmp.NamespacePhotoshop, "DateCreated")} {
+		if v == "" {
+			continue
+		}
+		if t, err := xmp.ParseDate(v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// MergeSidecar merges an XMP sidecar, which editing tools like Lightroom write next to RAW files.
+// The sidecar takes precedence over the metadata embedded in the image.
+func (m *Metadata) MergeSidecar(b []byte) error {
+	x, err := xmp.Parse(b)
+	if err != nil {
+		return err
+	}
+	n := newsFromXMP(x)
+	n.fill(m.News)
+	m.News = n
+	if t, ok := xmpDate(x); ok && m.Date == 0 {
+		m.Date = conversion.UnixNanoToMillis(t)
+	}
+	m.checkNews()
+	return nil
+}
+
+// checkNews lists the date and copyright in NilKeys when no source had them
+func (m *Metadata) checkNews() {
+	keys := m.NilKeys[:0]
+	for _, k := range m.NilKeys {
+		if k != "date" && k != "copyright" {
+			keys = append(keys, k)
+		}
+	}
+	if m.Date == 0 {
+		keys = append(keys, "date")
+	}
+	if m.Copyright == "" {
+		keys = append(keys, "copyright")
+	}
+	m.NilKeys = keys
+}
@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/iptc"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/segments"
+)
+
+func segment(marker byte, header []byte, data []byte) []byte {
+	n := len(header) + len(data) + 2
+	return append(append([]byte{0xFF, marker, byte(n >> 8), byte(n)}, header...), data...)
+}
+
+func xmpPacket(props string) []byte {
+	return []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/">` +
+		props + `</rdf:Description></rdf:RDF></x:xmpmeta>`)
+}
+
+func iptcDataset(ds iptc.Dataset, v string) []byte {
+	return append([]byte{0x1C, byte(ds >> 8), byte(ds), byte(len(v) >> 8), byte(len(v))}, v...)
+}
+
+// testJPEG is a jpeg without exif, with an XMP packet and an IPTC record in Photoshop resources
+func testJPEG() []byte {
+	record := bytes.Join([][]byte{
+		iptcDataset(iptc.Byline, "IPTC Byline"),
+		iptcDataset(iptc.City, "Aarhus"),
+		iptcDataset(iptc.Keywords, "harbour"),
+		iptcDataset(iptc.Keywords, "Fire"),
+		iptcDataset(iptc.DateCreated, "20200504"),
+	}, nil)
+	irb := append([]byte("8BIM\x04\x04\x00\x00"), byte(len(record)>>24), byte(len(record)>>16), byte(len(record)>>8), byte(len(record)))
+	irb = append(irb, record...)
+	packet := xmpPacket(`<dc:creator><rdf:Seq><rdf:li>XMP Creator</rdf:li></rdf:Seq></dc:creator>
+<dc:subject><rdf:Bag><rdf:li>fire</rdf:li></rdf:Bag></dc:subject>`)
+	return bytes.Join([][]byte{
+		{0xFF, segments.SOI},
+		segment(segments.APP1, segments.XMPHeader, packet),
+		segment(segments.APP13, segments.PhotoshopHeader, irb),
+		segment(segments.SOS, nil, []byte{1, 2, 3}),
+		{0xFF, segments.EOI},
+	}, nil)
+}
+
+func TestDecodeImageNews(t *testing.T) {
+	m, err := DecodeImage(bytes.NewReader(testJPEG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := News{Byline: "XMP Creator", City: "Aarhus", Keywords: []string{"fire", "harbour"}}
+	if !reflect.DeepEqual(m.News, want) {
+		t.Errorf("expected %+v got %+v", want, m.News)
+	}
+	// 2020-05-04 UTC
+	if m.Date != 1588550400000 {
+		t.Errorf("expected %v got %v", 1588550400000, m.Date)
+	}
+	if want := []string{"geo", "model", "lens", "dimension", "copyright"}; !reflect.DeepEqual(m.NilKeys, want) {
+		t.Errorf("expected %v got %v", want, m.NilKeys)
+	}
+}
+
+func TestMergeSidecar(t *testing.T) {
+	m, err := DecodeImage(bytes.NewReader(testJPEG()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecar := xmpPacket(`<dc:creator><rdf:Seq><rdf:li>Sidecar Creator</rdf:li></rdf:Seq></dc:creator>
+<dc:rights><rdf:Alt><rdf:li xml:lang="x-default">© Byrd</rdf:li></rdf:Alt></dc:rights>
+<dc:subject><rdf:Bag><rdf:li>police</rdf:li></rdf:Bag></dc:subject>`)
+	if err := m.MergeSidecar(sidecar); err != nil {
+		t.Fatal(err)
+	}
+	want := News{Copyright: "© Byrd", Byline: "Sidecar Creator", City: "Aarhus", Keywords: []string{"police", "fire", "harbour"}}
+	if !reflect.DeepEqual(m.News, want) {
+		t.Errorf("expected %+v got %+v", want, m.News)
+	}
+	if want := []string{"geo", "model", "lens", "dimension"}; !reflect.DeepEqual(m.NilKeys, want) {
+		t.Errorf("expected %v got %v", want, m.NilKeys)
+	}
+	if err := m.MergeSidecar([]byte("<x:xmpmeta")); err == nil {
+		t.Error("expected an error")
+	}
+}
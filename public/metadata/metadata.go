@@ -1,8 +1,10 @@
 package metadata
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"io/ioutil"
 
 	"github.com/byrdapp/byrd-pro-api/public/conversion"
 	"github.com/byrdapp/byrd-pro-api/public/metadata/image"
@@ -11,6 +13,8 @@ import (
 
 var (
 	EOFError = errors.New("error reading exif from file")
+	errHEIF  = errors.New("error decoding heif image for meta data")
+	errRaw   = errors.New("error decoding raw image for meta data")
 )
 
 // Output represents the final decoded EXIF data from an image
@@ -19,14 +23,20 @@ type Metadata struct {
 	Date      int64    `json:"date,omitempty"`
 	Lat       float64  `json:"lat,omitempty"`
 	Lng       float64  `json:"lng,omitempty"`
-	Copyright string   `json:"copyright,omitempty"`
 	Model     string   `json:"model,omitempty"`
+	Lens      string   `json:"lens,omitempty"`
 	Height    int      `json:"height,omitempty"`
 	Width     int      `json:"width,omitempty"`
 	MediaSize float64  `json:"mediaSize,omitempty"`
 	NilKeys   []string `json:"missingExif,omitempty"`
 	// MissingExif map[string]string `json:"missingExif,omitempty"`
 	// MediaFormat     string  `json:"mediaFormat,omitempty"`
+
+	// Exif is the full dump of the exif tags, only set on request, see DecodeImageFull
+	Exif *image.Dump `json:"exif,omitempty"`
+
+	// News holds the copyright, byline, caption and more merged from XMP, IPTC and EXIF
+	News
 }
 
 func DecodeVideo(r io.Reader) (*Metadata, error) {
@@ -66,47 +76,88 @@ func DecodeVideo(r io.Reader) (*Metadata, error) {
 // DecodeImageMetadata returns the struct *Output containing img data.
 // This will include the errors from missing/broken exif will follow.
 // If an error is != nil, its a panic
+// The image is read whole to find its exif, XMP and IPTC, which are merged as documented in merge.go.
 func DecodeImage(r io.Reader) (*Metadata, error) {
-	// r := bytes.NewReader(data)
-	// xErr := &metadata.Metadata{MissingExif: make(map[string]string)}
+	return decodeImage(r, false)
+}
+
+// DecodeImageFull is DecodeImage with every exif tag of the image in Exif
+func DecodeImageFull(r io.Reader) (*Metadata, error) {
+	return decodeImage(r, true)
+}
+
+func decodeImage(r io.Reader, full bool) (*Metadata, error) {
 	var nilKeys []string
-	m, err := image.ImageMetadata(r)
+	b, err := ioutil.ReadAll(r)
 	if err != nil {
+		return nil, EOFError
+	}
+	src, err := readSources(b)
+	if err != nil {
+		return nil, err
+	}
+	m, err := image.ImageMetadata(bytes.NewReader(src.exif))
+	if err != nil && src.xmp == nil && src.iptc == nil {
 		if err == io.ErrUnexpectedEOF || err == io.EOF {
 			return nil, EOFError
 		}
 		// Missing exif should probably not happen
 		return nil, errors.New("error decoding image for meta data")
 	}
-	lat, lng, err := m.Geo()
 	if err != nil {
-		nilKeys = append(nilKeys, "geo")
+		// files of agencies often carry only XMP or IPTC
+		nilKeys = append(nilKeys, "geo", "model", "lens", "dimension")
+		meta := &Metadata{News: merge(src, exifNews{}), NilKeys: nilKeys}
+		meta.Date = newsDate(src)
+		meta.checkNews()
+		return meta, nil
 	}
-	date, err := m.DateMillisUnix()
+	lat, lng, err := m.Geo()
 	if err != nil {
-		nilKeys = append(nilKeys, "date")
+		nilKeys = append(nilKeys, "geo")
 	}
-	copyright, err := m.Copyright()
-	if err != nil {
-		nilKeys = append(nilKeys, "copyright")
+	date, _ := m.DateMillisUnix()
+	if date == 0 {
+		date = newsDate(src)
 	}
+	var ex exifNews
+	ex.copyright, _ = m.Copyright()
+	ex.artist, _ = m.Artist()
+	ex.description, _ = m.Description()
 	model, err := m.Model()
 	if err != nil {
 		nilKeys = append(nilKeys, "model")
 	}
+	lens, err := m.Lens()
+	if err != nil {
+		nilKeys = append(nilKeys, "lens")
+	}
 	w, h, err := m.Dimensions()
+	// the exif of heif and raw images may leave out the size of the image
+	if err != nil && src.heif != nil {
+		w, h, err = src.heif.Dimensions()
+	}
+	if err != nil && src.raw != nil {
+		w, h, err = src.raw.Dimensions()
+	}
 	if err != nil {
 		nilKeys = append(nilKeys, "dimension")
 	}
 
-	return &Metadata{
-		Lat:       lat,
-		Lng:       lng,
-		Date:      date,
-		Model:     model,
-		Width:     w,
-		Height:    h,
-		Copyright: copyright,
-		NilKeys:   nilKeys,
-	}, nil
+	meta := &Metadata{
+		Lat:     lat,
+		Lng:     lng,
+		Date:    date,
+		News:    merge(src, ex),
+		Model:   model,
+		Lens:    lens,
+		Width:   w,
+		Height:  h,
+		NilKeys: nilKeys,
+	}
+	if full {
+		meta.Exif = m.Dump()
+	}
+	meta.checkNews()
+	return meta, nil
 }
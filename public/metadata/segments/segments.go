@@ -0,0 +1,138 @@
+// Package segments reads the marker segments of JPEG files, where the metadata of a JPEG lives:
+// EXIF and XMP in APP1 segments and IPTC in the Photoshop resources of APP13.
+package segments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// JPEG markers
+const (
+	SOI   = 0xD8
+	EOI   = 0xD9
+	SOS   = 0xDA
+	APP1  = 0xE1
+	APP13 = 0xED
+)
+
+// Identifiers at the start of the metadata segments
+var (
+	ExifHeader      = []byte("Exif\x00\x00")
+	XMPHeader       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	PhotoshopHeader = []byte("Photoshop 3.0\x00")
+)
+
+var (
+	ErrNotJPEG   = errors.New("segments: not a jpeg file")
+	ErrMalformed = errors.New("segments: malformed segment")
+)
+
+// Segment is a marker segment, Data excludes the marker and length
+type Segment struct {
+	Marker byte
+	Data   []byte
+}
+
+// IsJPEG reports whether header starts a JPEG file
+func IsJPEG(header []byte) bool {
+	return len(header) >= 3 && header[0] == 0xFF && header[1] == SOI && header[2] == 0xFF
+}
+
+// Read reads the segments of a JPEG file up to the start of the image scan, which follows all metadata
+func Read(r io.Reader) ([]Segment, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != SOI {
+		return nil, ErrNotJPEG
+	}
+	var segments []Segment
+	var b [1]byte
+	for {
+		// markers may be padded with any number of 0xFF
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, ErrMalformed
+		}
+		if b[0] != 0xFF {
+			return nil, ErrMalformed
+		}
+		for b[0] == 0xFF {
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return nil, ErrMalformed
+			}
+		}
+		marker := b[0]
+		if marker == EOI {
+			return segments, nil
+		}
+		// TEM and RSTn stand alone
+		if marker == 0x01 || marker >= 0xD0 && marker <= 0xD7 {
+			continue
+		}
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil || length < 2 {
+			return nil, ErrMalformed
+		}
+		data := make([]byte, length-2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, ErrMalformed
+		}
+		segments = append(segments, Segment{Marker: marker, Data: data})
+		if marker == SOS {
+			return segments, nil
+		}
+	}
+}
+
+// Find returns the data of the segments with marker whose data starts with header, header removed
+func Find(segments []Segment, marker byte, header []byte) [][]byte {
+	var found [][]byte
+	for _, s := range segments {
+		if s.Marker == marker && bytes.HasPrefix(s.Data, header) {
+			found = append(found, s.Data[len(header):])
+		}
+	}
+	return found
+}
+
+// MaxData is the largest Data of a segment, its length field counts itself
+const MaxData = 0xFFFF - 2
+
+var ErrTooLarge = errors.New("segments: segment data too large")
+
+// Split splits a JPEG file into the segments before the image scan and the scan, from its SOS marker
+// to the end of the file. Writing them back with Join leaves the image data byte for byte as it was.
+func Split(b []byte) ([]Segment, []byte, error) {
+	r := bytes.NewReader(b)
+	segments, err := Read(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(segments) == 0 || segments[len(segments)-1].Marker != SOS {
+		return nil, nil, ErrMalformed
+	}
+	sos := segments[len(segments)-1]
+	// the scan starts at the marker and length of the SOS segment
+	start := len(b) - r.Len() - len(sos.Data) - 4
+	return segments[:len(segments)-1], b[start:], nil
+}
+
+// Join writes a JPEG file of the segments followed by the scan returned by Split
+func Join(segments []Segment, scan []byte) ([]byte, error) {
+	size := 2 + len(scan)
+	for _, s := range segments {
+		if len(s.Data) > MaxData {
+			return nil, ErrTooLarge
+		}
+		size += 4 + len(s.Data)
+	}
+	b := make([]byte, 0, size)
+	b = append(b, 0xFF, SOI)
+	for _, s := range segments {
+		b = append(b, 0xFF, s.Marker, 0, 0)
+		binary.BigEndian.PutUint16(b[len(b)-2:], uint16(len(s.Data)+2))
+		b = append(b, s.Data...)
+	}
+	return append(b, scan...), nil
+}
@@ -0,0 +1,86 @@
+package segments
+
+import (
+	"bytes"
+	"testing"
+)
+
+func segment(marker byte, data []byte) []byte {
+	n := len(data) + 2
+	return append([]byte{0xFF, marker, byte(n >> 8), byte(n)}, data...)
+}
+
+func TestRead(t *testing.T) {
+	xmp := append(append([]byte{}, XMPHeader...), "<x:xmpmeta/>"...)
+	jpeg := bytes.Join([][]byte{
+		{0xFF, SOI},
+		segment(0xE0, []byte("JFIF\x00")),
+		segment(APP1, append(append([]byte{}, ExifHeader...), "MM\x00*"...)),
+		// padded marker
+		{0xFF},
+		segment(APP1, xmp),
+		segment(SOS, []byte{1, 2, 3}),
+		{0xFF, 0x00, 0xFF, EOI},
+	}, nil)
+	if !IsJPEG(jpeg) {
+		t.Fatal("expected a jpeg")
+	}
+	segs, err := Read(bytes.NewReader(jpeg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 4 || segs[3].Marker != SOS {
+		t.Fatalf("expected 4 segments ending with SOS got %v", segs)
+	}
+	found := Find(segs, APP1, XMPHeader)
+	if len(found) != 1 || string(found[0]) != "<x:xmpmeta/>" {
+		t.Errorf("expected the xmp packet got %q", found)
+	}
+	if found := Find(segs, APP13, PhotoshopHeader); len(found) != 0 {
+		t.Errorf("expected no photoshop segments got %q", found)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want error
+	}{
+		{[]byte("\x89PNG"), ErrNotJPEG},
+		{[]byte{0xFF, SOI, 0xFF, APP1, 0x00, 0x10, 'E'}, ErrMalformed},
+		{[]byte{0xFF, SOI, 0xFF, APP1, 0x00, 0x01}, ErrMalformed},
+		{[]byte{0xFF, SOI, 0x00}, ErrMalformed},
+	}
+	for i, test := range tests {
+		if _, err := Read(bytes.NewReader(test.b)); err != test.want {
+			t.Errorf("%d: expected %v got %v", i, test.want, err)
+		}
+	}
+}
+
+func TestSplitJoin(t *testing.T) {
+	scan := append(segment(SOS, []byte{1, 2, 3}), 0xFF, 0x00, 0x42, 0xFF, EOI)
+	jpeg := bytes.Join([][]byte{
+		{0xFF, SOI},
+		segment(0xE0, []byte("JFIF\x00")),
+		segment(APP1, append(append([]byte{}, ExifHeader...), "MM\x00*"...)),
+		scan,
+	}, nil)
+	segs, gotScan, err := Split(jpeg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 2 || !bytes.Equal(gotScan, scan) {
+		t.Fatalf("expected 2 segments and the scan got %v %x", segs, gotScan)
+	}
+	b, err := Join(segs, gotScan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, jpeg) {
+		t.Errorf("expected %x got %x", jpeg, b)
+	}
+	if _, err := Join([]Segment{{APP1, make([]byte, MaxData+1)}}, scan); err != ErrTooLarge {
+		t.Errorf("expected %v got %v", ErrTooLarge, err)
+	}
+}
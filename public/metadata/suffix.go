@@ -6,7 +6,7 @@ import (
 
 var (
 	VideoFormatSuffix = []string{"mp4", "mov", "quicktime", "x-m4v", "m4v"}
-	ImageFormatSuffix = []string{"jpg", "jpeg", "png"}
+	ImageFormatSuffix = []string{"jpg", "jpeg", "png", "heic", "heif", "dng", "cr2", "nef", "arw"}
 )
 
 func SupportedVideoSuffix(fileName string) bool {
@@ -0,0 +1,235 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Kind is how the value of a property is written
+type Kind int
+
+const (
+	// Simple is a text property, e.g. photoshop:Credit
+	Simple Kind = iota
+	// LangAlt is a language alternative of which the value is the x-default item, e.g. dc:rights
+	LangAlt
+	// Seq is an ordered array of which the value is the only item, e.g. dc:creator
+	Seq
+)
+
+// Property is a property for Edit to write
+type Property struct {
+	NS, Name, Value string
+	Kind            Kind
+}
+
+// prefixes of the namespaces of written properties, others are written as ns
+var prefixes = map[string]string{
+	NamespaceDC:        "dc",
+	NamespacePhotoshop: "photoshop",
+	NamespaceExif:      "exif",
+	NamespaceXMP:       "xmp",
+}
+
+const namespaceXML = "http://www.w3.org/XML/1998/namespace"
+
+// emptyPacket is edited when there is no packet yet
+const emptyPacket = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="">
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+// attrPattern matches a prefixed attribute of a start tag
+var attrPattern = regexp.MustCompile(`\s+([A-Za-z_][\w.-]*):([A-Za-z_][\w.-]*)\s*=\s*("[^"]*"|'[^']*')`)
+
+// splice replaces b[start:end] with text
+type splice struct {
+	start, end int
+	text       string
+}
+
+// Edit returns the packet b with props written to its first rdf:Description, and the existing values of props and
+// the properties drop returns true for removed from all descriptions. Properties of an empty value are only removed.
+// The rest of the packet is kept byte for byte; an empty b starts a new packet.
+func Edit(b []byte, props []Property, drop func(ns, name string) bool) ([]byte, error) {
+	b = bytes.TrimRight(b, "\x00")
+	if len(bytes.TrimSpace(b)) == 0 {
+		b = []byte(emptyPacket)
+	}
+	set := make(map[string]bool, len(props))
+	var insert strings.Builder
+	for _, p := range props {
+		set[key(p.NS, p.Name)] = true
+		if p.Value != "" {
+			insert.WriteString("\n   ")
+			insert.WriteString(p.xml())
+		}
+	}
+	remove := func(ns, name string) bool {
+		return set[key(ns, name)] || drop != nil && drop(ns, name)
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	var splices []splice
+	// scopes are the prefixes bound by each open element
+	var scopes []map[string]string
+	inserted := false
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		if _, ok := tok.(xml.EndElement); ok {
+			scopes = scopes[:len(scopes)-1]
+			continue
+		}
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		scopes = append(scopes, bindings(t))
+		if t.Name.Space != NamespaceRDF || t.Name.Local != "Description" {
+			continue
+		}
+		end := int(dec.InputOffset())
+		tag := removeAttrs(string(b[start:end]), scopes, remove)
+		if !inserted {
+			tag = insertInto(tag, insert.String())
+			inserted = true
+		}
+		splices = append(splices, splice{start, end, tag})
+
+		description, err := removeProperties(dec, b, remove)
+		if err != nil {
+			return nil, err
+		}
+		splices = append(splices, description...)
+		scopes = scopes[:len(scopes)-1]
+	}
+	if !inserted && insert.Len() > 0 {
+		return nil, ErrMalformed
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, s := range splices {
+		out.Write(b[last:s.start])
+		out.WriteString(s.text)
+		last = s.end
+	}
+	out.Write(b[last:])
+	return out.Bytes(), nil
+}
+
+func bindings(t xml.StartElement) map[string]string {
+	scope := make(map[string]string)
+	for _, attr := range t.Attr {
+		if attr.Name.Space == "xmlns" {
+			scope[attr.Name.Local] = attr.Value
+		}
+	}
+	return scope
+}
+
+func lookup(scopes []map[string]string, prefix string) string {
+	if prefix == "xml" {
+		return namespaceXML
+	}
+	for i := len(scopes) - 1; i >= 0; i-- {
+		if ns, ok := scopes[i][prefix]; ok {
+			return ns
+		}
+	}
+	return prefix
+}
+
+// removeAttrs removes the properties written as attributes of the start tag
+func removeAttrs(tag string, scopes []map[string]string, remove func(ns, name string) bool) string {
+	return attrPattern.ReplaceAllStringFunc(tag, func(attr string) string {
+		m := attrPattern.FindStringSubmatch(attr)
+		if m[1] == "xmlns" || !remove(lookup(scopes, m[1]), m[2]) {
+			return attr
+		}
+		return ""
+	})
+}
+
+// insertInto adds the property elements to the start tag of a description, opening it when it is self-closing
+func insertInto(tag, elements string) string {
+	if elements == "" {
+		return tag
+	}
+	if !strings.HasSuffix(tag, "/>") {
+		return tag + elements
+	}
+	name := tag[1:strings.IndexAny(tag, " \t\r\n/>")]
+	return strings.TrimRight(strings.TrimSuffix(tag, "/>"), " \t\r\n") + ">" + elements + "\n  </" + name + ">"
+}
+
+// removeProperties reads the property elements of a description up to its end and
+// returns the splices that remove those to remove, with the indentation before them
+func removeProperties(dec *xml.Decoder, b []byte, remove func(ns, name string) bool) ([]splice, error) {
+	var splices []splice
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			return splices, nil
+		case xml.StartElement:
+			if err := dec.Skip(); err != nil {
+				return nil, ErrMalformed
+			}
+			if !remove(t.Name.Space, t.Name.Local) {
+				continue
+			}
+			for start > 0 && (b[start-1] == ' ' || b[start-1] == '\t') {
+				start--
+			}
+			if start > 0 && b[start-1] == '\n' {
+				start--
+			}
+			splices = append(splices, splice{start, int(dec.InputOffset()), ""})
+		}
+	}
+}
+
+// xml returns the property element, it declares the namespaces it uses
+func (p Property) xml() string {
+	prefix := prefixes[p.NS]
+	if prefix == "" {
+		prefix = "ns"
+	}
+	name := prefix + ":" + p.Name
+	var value bytes.Buffer
+	_ = xml.EscapeText(&value, []byte(p.Value))
+	var ns bytes.Buffer
+	_ = xml.EscapeText(&ns, []byte(p.NS))
+
+	var s strings.Builder
+	s.WriteString("<" + name + ` xmlns:` + prefix + `="` + ns.String() + `"`)
+	switch p.Kind {
+	case LangAlt:
+		s.WriteString(` xmlns:rdf="` + NamespaceRDF + `"><rdf:Alt><rdf:li xml:lang="x-default">` + value.String() + `</rdf:li></rdf:Alt>`)
+	case Seq:
+		s.WriteString(` xmlns:rdf="` + NamespaceRDF + `"><rdf:Seq><rdf:li>` + value.String() + `</rdf:li></rdf:Seq>`)
+	default:
+		s.WriteString(">" + value.String())
+	}
+	s.WriteString("</" + name + ">")
+	return s.String()
+}
@@ -0,0 +1,84 @@
+package xmp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const lightroom = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    exif:GPSLatitude="56,9.0N"
+    photoshop:City="Aarhus">
+   <dc:creator>
+    <rdf:Seq>
+     <rdf:li>Old Creator</rdf:li>
+    </rdf:Seq>
+   </dc:creator>
+   <exif:GPSLongitude>10,12.0E</exif:GPSLongitude>
+   <dc:subject><rdf:Bag><rdf:li>fire</rdf:li></rdf:Bag></dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+func TestEdit(t *testing.T) {
+	b, err := Edit([]byte(lightroom), []Property{
+		{NS: NamespaceDC, Name: "creator", Value: "Jane & Co", Kind: Seq},
+		{NS: NamespaceDC, Name: "rights", Value: "© Byrd", Kind: LangAlt},
+		{NS: NamespacePhotoshop, Name: "TransmissionReference", Value: "booking-42"},
+	}, func(ns, name string) bool {
+		return ns == NamespaceExif && strings.HasPrefix(name, "GPS")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "GPS") || strings.Contains(string(b), "Old Creator") {
+		t.Errorf("expected the gps and old creator to be removed got %s", b)
+	}
+	p, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ns, name string
+		want     []string
+	}{
+		{NamespaceDC, "creator", []string{"Jane & Co"}},
+		{NamespaceDC, "rights", []string{"© Byrd"}},
+		{NamespacePhotoshop, "TransmissionReference", []string{"booking-42"}},
+		{NamespacePhotoshop, "City", []string{"Aarhus"}},
+		{NamespaceDC, "subject", []string{"fire"}},
+	}
+	for _, test := range tests {
+		if got := p.All(test.ns, test.name); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %q got %q", test.name, test.want, got)
+		}
+	}
+	// properties that are not edited are kept as they were
+	if !strings.Contains(string(b), "<dc:subject><rdf:Bag><rdf:li>fire</rdf:li></rdf:Bag></dc:subject>") {
+		t.Errorf("expected dc:subject to be kept got %s", b)
+	}
+}
+
+func TestEditEmpty(t *testing.T) {
+	for _, packet := range []string{"", `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description rdf:about=""/></rdf:RDF></x:xmpmeta>`} {
+		b, err := Edit([]byte(packet), []Property{{NS: NamespacePhotoshop, Name: "Credit", Value: "Byrd"}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Get(NamespacePhotoshop, "Credit"); got != "Byrd" {
+			t.Errorf("expected %q got %q in %s", "Byrd", got, b)
+		}
+	}
+	if _, err := Edit([]byte("<x:xmpmeta>"), nil, nil); err != ErrMalformed {
+		t.Errorf("expected %v got %v", ErrMalformed, err)
+	}
+}
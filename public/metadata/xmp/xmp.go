@@ -0,0 +1,213 @@
+// Package xmp reads the properties of XMP packets, the RDF/XML metadata Adobe tools and newsroom
+// systems write. Packets are embedded in APP1 segments of JPEGs, tag 700 of TIFF and RAW files and
+// mime items of HEIF files, or stored next to a RAW file as a .xmp sidecar.
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Namespaces of the properties the metadata package reads
+const (
+	NamespaceRDF       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	NamespaceDC        = "http://purl.org/dc/elements/1.1/"
+	NamespacePhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	NamespaceExif      = "http://ns.adobe.com/exif/1.0/"
+	NamespaceXMP       = "http://ns.adobe.com/xap/1.0/"
+)
+
+var ErrMalformed = errors.New("xmp: malformed packet")
+
+// Packet holds the simple properties and array items of each property, keyed by namespace and name.
+// Structured properties are skipped.
+type Packet struct {
+	props map[string][]string
+}
+
+func key(ns, name string) string {
+	return ns + name
+}
+
+// Parse reads the rdf:Description elements of an XMP packet
+func Parse(b []byte) (*Packet, error) {
+	p := &Packet{props: make(map[string][]string)}
+	dec := xml.NewDecoder(bytes.NewReader(bytes.TrimRight(b, "\x00")))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return p, nil
+		}
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Space != NamespaceRDF || start.Name.Local != "Description" {
+			continue
+		}
+		// simple properties may be written as attributes of the description
+		for _, attr := range start.Attr {
+			if attr.Name.Space == "" || attr.Name.Space == "xmlns" || attr.Name.Space == NamespaceRDF {
+				continue
+			}
+			if v := strings.TrimSpace(attr.Value); v != "" {
+				p.add(attr.Name.Space, attr.Name.Local, v)
+			}
+		}
+		if err := p.readDescription(dec); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Packet) add(ns, name string, values ...string) {
+	k := key(ns, name)
+	p.props[k] = append(p.props[k], values...)
+}
+
+// readDescription reads the property elements of a description up to its end
+func (p *Packet) readDescription(dec *xml.Decoder) error {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ErrMalformed
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			values, err := readProperty(dec)
+			if err != nil {
+				return err
+			}
+			if len(values) > 0 {
+				p.add(t.Name.Space, t.Name.Local, values...)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// readProperty returns the text of a simple property or the items of an rdf:Alt, rdf:Bag or rdf:Seq.
+// The x-default item of a language alternative comes first.
+func readProperty(dec *xml.Decoder) ([]string, error) {
+	var values []string
+	var text strings.Builder
+	children := false
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			children = true
+			if t.Name.Space != NamespaceRDF || t.Name.Local != "li" {
+				depth++
+				continue
+			}
+			v, err := readText(dec)
+			if err != nil {
+				return nil, err
+			}
+			if v == "" {
+				continue
+			}
+			if isDefaultLang(t) {
+				values = append([]string{v}, values...)
+			} else {
+				values = append(values, v)
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				if !children {
+					if v := strings.TrimSpace(text.String()); v != "" {
+						values = append(values, v)
+					}
+				}
+				return values, nil
+			}
+			depth--
+		case xml.CharData:
+			if depth == 0 {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+func isDefaultLang(start xml.StartElement) bool {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "lang" && attr.Value == "x-default" {
+			return true
+		}
+	}
+	return false
+}
+
+// readText returns the text of the current element up to its end
+func readText(dec *xml.Decoder) (string, error) {
+	var text strings.Builder
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", ErrMalformed
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return strings.TrimSpace(text.String()), nil
+			}
+			depth--
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+}
+
+// Get returns the first value of the property
+func (p *Packet) Get(ns, name string) string {
+	if p == nil {
+		return ""
+	}
+	if values := p.props[key(ns, name)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// All returns every item of an array property like dc:subject
+func (p *Packet) All(ns, name string) []string {
+	if p == nil {
+		return nil
+	}
+	return p.props[key(ns, name)]
+}
+
+// dateLayouts are the forms of XMP dates, from the most precise
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// ParseDate parses an XMP date, dates without a time zone are read as UTC
+func ParseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("xmp: invalid date " + s)
+}
@@ -0,0 +1,84 @@
+package xmp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const packet = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    photoshop:City="Aarhus"
+    photoshop:DateCreated="2020-05-04T13:37:00+02:00">
+   <dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li></rdf:Seq></dc:creator>
+   <dc:description><rdf:Alt>
+     <rdf:li xml:lang="da">Brand i havnen</rdf:li>
+     <rdf:li xml:lang="x-default">Fire at the harbour</rdf:li>
+   </rdf:Alt></dc:description>
+   <dc:subject><rdf:Bag><rdf:li>fire</rdf:li><rdf:li>harbour</rdf:li></rdf:Bag></dc:subject>
+   <photoshop:Credit>Byrd</photoshop:Credit>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(packet + "\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ns, name string
+		want     []string
+	}{
+		{NamespacePhotoshop, "City", []string{"Aarhus"}},
+		{NamespacePhotoshop, "Credit", []string{"Byrd"}},
+		{NamespaceDC, "creator", []string{"Jane Doe"}},
+		{NamespaceDC, "description", []string{"Fire at the harbour", "Brand i havnen"}},
+		{NamespaceDC, "subject", []string{"fire", "harbour"}},
+		{NamespaceDC, "rights", nil},
+	}
+	for _, test := range tests {
+		if got := p.All(test.ns, test.name); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected %q got %q", test.name, test.want, got)
+		}
+	}
+	if got := p.Get(NamespaceDC, "description"); got != "Fire at the harbour" {
+		t.Errorf("expected %q got %q", "Fire at the harbour", got)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	if _, err := Parse([]byte(packet[:300])); err != ErrMalformed {
+		t.Errorf("expected %v got %v", ErrMalformed, err)
+	}
+	var p *Packet
+	if got := p.Get(NamespaceDC, "rights"); got != "" {
+		t.Errorf("expected empty got %q", got)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2020-05-04T13:37:00+02:00", time.Date(2020, 5, 4, 11, 37, 0, 0, time.UTC)},
+		{"2020-05-04T13:37:00", time.Date(2020, 5, 4, 13, 37, 0, 0, time.UTC)},
+		{"2020-05-04T13:37Z", time.Date(2020, 5, 4, 13, 37, 0, 0, time.UTC)},
+		{"2020-05-04", time.Date(2020, 5, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := ParseDate(test.in)
+		if err != nil || !got.Equal(test.want) {
+			t.Errorf("%s: expected %v got %v %v", test.in, test.want, got, err)
+		}
+	}
+	if _, err := ParseDate("yesterday"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -1,15 +1,21 @@
 package thumbnail
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
+	"image"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 
 	"github.com/disintegration/imaging"
+
+	"github.com/byrdapp/byrd-pro-api/public/metadata/heif"
+	"github.com/byrdapp/byrd-pro-api/public/metadata/ifd"
+	exifimage "github.com/byrdapp/byrd-pro-api/public/metadata/image"
 )
 
 const (
@@ -49,13 +55,21 @@ func (t *thumbnail) VideoThumbnail(x, y int) (FFMPEGThumbnail, error) {
 type ImageThumbnail []byte
 
 func (t *thumbnail) ImageThumbnail(x, y int) (ImageThumbnail, error) {
-	opt := imaging.AutoOrientation(true)
-	rc := ioutil.NopCloser(t.r)
-	img, err := imaging.Decode(rc, opt)
+	br := bufio.NewReader(t.r)
+	var img image.Image
+	var err error
+	header, _ := br.Peek(heif.SniffLen)
+	switch {
+	case heif.IsHEIF(header):
+		img, err = decodeHEIF(br)
+	case ifd.IsTIFF(header):
+		img, err = decodeRawPreview(br)
+	default:
+		img, err = imaging.Decode(br, imaging.AutoOrientation(true))
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rc.Close()
 	img = imaging.Resize(img, x, y, imaging.Lanczos)
 	var buf bytes.Buffer
 	if err := imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(60)); err != nil {
@@ -64,6 +78,72 @@ func (t *thumbnail) ImageThumbnail(x, y int) (ImageThumbnail, error) {
 	return buf.Bytes(), nil
 }
 
+// decodeHEIF decodes the primary image of a heif file with ffmpeg, which applies its rotation.
+// The tiled images of iPhones need ffmpeg 7 or newer.
+func decodeHEIF(r io.Reader) (image.Image, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, errors.New("ffmpeg no bin in $PATH")
+	}
+	// the items of a heif file are found through offsets, so ffmpeg reads it from a file instead of a pipe
+	f, err := ioutil.TempFile(os.TempDir(), "heif-*")
+	if err != nil {
+		return nil, err
+	}
+	defer removeFile(f)
+	if _, err := io.Copy(f, r); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(ffmpeg, "-v", "quiet", "-i", f.Name(), "-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "pipe:")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg heif decode: %v", err)
+	}
+	return imaging.Decode(bytes.NewReader(out))
+}
+
+// decodeRawPreview decodes the embedded JPEG preview of a camera RAW file instead of its sensor data
+func decodeRawPreview(r io.Reader) (image.Image, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := exifimage.DecodeRaw(b)
+	if err != nil {
+		return nil, err
+	}
+	preview, err := raw.Preview()
+	if err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(bytes.NewReader(preview))
+	if err != nil {
+		return nil, err
+	}
+	return orient(img, raw.Orientation()), nil
+}
+
+// orient turns img upright by its EXIF orientation
+func orient(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
 func removeFile(f *os.File) error {
 	if err := f.Close(); err != nil {
 		return err
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
 	"time"
 
 	"github.com/byrdapp/byrd-pro-api/public/logger"
@@ -57,3 +58,115 @@ func (t *TimeBuilder) IsZero() error {
 	}
 	return nil
 }
+
+// IsValid is true when both dates are set and the interval does not end before it starts
+func (t *TimeBuilder) IsValid() bool {
+	return t.IsZero() == nil && !t.DateEnd.Before(t.DateStart)
+}
+
+// Duration of the interval
+func (t *TimeBuilder) Duration() time.Duration {
+	return t.DateEnd.Sub(t.DateStart)
+}
+
+// Contains reports whether tm is within the half-open interval [DateStart, DateEnd)
+func (t *TimeBuilder) Contains(tm time.Time) bool {
+	return !tm.Before(t.DateStart) && tm.Before(t.DateEnd)
+}
+
+// Overlaps reports whether the two half-open intervals share any time.
+// Intervals that only touch, one ending when the other starts, do not overlap.
+func (t *TimeBuilder) Overlaps(o *TimeBuilder) bool {
+	return t.DateStart.Before(o.DateEnd) && o.DateStart.Before(t.DateEnd)
+}
+
+// Clamp returns the part of the interval within bounds, or nil when they do not overlap
+func (t *TimeBuilder) Clamp(bounds *TimeBuilder) *TimeBuilder {
+	if !t.Overlaps(bounds) {
+		return nil
+	}
+	c := *t
+	if c.DateStart.Before(bounds.DateStart) {
+		c.DateStart = bounds.DateStart
+	}
+	if c.DateEnd.After(bounds.DateEnd) {
+		c.DateEnd = bounds.DateEnd
+	}
+	return &c
+}
+
+// Merge sorts the intervals and joins the ones that overlap or touch
+func Merge(intervals []*TimeBuilder) []*TimeBuilder {
+	sorted := make([]*TimeBuilder, 0, len(intervals))
+	for _, i := range intervals {
+		if i != nil && i.Duration() > 0 {
+			c := *i
+			sorted = append(sorted, &c)
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].DateStart.Before(sorted[b].DateStart)
+	})
+	var merged []*TimeBuilder
+	for _, i := range sorted {
+		if n := len(merged); n > 0 && !i.DateStart.After(merged[n-1].DateEnd) {
+			if i.DateEnd.After(merged[n-1].DateEnd) {
+				merged[n-1].DateEnd = i.DateEnd
+			}
+			continue
+		}
+		merged = append(merged, i)
+	}
+	return merged
+}
+
+// Subtract removes every busy interval from the free intervals
+func Subtract(free, busy []*TimeBuilder) []*TimeBuilder {
+	busy = Merge(busy)
+	var out []*TimeBuilder
+	for _, f := range Merge(free) {
+		start := f.DateStart
+		for _, b := range busy {
+			if !b.Overlaps(&TimeBuilder{start, f.DateEnd}) {
+				continue
+			}
+			if b.DateStart.After(start) {
+				out = append(out, &TimeBuilder{start, b.DateStart})
+			}
+			start = b.DateEnd
+		}
+		if start.Before(f.DateEnd) {
+			out = append(out, &TimeBuilder{start, f.DateEnd})
+		}
+	}
+	return out
+}
+
+// Weekly expands a recurring weekly slot, from startMinute to endMinute after midnight
+// on weekday in loc, into the occurrences overlapping bounds. Slots passing midnight
+// have endMinute <= startMinute and end the following day.
+func Weekly(weekday time.Weekday, startMinute, endMinute int, loc *time.Location, bounds *TimeBuilder) []*TimeBuilder {
+	if loc == nil {
+		loc = time.UTC
+	}
+	if endMinute <= startMinute {
+		endMinute += 24 * 60
+	}
+	// start a day early so a slot passing midnight before bounds is included
+	from := bounds.DateStart.In(loc).AddDate(0, 0, -1)
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	for day.Weekday() != weekday {
+		day = day.AddDate(0, 0, 1)
+	}
+	var out []*TimeBuilder
+	for ; day.Before(bounds.DateEnd); day = day.AddDate(0, 0, 7) {
+		occurrence := &TimeBuilder{
+			time.Date(day.Year(), day.Month(), day.Day(), 0, startMinute, 0, 0, loc),
+			time.Date(day.Year(), day.Month(), day.Day(), 0, endMinute, 0, 0, loc),
+		}
+		if c := occurrence.Clamp(bounds); c != nil {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func at(day, hour int) time.Time {
+	return time.Date(2020, time.April, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestOverlaps(t *testing.T) {
+	a := NewTime(at(15, 10), at(15, 12))
+	tests := []struct {
+		b        *TimeBuilder
+		expected bool
+	}{
+		{NewTime(at(15, 11), at(15, 13)), true},
+		{NewTime(at(15, 9), at(15, 13)), true},
+		{NewTime(at(15, 12), at(15, 13)), false},
+		{NewTime(at(15, 8), at(15, 10)), false},
+	}
+	for _, test := range tests {
+		if got := a.Overlaps(test.b); got != test.expected {
+			t.Errorf("%v overlaps %v: expected %v got %v", a, test.b, test.expected, got)
+		}
+	}
+}
+
+func TestMergeAndSubtract(t *testing.T) {
+	merged := Merge([]*TimeBuilder{
+		NewTime(at(15, 14), at(15, 16)),
+		NewTime(at(15, 9), at(15, 11)),
+		NewTime(at(15, 10), at(15, 12)),
+		NewTime(at(15, 12), at(15, 13)),
+	})
+	if len(merged) != 2 || !merged[0].DateEnd.Equal(at(15, 13)) || !merged[1].DateStart.Equal(at(15, 14)) {
+		t.Fatalf("unexpected merge %v", merged)
+	}
+
+	free := Subtract(
+		[]*TimeBuilder{NewTime(at(15, 8), at(15, 18))},
+		[]*TimeBuilder{NewTime(at(15, 10), at(15, 11)), NewTime(at(15, 17), at(15, 19))},
+	)
+	expected := []*TimeBuilder{NewTime(at(15, 8), at(15, 10)), NewTime(at(15, 11), at(15, 17))}
+	if len(free) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, free)
+	}
+	for i := range expected {
+		if !free[i].DateStart.Equal(expected[i].DateStart) || !free[i].DateEnd.Equal(expected[i].DateEnd) {
+			t.Errorf("expected %v got %v", expected[i], free[i])
+		}
+	}
+}
+
+func TestWeekly(t *testing.T) {
+	// 2020-04-13 is a monday
+	bounds := NewTime(at(13, 0), at(27, 0))
+	slots := Weekly(time.Wednesday, 9*60, 17*60, time.UTC, bounds)
+	if len(slots) != 2 || !slots[0].DateStart.Equal(at(15, 9)) || !slots[1].DateEnd.Equal(at(22, 17)) {
+		t.Errorf("unexpected occurrences %v", slots)
+	}
+
+	night := Weekly(time.Sunday, 22*60, 2*60, time.UTC, bounds)
+	// the slot starting sunday the 12th spills past midnight into bounds
+	if len(night) != 3 || !night[0].DateStart.Equal(at(13, 0)) || !night[1].DateStart.Equal(at(19, 22)) || !night[1].DateEnd.Equal(at(20, 2)) {
+		t.Errorf("unexpected overnight occurrences %v", night)
+	}
+}
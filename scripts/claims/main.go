@@ -0,0 +1,98 @@
+// Backfills the role custom claims of every user from the /admins list and the
+// isMedia, isPress and isProfessional flags of the profiles. Existing role claims
+// are kept, the script only grants. Run without -apply to see what would change.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/byrdapp/byrd-pro-api/internal/storage"
+	"github.com/byrdapp/byrd-pro-api/internal/storage/firebase"
+)
+
+func main() {
+	env := flag.String("env", "development", "set environment")
+	apply := flag.Bool("apply", false, "write the claims, otherwise only print them")
+	flag.Parse()
+	initCreds(*env)
+
+	ctx := context.Background()
+	srv, err := firebase.NewFB()
+	if err != nil {
+		panic(err)
+	}
+	fb := srv.(*firebase.Firebase)
+
+	var admins map[string]interface{}
+	if err := fb.Client.NewRef(os.Getenv("ENV")+"/admins").Get(ctx, &admins); err != nil {
+		panic(err)
+	}
+	res, err := fb.Client.NewRef(os.Getenv("ENV") + "/profiles").OrderByKey().GetOrdered(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	wanted := make(map[string]storage.RoleClaims)
+	for uid := range admins {
+		wanted[uid] = storage.RoleClaims{Admin: true}
+	}
+	for _, r := range res {
+		var p storage.FirebaseProfile
+		if err := r.Unmarshal(&p); err != nil {
+			fmt.Printf("skipping profile %s: %v\n", r.Key(), err)
+			continue
+		}
+		rc := wanted[r.Key()]
+		rc.Professional = p.IsProfessional
+		rc.Media = p.IsMedia || p.IsPress
+		wanted[r.Key()] = rc
+	}
+
+	var changed, failed int
+	for uid, want := range wanted {
+		current, _, err := fb.GetRoleClaims(ctx, uid)
+		if err != nil {
+			fmt.Printf("skipping %s: %v\n", uid, err)
+			failed++
+			continue
+		}
+		next := storage.RoleClaims{
+			Admin:        current.Admin || want.Admin,
+			Professional: current.Professional || want.Professional,
+			Media:        current.Media || want.Media,
+		}
+		if next == current {
+			continue
+		}
+		fmt.Printf("%s: %+v -> %+v\n", uid, current, next)
+		changed++
+		if !*apply {
+			continue
+		}
+		if err := fb.SetRoleClaims(ctx, uid, next); err != nil {
+			fmt.Printf("failed to set claims of %s: %v\n", uid, err)
+			failed++
+		}
+	}
+	fmt.Printf("%d users, %d changed, %d failed (applied: %v)\n", len(wanted), changed, failed, *apply)
+}
+
+func initCreds(env string) {
+	switch env {
+	case "development":
+		if err := godotenv.Load(".env"); err != nil {
+			panic(err)
+		}
+	case "production":
+		if err := godotenv.Load("production.env"); err != nil {
+			panic(err)
+		}
+	default:
+		panic("no evironment set")
+	}
+}